@@ -0,0 +1,446 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Apache Arrow IPC streaming format, see
+//
+//	https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc
+//
+// We only need a small part of the format(a schema and a single record batch
+// of primitive columns), so the flatbuffers messages are encoded by hand to
+// avoid the heavy Arrow dependency.
+
+// Arrow metadata enums used here.
+const (
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeTimestamp     = 10
+
+	arrowPrecisionDouble = 2
+	arrowUnitMillisecond = 1
+
+	arrowContinuation = 0xFFFFFFFF
+)
+
+// arrowColumn is a column converted from exportTable.
+type arrowColumn struct {
+	name  string
+	typ   int
+	valid []bool
+	nulls int
+
+	ints    []int64
+	floats  []float64
+	bools   []bool
+	strings []string
+}
+
+// inferArrowType infer arrow type of column i of the table.
+func (t *exportTable) inferArrowType(i int) int {
+	switch t.columnType(i) {
+	case ColumnTime:
+		return arrowTypeTimestamp
+	case ColumnInt:
+		return arrowTypeInt
+	case ColumnFloat:
+		return arrowTypeFloatingPoint
+	case ColumnBool:
+		return arrowTypeBool
+	case ColumnString:
+	}
+	return arrowTypeUtf8
+}
+
+func (t *exportTable) arrowColumns() []*arrowColumn {
+	cols := make([]*arrowColumn, 0, len(t.columns))
+
+	for i, col := range t.columns {
+		c := &arrowColumn{
+			name:  col.name,
+			typ:   t.inferArrowType(i),
+			valid: make([]bool, len(t.rows)),
+		}
+
+		for j, row := range t.rows {
+			v := row[i]
+			c.valid[j] = v != nil
+			if v == nil {
+				c.nulls++
+			}
+
+			switch c.typ {
+			case arrowTypeInt, arrowTypeTimestamp:
+				var n int64
+				switch x := v.(type) {
+				case float64:
+					n = int64(x)
+				case int64:
+					n = x
+				case uint64:
+					n = int64(x)
+				}
+				c.ints = append(c.ints, n)
+
+			case arrowTypeFloatingPoint:
+				f, _ := toFloat(v)
+				c.floats = append(c.floats, f)
+
+			case arrowTypeBool:
+				b, _ := v.(bool)
+				c.bools = append(c.bools, b)
+
+			default:
+				c.strings = append(c.strings, formatValue(v))
+			}
+		}
+
+		cols = append(cols, c)
+	}
+
+	return cols
+}
+
+func (t *exportTable) writeArrow(w io.Writer) error {
+	cols := t.arrowColumns()
+
+	if err := writeArrowMessage(w, arrowSchemaMessage(cols), nil); err != nil {
+		return err
+	}
+
+	if len(t.rows) > 0 {
+		meta, body := arrowRecordBatchMessage(cols, len(t.rows))
+		if err := writeArrowMessage(w, meta, body); err != nil {
+			return err
+		}
+	}
+
+	// end-of-stream
+	var eos [8]byte
+	binary.LittleEndian.PutUint32(eos[:], arrowContinuation)
+	_, err := w.Write(eos[:])
+	return err
+}
+
+// writeArrowMessage write encapsulated message: continuation, metadata size,
+// metadata(padded to 8 bytes) and body.
+func writeArrowMessage(w io.Writer, meta, body []byte) error {
+	for len(meta)%8 != 0 {
+		meta = append(meta, 0)
+	}
+
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[:4], arrowContinuation)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(len(meta)))
+
+	for _, b := range [][]byte{prefix[:], meta, body} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func arrowMessage(headerType int, header fbObject, bodyLen int) []byte {
+	return fbFinish(fbTable{
+		fbScalar(2, arrowMetadataV5),
+		fbScalar(1, uint64(headerType)),
+		fbOffset(header),
+		fbScalar(8, uint64(bodyLen)),
+	})
+}
+
+func arrowSchemaMessage(cols []*arrowColumn) []byte {
+	fields := make([]fbObject, 0, len(cols))
+
+	for _, c := range cols {
+		var typ fbTable
+		switch c.typ {
+		case arrowTypeInt:
+			typ = fbTable{fbScalar(4, 64), fbScalar(1, 1)} // bitWidth, is_signed
+		case arrowTypeFloatingPoint:
+			typ = fbTable{fbScalar(2, arrowPrecisionDouble)}
+		case arrowTypeTimestamp:
+			typ = fbTable{fbScalar(2, arrowUnitMillisecond)}
+		default: // utf8 and bool are empty tables
+			typ = fbTable{}
+		}
+
+		fields = append(fields, fbTable{
+			fbOffset(fbString(c.name)),
+			fbScalar(1, 1), // nullable
+			fbScalar(1, uint64(c.typ)),
+			fbOffset(typ),
+			nil,                     // dictionary
+			fbOffset(fbVector(nil)), // children, required by some readers
+		})
+	}
+
+	schema := fbTable{
+		fbScalar(2, 0), // little endian
+		fbOffset(fbVector(fields)),
+	}
+
+	return arrowMessage(arrowHeaderSchema, schema, 0)
+}
+
+// arrowBody is the record batch body with buffers aligned to 8 bytes.
+type arrowBody struct {
+	data    []byte
+	buffers []int64 // offset, length pairs
+}
+
+func (b *arrowBody) add(buf []byte) {
+	b.buffers = append(b.buffers, int64(len(b.data)), int64(len(buf)))
+	b.data = append(b.data, buf...)
+	for len(b.data)%8 != 0 {
+		b.data = append(b.data, 0)
+	}
+}
+
+func bitmap(bits []bool) []byte {
+	buf := make([]byte, (len(bits)+7)/8)
+	for i, on := range bits {
+		if on {
+			buf[i/8] |= 1 << (i % 8)
+		}
+	}
+	return buf
+}
+
+func arrowRecordBatchMessage(cols []*arrowColumn, n int) ([]byte, []byte) {
+	var (
+		body  arrowBody
+		nodes []int64
+	)
+
+	for _, c := range cols {
+		nodes = append(nodes, int64(n), int64(c.nulls))
+
+		body.add(bitmap(c.valid))
+
+		switch c.typ {
+		case arrowTypeInt, arrowTypeTimestamp:
+			buf := make([]byte, 8*n)
+			for i, v := range c.ints {
+				binary.LittleEndian.PutUint64(buf[8*i:], uint64(v))
+			}
+			body.add(buf)
+
+		case arrowTypeFloatingPoint:
+			buf := make([]byte, 8*n)
+			for i, v := range c.floats {
+				binary.LittleEndian.PutUint64(buf[8*i:], math.Float64bits(v))
+			}
+			body.add(buf)
+
+		case arrowTypeBool:
+			body.add(bitmap(c.bools))
+
+		default:
+			offsets := make([]byte, 4*(n+1))
+			var data []byte
+			for i, s := range c.strings {
+				data = append(data, s...)
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			body.add(offsets)
+			body.add(data)
+		}
+	}
+
+	batch := fbTable{
+		fbScalar(8, uint64(n)),
+		fbOffset(fbStructVector(16, nodes)),
+		fbOffset(fbStructVector(16, body.buffers)),
+	}
+
+	return arrowMessage(arrowHeaderRecordBatch, batch, len(body.data)), body.data
+}
+
+// A minimal forward-only flatbuffers encoder. Child objects are always
+// written after their parent, so all unsigned offsets point forward.
+
+type fbObject interface {
+	// write the object into b, and returns the position of the object.
+	write(b *fbBuilder) int
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putUint32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v)), uint32(v>>32))
+}
+
+// fbFinish encode the root table.
+func fbFinish(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	pos := root.write(b)
+	b.putUint32(0, uint32(pos))
+	return b.buf
+}
+
+// fbField is a table field, a scalar or a offset to child object.
+type fbField struct {
+	size   int
+	scalar uint64
+	child  fbObject
+}
+
+func fbScalar(size int, v uint64) *fbField {
+	return &fbField{size: size, scalar: v}
+}
+
+func fbOffset(child fbObject) *fbField {
+	return &fbField{size: 4, child: child}
+}
+
+// fbTable is a table, fields indexed by field ID, nil for absent field.
+type fbTable []*fbField
+
+func (t fbTable) write(b *fbBuilder) int {
+	// layout inline fields: larger fields first, each aligned to it's size.
+	offs := make([]int, len(t))
+	size := 4 // soffset to vtable
+	for _, sz := range []int{8, 4, 2, 1} {
+		for i, f := range t {
+			if f == nil || f.size != sz {
+				continue
+			}
+			for size%sz != 0 {
+				size++
+			}
+			offs[i] = size
+			size += sz
+		}
+	}
+
+	// vtable
+	b.pad(2)
+	vt := len(b.buf)
+	b.buf = appendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = appendUint16(b.buf, uint16(size))
+	for _, off := range offs {
+		b.buf = appendUint16(b.buf, uint16(off))
+	}
+
+	// table inline data
+	b.pad(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	b.putUint32(pos, uint32(pos-vt))
+
+	for i, f := range t {
+		if f == nil || f.child != nil {
+			continue
+		}
+
+		var scalar [8]byte
+		binary.LittleEndian.PutUint64(scalar[:], f.scalar)
+		copy(b.buf[pos+offs[i]:], scalar[:f.size])
+	}
+
+	for i, f := range t {
+		if f == nil || f.child == nil {
+			continue
+		}
+
+		at := pos + offs[i]
+		child := f.child.write(b)
+		b.putUint32(at, uint32(child-at))
+	}
+
+	return pos
+}
+
+// fbString is a string.
+type fbString string
+
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// fbVector is a vector of tables(or strings).
+type fbVector []fbObject
+
+func (v fbVector) write(b *fbBuilder) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+
+	for i, obj := range v {
+		at := pos + 4 + 4*i
+		child := obj.write(b)
+		b.putUint32(at, uint32(child-at))
+	}
+
+	return pos
+}
+
+// fbInt64Structs is a vector of structs, each struct consists of int64 fields.
+type fbInt64Structs struct {
+	structSize int
+	values     []int64
+}
+
+func fbStructVector(structSize int, values []int64) fbObject {
+	return &fbInt64Structs{structSize: structSize, values: values}
+}
+
+func (v *fbInt64Structs) write(b *fbBuilder) int {
+	// elements should be aligned to 8 bytes
+	b.pad(4)
+	if (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0, 0, 0, 0)
+	}
+
+	pos := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(v.values)*8/v.structSize))
+	for _, x := range v.values {
+		b.buf = appendUint64(b.buf, uint64(x))
+	}
+
+	return pos
+}
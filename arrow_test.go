@@ -0,0 +1,161 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"bytes"
+	"encoding/binary"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// le encode values little endian, []byte appended as is.
+func le(values ...any) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			buf.Write(b)
+			continue
+		}
+		binary.Write(&buf, binary.LittleEndian, v) //nolint:errcheck,gosec
+	}
+	return buf.Bytes()
+}
+
+// TestArrowLayout compare the stream byte by byte with the layout of Arrow
+// IPC streaming format(Message.fbs, Schema.fbs and the columnar format) and
+// flatbuffers binary format. Offsets within the comments are relative to
+// the start of the message metadata.
+func TestArrowLayout(t *T.T) {
+	tbl := &exportTable{
+		columns: []*exportColumn{{name: "n", kind: colValue}, {name: "s", kind: colTag}},
+		rows:    [][]any{{1.0, "a"}, {nil, "bc"}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, tbl.writeArrow(&buf))
+
+	// message table vtable: version(2 bytes, at 20), header_type(1 byte, at 22),
+	// header(offset, at 16), bodyLength(8 bytes, at 8) within 23 bytes.
+	messageVTable := le(uint16(12), uint16(23), uint16(20), uint16(22), uint16(16), uint16(8))
+
+	schema := le(
+		uint32(0xFFFFFFFF), uint32(200), // continuation, metadata size
+
+		uint32(16),    // 0: root offset to Message table
+		messageVTable, // 4: vtable of Message
+		// 16: Message
+		uint32(12), uint32(0), // soffset to vtable, padding
+		int64(0),         // bodyLength
+		uint32(16),       // header, to Schema at 48
+		uint16(4),        // version V5
+		byte(1), byte(0), // header_type Schema, padding
+
+		// 40: vtable of Schema: endianness(at 8), fields(at 4) within 10 bytes
+		uint16(8), uint16(10), uint16(8), uint16(4),
+		// 48: Schema
+		uint32(8),            // soffset to vtable
+		uint32(8),            // fields, to vector at 60
+		uint16(0), uint16(0), // endianness Little, padding
+		// 60: fields vector
+		uint32(2), uint32(24), uint32(92), // length, to Field at 88, to Field at 160
+
+		// 72: vtable of Field: name(at 4), nullable(at 16), type_type(at 17),
+		// type(at 8), no dictionary, children(at 12) within 18 bytes
+		uint16(16), uint16(18), uint16(4), uint16(16), uint16(17), uint16(8), uint16(0), uint16(12),
+		// 88: Field n
+		uint32(16),       // soffset to vtable
+		uint32(16),       // name, to string at 108
+		uint32(32),       // type, to Int at 128
+		uint32(40),       // children, to vector at 140
+		byte(1), byte(2), // nullable, type_type Int
+		uint16(0), // padding
+		// 108: name
+		uint32(1), []byte("n\x00"),
+		// 114: vtable of Int: bitWidth(at 4), is_signed(at 8) within 9 bytes
+		uint16(8), uint16(9), uint16(4), uint16(8),
+		[]byte{0, 0, 0, 0, 0, 0}, // padding
+		// 128: Int
+		uint32(14), uint32(64), byte(1), // soffset to vtable, bitWidth, is_signed
+		[]byte{0, 0, 0}, // padding
+		// 140: children
+		uint32(0),
+
+		// 144: vtable of Field s, same as Field n
+		uint16(16), uint16(18), uint16(4), uint16(16), uint16(17), uint16(8), uint16(0), uint16(12),
+		// 160: Field s
+		uint32(16),       // soffset to vtable
+		uint32(16),       // name, to string at 180
+		uint32(24),       // type, to Utf8 at 192
+		uint32(24),       // children, to vector at 196
+		byte(1), byte(5), // nullable, type_type Utf8
+		uint16(0), // padding
+		// 180: name
+		uint32(1), []byte("s\x00"),
+		// 186: vtable of Utf8, an empty table
+		uint16(4), uint16(4),
+		uint16(0), // padding
+		// 192: Utf8
+		uint32(6),
+		// 196: children
+		uint32(0),
+	)
+
+	batch := le(
+		uint32(0xFFFFFFFF), uint32(208), // continuation, metadata size
+
+		uint32(16),    // 0: root offset to Message table
+		messageVTable, // 4: vtable of Message
+		// 16: Message
+		uint32(12), uint32(0), // soffset to vtable, padding
+		int64(56),        // bodyLength
+		uint32(24),       // header, to RecordBatch at 56
+		uint16(4),        // version V5
+		byte(3), byte(0), // header_type RecordBatch, padding
+
+		// 40: vtable of RecordBatch: length(at 8), nodes(at 16), buffers(at 20)
+		// within 24 bytes
+		uint16(10), uint16(24), uint16(8), uint16(16), uint16(20),
+		[]byte{0, 0, 0, 0, 0, 0}, // padding
+		// 56: RecordBatch
+		uint32(16), uint32(0), // soffset to vtable, padding
+		int64(2),   // length
+		uint32(12), // nodes, to vector at 84
+		uint32(48), // buffers, to vector at 124
+		uint32(0),  // padding
+		// 84: nodes, structs aligned to 8 bytes
+		uint32(2),
+		int64(2), int64(1), // n: length, null_count
+		int64(2), int64(0), // s: length, null_count
+		uint32(0), // padding
+		// 124: buffers, offset and length within body
+		uint32(5),
+		int64(0), int64(1), // n: validity
+		int64(8), int64(16), // n: values
+		int64(24), int64(1), // s: validity
+		int64(32), int64(12), // s: offsets
+		int64(48), int64(3), // s: data
+
+		// body, each buffer padded to 8 bytes
+		[]byte{0x1, 0, 0, 0, 0, 0, 0, 0}, // n: validity 0b01
+		int64(1), int64(0),               // n: values, null slot is zero
+		[]byte{0x3, 0, 0, 0, 0, 0, 0, 0},       // s: validity 0b11
+		int32(0), int32(1), int32(3), int32(0), // s: offsets, padding
+		[]byte("abc\x00\x00\x00\x00\x00"), // s: data
+	)
+
+	eos := le(uint32(0xFFFFFFFF), uint32(0))
+
+	want := append(append(schema, batch...), eos...)
+	got := buf.Bytes()
+
+	require.Equal(t, len(want), len(got))
+	for i := 0; i < len(want); i += 8 {
+		assert.Equal(t, want[i:i+8], got[i:i+8], "bytes at %d", i)
+	}
+}
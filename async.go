@@ -0,0 +1,160 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A PollPolicy control how async query result polled.
+type PollPolicy struct {
+	// Interval is the wait duration before the first poll, and multiplied by
+	// Multiplier after each poll, but no more than MaxInterval. Zero fields of
+	// them are filled by DefaultPollPolicy.
+	Interval    time.Duration
+	MaxInterval time.Duration
+	Multiplier  float64
+
+	// Timeout is the max duration waiting the async query to complete. If not set,
+	// the async timeout of the DQL(see WithAsyncTimeout) used. Zero means
+	// no timeout(but ctx still works).
+	Timeout time.Duration
+
+	// OnPartial, if set, called on each result when the async query still running.
+	OnPartial func(*DQLResult)
+
+	// QueryOptions are options(such as WithToken and WithHTTPS) for each request.
+	QueryOptions []QueryOption
+}
+
+// DefaultPollPolicy poll async query result every 500ms to 5s.
+func DefaultPollPolicy() *PollPolicy {
+	return &PollPolicy{
+		Interval:    500 * time.Millisecond,
+		MaxInterval: 5 * time.Second,
+		Multiplier:  1.5,
+	}
+}
+
+// withDefaults get copy of p, zero fields of intervals filled by DefaultPollPolicy.
+func (p *PollPolicy) withDefaults() *PollPolicy {
+	def := DefaultPollPolicy()
+	if p == nil {
+		return def
+	}
+
+	cp := *p
+	if cp.Interval <= 0 {
+		cp.Interval = def.Interval
+	}
+	if cp.MaxInterval <= 0 {
+		cp.MaxInterval = def.MaxInterval
+		if cp.MaxInterval < cp.Interval {
+			cp.MaxInterval = cp.Interval
+		}
+	}
+	if cp.Multiplier == 0 {
+		cp.Multiplier = def.Multiplier
+	}
+	return &cp
+}
+
+func (p *PollPolicy) next(du time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		du = time.Duration(float64(du) * p.Multiplier)
+	}
+
+	if p.MaxInterval > 0 && du > p.MaxInterval {
+		du = p.MaxInterval
+	}
+
+	return du
+}
+
+// asyncDone check if the async query completed.
+func asyncDone(res *DQLResult) bool {
+	return res.Complete || !res.IsRunning
+}
+
+// QueryAsync submit the DQL q as async query(see WithAsync), and poll the result
+// with the returned async ID until it completed. If p is nil, DefaultPollPolicy used,
+// and zero intervals of p are filled by DefaultPollPolicy.
+//
+// If the query not completed within the timeout, the returned error wraps ErrTimeout.
+func (c *Client) QueryAsync(ctx context.Context, q *dql, p *PollPolicy) (*DQLResult, error) {
+	p = p.withDefaults()
+
+	timeout := p.Timeout
+	if timeout == 0 && q.AsyncTimeout != "" {
+		du, err := time.ParseDuration(q.AsyncTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid async timeout %q: %w", q.AsyncTimeout, err)
+		}
+		timeout = du
+	}
+
+	pc := &pageConf{opts: p.QueryOptions}
+
+	submit := *q
+	submit.IsAsync = true
+	submit.AsyncID = ""
+
+	res, err := pc.queryPage(ctx, c, &submit)
+	if err != nil {
+		return nil, err
+	}
+
+	if asyncDone(res) {
+		return res, nil
+	}
+
+	if res.AsyncID == "" {
+		return nil, errors.New("async query running but no async ID returned")
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	poll := *q
+	poll.IsAsync = false
+	poll.AsyncID = res.AsyncID
+
+	interval := p.Interval
+	for {
+		if p.OnPartial != nil {
+			p.OnPartial(res)
+		}
+
+		wait := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return nil, ctxErr(ctx, nil)
+		case <-deadline:
+			wait.Stop()
+			return nil, fmt.Errorf("%w: async query %s still running after %s", ErrTimeout, poll.AsyncID, timeout)
+		case <-wait.C:
+		}
+
+		res, err = pc.queryPage(ctx, c, &poll)
+		if err != nil {
+			return nil, err
+		}
+
+		if asyncDone(res) {
+			return res, nil
+		}
+
+		interval = p.next(interval)
+	}
+}
@@ -0,0 +1,116 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"errors"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// asyncServer complete the async query after n polls.
+func asyncServer(t *T.T, n int) *queryServer {
+	t.Helper()
+
+	polls := 0
+	return newQueryServer(t, func(d *dql) *DQLResult {
+		res := &DQLResult{AsyncID: "async-1", IsRunning: true}
+
+		if d.AsyncID != "" {
+			polls++
+			res.Series = []*Row{{Name: "nginx", Values: make([][]any, polls)}}
+			if n >= 0 && polls >= n {
+				res.IsRunning = false
+				res.Complete = true
+			}
+		}
+		return res
+	})
+}
+
+func TestQueryAsync(t *T.T) {
+	t.Run("poll-until-complete", func(t *T.T) {
+		ts := asyncServer(t, 3)
+		defer ts.Close()
+
+		c := ts.client()
+
+		var partials []int
+		res, err := c.QueryAsync(context.Background(), MustBuildDQL("L::nginx"), &PollPolicy{
+			Interval:    time.Millisecond,
+			MaxInterval: 5 * time.Millisecond,
+			Multiplier:  2,
+			OnPartial: func(res *DQLResult) {
+				n := 0
+				if len(res.Series) > 0 {
+					n = len(res.Series[0].Values)
+				}
+				partials = append(partials, n)
+			},
+		})
+
+		require.NoError(t, err)
+		assert.True(t, res.Complete)
+		assert.Len(t, res.Series[0].Values, 3)
+		assert.Equal(t, []int{0, 1, 2}, partials)
+
+		received := ts.queries()
+		require.Len(t, received, 4)
+		assert.True(t, received[0].IsAsync)
+		for _, d := range received[1:] {
+			assert.Equal(t, "async-1", d.AsyncID)
+		}
+	})
+
+	t.Run("timeout", func(t *T.T) {
+		ts := asyncServer(t, -1)
+		defer ts.Close()
+
+		c := ts.client()
+
+		_, err := c.QueryAsync(context.Background(),
+			MustBuildDQL("L::nginx", WithAsyncTimeout(50*time.Millisecond)),
+			&PollPolicy{Interval: 5 * time.Millisecond})
+
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrTimeout))
+	})
+
+	t.Run("cancel", func(t *T.T) {
+		ts := asyncServer(t, -1)
+		defer ts.Close()
+
+		c := ts.client()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := c.QueryAsync(ctx, MustBuildDQL("L::nginx"), &PollPolicy{Interval: 5 * time.Millisecond})
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("zero-policy", func(t *T.T) {
+		ts := asyncServer(t, -1)
+		defer ts.Close()
+
+		c := ts.client()
+
+		// intervals filled by default, no busy polling within the timeout
+		_, err := c.QueryAsync(context.Background(), MustBuildDQL("L::nginx"), &PollPolicy{Timeout: 100 * time.Millisecond})
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.Len(t, ts.queries(), 1)
+
+		p := (&PollPolicy{Interval: 10 * time.Second}).withDefaults()
+		assert.Equal(t, 10*time.Second, p.MaxInterval)
+		assert.Equal(t, 1.5, p.Multiplier)
+		assert.Equal(t, DefaultPollPolicy(), (*PollPolicy)(nil).withDefaults())
+	})
+}
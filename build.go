@@ -7,7 +7,8 @@ package dql
 
 // MustBuildDQL build a DQL query, and panic on any error.
 // Most of the time, the build will not fail, we can use
-// the Must function without worry.
+// the Must function without worry. But with WithValidate(true),
+// invalid query will panic.
 func MustBuildDQL(dql string, opts ...DQLOption) *dql {
 	q, err := BuildDQL(dql, opts...)
 	if err != nil {
@@ -17,7 +18,8 @@ func MustBuildDQL(dql string, opts ...DQLOption) *dql {
 }
 
 // BuildDQL used to build a DQL query with one or more options.
-// dqlStr is the basic DQL query string.
+// dqlStr is the basic DQL query string. With WithValidate(true),
+// the query is validated and error returned if it's invalid.
 func BuildDQL(dqlStr string, opts ...DQLOption) (*dql, error) {
 	q := &dql{
 		DQL:         dqlStr,
@@ -30,5 +32,15 @@ func BuildDQL(dqlStr string, opts ...DQLOption) (*dql, error) {
 		}
 	}
 
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	if q.validate {
+		if err := q.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	return q, nil
 }
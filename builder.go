@@ -0,0 +1,548 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/GuanceCloud/dql-go/parser"
+)
+
+// A QueryBuilder used to build DQL query in fluent style, all field names
+// and values are escaped, so it's safe to build query from user input:
+//
+//	s := dql.Logging("nginx").
+//		Fields("status", dql.Count("__docid").As("n")).
+//		Where(dql.Eq("host", host), dql.Wildcard("message", "*err*")).
+//		Range(time.Hour).
+//		By("status").
+//		Limit(100).
+//		String()
+//
+// And the string can be passed to BuildDQL.
+type QueryBuilder struct {
+	q   *parser.Query
+	err error
+}
+
+// From start a query on namespace ns(short or long name, such as "L" or
+// "logging") and sources. The namespace is written in short name.
+func From(ns Namespace, sources ...string) *QueryBuilder {
+	b := &QueryBuilder{q: &parser.Query{Namespace: string(ns)}}
+
+	if short, err := ParseNamespace(string(ns)); err != nil {
+		b.err = err
+	} else {
+		b.q.Namespace = string(short)
+	}
+
+	for _, s := range sources {
+		b.q.Sources = append(b.q.Sources, &parser.Source{Name: s})
+	}
+
+	return b
+}
+
+// Metric start a query on metric(M::) sources.
+func Metric(sources ...string) *QueryBuilder { return From(NSMetric, sources...) }
+
+// Logging start a query on logging(L::) sources.
+func Logging(sources ...string) *QueryBuilder { return From(NSLogging, sources...) }
+
+// Object start a query on object(O::) sources.
+func Object(sources ...string) *QueryBuilder { return From(NSObject, sources...) }
+
+// CustomObject start a query on custom object(CO::) sources.
+func CustomObject(sources ...string) *QueryBuilder { return From(NSCustomObject, sources...) }
+
+// Event start a query on event(E::) sources.
+func Event(sources ...string) *QueryBuilder { return From(NSEvent, sources...) }
+
+// Tracing start a query on tracing(T::) sources.
+func Tracing(sources ...string) *QueryBuilder { return From(NSTracing, sources...) }
+
+// RUM start a query on RUM(R::) sources.
+func RUM(sources ...string) *QueryBuilder { return From(NSRUM, sources...) }
+
+// Security start a query on security(S::) sources.
+func Security(sources ...string) *QueryBuilder { return From(NSSecurity, sources...) }
+
+// Network start a query on network(N::) sources.
+func Network(sources ...string) *QueryBuilder { return From(NSNetwork, sources...) }
+
+// Profiling start a query on profiling(P::) sources.
+func Profiling(sources ...string) *QueryBuilder { return From(NSProfiling, sources...) }
+
+// BackupLog start a query on backup log(BL::) sources.
+func BackupLog(sources ...string) *QueryBuilder { return From(NSBackupLog, sources...) }
+
+// SourceRegex add re('pattern') source, it matches sources by regex.
+func (b *QueryBuilder) SourceRegex(pattern string) *QueryBuilder {
+	b.q.Sources = append(b.q.Sources, &parser.Source{Name: pattern, Regex: true})
+	return b
+}
+
+// Fields set targets of the query. Each field is a field name(string) or Expr.
+func (b *QueryBuilder) Fields(fields ...any) *QueryBuilder {
+	for _, f := range fields {
+		e := b.field(f)
+		b.q.Targets = append(b.q.Targets, &parser.Target{Expr: e.node, Alias: e.alias})
+	}
+	return b
+}
+
+// Where add conditions, multiple conditions are AND-ed.
+func (b *QueryBuilder) Where(conds ...Expr) *QueryBuilder {
+	for _, c := range conds {
+		b.q.Where = append(b.q.Where, b.expr(c))
+	}
+	return b
+}
+
+// Range set time range to last du, such as [1h].
+func (b *QueryBuilder) Range(du time.Duration) *QueryBuilder {
+	if du <= 0 {
+		b.setErr(fmt.Errorf("time range %s should be positive", du))
+	}
+
+	b.timeRange().Start = durationValue(du)
+	b.timeRange().End = nil
+	return b
+}
+
+// Between set time range to [start:end].
+func (b *QueryBuilder) Between(start, end time.Time) *QueryBuilder {
+	if !start.Before(end) {
+		b.setErr(fmt.Errorf("time range start(%s) should before end(%s)", start, end))
+	}
+
+	b.timeRange().Start = timeValue(start)
+	b.timeRange().End = timeValue(end)
+	return b
+}
+
+// Interval set interval of the time range, such as [1h::5m].
+func (b *QueryBuilder) Interval(du time.Duration) *QueryBuilder {
+	if du <= 0 {
+		b.setErr(fmt.Errorf("interval %s should be positive", du))
+	}
+
+	b.timeRange().Interval = durationValue(du)
+	return b
+}
+
+// Rollup set rollup function of the time range, such as [1h::5m:last].
+func (b *QueryBuilder) Rollup(fn string) *QueryBuilder {
+	if !parser.IsIdent(fn) {
+		b.setErr(fmt.Errorf("invalid rollup function %q", fn))
+	}
+
+	b.timeRange().Rollup = fn
+	return b
+}
+
+// By set group-by. Each field is a field name(string) or Expr.
+func (b *QueryBuilder) By(fields ...any) *QueryBuilder {
+	for _, f := range fields {
+		b.q.GroupBy = append(b.q.GroupBy, b.field(f).node)
+	}
+	return b
+}
+
+// Having set having condition.
+func (b *QueryBuilder) Having(cond Expr) *QueryBuilder {
+	b.q.Having = b.expr(cond)
+	return b
+}
+
+// OrderBy add ORDER BY on field.
+func (b *QueryBuilder) OrderBy(field any, order OrderByOrder) *QueryBuilder {
+	b.q.OrderBy = append(b.q.OrderBy, b.orderBy(field, order))
+	return b
+}
+
+// SOrderBy add SORDER BY on field.
+func (b *QueryBuilder) SOrderBy(field any, order OrderByOrder) *QueryBuilder {
+	b.q.SOrderBy = append(b.q.SOrderBy, b.orderBy(field, order))
+	return b
+}
+
+// Limit set LIMIT.
+func (b *QueryBuilder) Limit(n int64) *QueryBuilder {
+	b.q.Limit = &n
+	return b
+}
+
+// Offset set OFFSET.
+func (b *QueryBuilder) Offset(n int64) *QueryBuilder {
+	b.q.Offset = &n
+	return b
+}
+
+// SLimit set SLIMIT.
+func (b *QueryBuilder) SLimit(n int64) *QueryBuilder {
+	b.q.SLimit = &n
+	return b
+}
+
+// SOffset set SOFFSET.
+func (b *QueryBuilder) SOffset(n int64) *QueryBuilder {
+	b.q.SOffset = &n
+	return b
+}
+
+// Err get the first error during building, such as unsupported value type.
+func (b *QueryBuilder) Err() error {
+	if b.err == nil && len(b.q.Sources) == 0 {
+		return fmt.Errorf("no source")
+	}
+	return b.err
+}
+
+// String get the DQL string. Check Err() before using it.
+func (b *QueryBuilder) String() string {
+	return b.q.String()
+}
+
+// Build build the DQL query with options, same as BuildDQL(b.String(), opts...).
+func (b *QueryBuilder) Build(opts ...DQLOption) (*dql, error) {
+	if err := b.Err(); err != nil {
+		return nil, err
+	}
+	return BuildDQL(b.String(), opts...)
+}
+
+func (b *QueryBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *QueryBuilder) expr(e Expr) parser.Expr {
+	if e.err != nil {
+		b.setErr(e.err)
+	}
+
+	if e.node == nil {
+		b.setErr(fmt.Errorf("empty expression"))
+		return &parser.NilLit{}
+	}
+
+	return e.node
+}
+
+func (b *QueryBuilder) field(f any) Expr {
+	switch x := f.(type) {
+	case string:
+		return Field(x)
+	case Expr:
+		return Expr{node: b.expr(x), alias: x.alias}
+	default:
+		b.setErr(fmt.Errorf("field should be string or Expr, got %T", f))
+		return Expr{node: &parser.NilLit{}}
+	}
+}
+
+func (b *QueryBuilder) orderBy(field any, order OrderByOrder) *parser.OrderBy {
+	o := &parser.OrderBy{Expr: b.field(field).node}
+	switch order {
+	case ASC:
+		o.Order = "ASC"
+	case DESC:
+		o.Order = "DESC"
+	}
+	return o
+}
+
+func (b *QueryBuilder) timeRange() *parser.TimeRange {
+	if b.q.TimeRange == nil {
+		b.q.TimeRange = &parser.TimeRange{}
+	}
+	return b.q.TimeRange
+}
+
+func durationValue(du time.Duration) *parser.TimeValue {
+	return &parser.TimeValue{Raw: parser.FormatDuration(du), Duration: du}
+}
+
+func timeValue(t time.Time) *parser.TimeValue {
+	ms := t.UnixMilli()
+	return &parser.TimeValue{Raw: strconv.FormatInt(ms, 10), Timestamp: ms, Absolute: true}
+}
+
+// An Expr is an expression used within QueryBuilder, such as a field,
+// a function call or a condition.
+type Expr struct {
+	node  parser.Expr
+	alias string
+	err   error
+}
+
+// As set alias of the expression, only used within Fields.
+func (e Expr) As(alias string) Expr {
+	e.alias = alias
+	return e
+}
+
+// String get the expression in DQL.
+func (e Expr) String() string {
+	if e.node == nil {
+		return ""
+	}
+
+	if e.alias != "" {
+		return e.node.String() + " AS " + parser.QuoteIdent(e.alias)
+	}
+	return e.node.String()
+}
+
+// Field refer to a field by name, the name is quoted if needed.
+func Field(name string) Expr {
+	if name == "*" {
+		return Expr{node: &parser.Star{}}
+	}
+	return Expr{node: &parser.Ident{Name: name}}
+}
+
+// Value used to pass value v as expression.
+func Value(v any) Expr {
+	node, err := literal(v)
+	return Expr{node: node, err: err}
+}
+
+// Func call function name with args. Args of type Expr used as is, others
+// are values, use Field to pass field as argument.
+func Func(name string, args ...any) Expr {
+	e := Expr{}
+	if !parser.IsIdent(name) {
+		e.err = fmt.Errorf("invalid function name %q", name)
+	}
+
+	call := &parser.Call{Name: name}
+	for _, arg := range args {
+		a := exprOf(arg)
+		if a.err != nil && e.err == nil {
+			e.err = a.err
+		}
+		call.Args = append(call.Args, a.node)
+	}
+
+	e.node = call
+	return e
+}
+
+// Count is count(field), field can be "*".
+func Count(field string) Expr { return Func("count", Field(field)) }
+
+// Sum is sum(field).
+func Sum(field string) Expr { return Func("sum", Field(field)) }
+
+// Avg is avg(field).
+func Avg(field string) Expr { return Func("avg", Field(field)) }
+
+// Min is min(field).
+func Min(field string) Expr { return Func("min", Field(field)) }
+
+// Max is max(field).
+func Max(field string) Expr { return Func("max", Field(field)) }
+
+// First is first(field).
+func First(field string) Expr { return Func("first", Field(field)) }
+
+// Last is last(field).
+func Last(field string) Expr { return Func("last", Field(field)) }
+
+// Distinct is distinct(field).
+func Distinct(field string) Expr { return Func("distinct", Field(field)) }
+
+// Fill is fill(e, v), fill v if e is nil.
+func Fill(e Expr, v any) Expr { return Func("fill", e, v) }
+
+// Eq is field = v.
+func Eq(field string, v any) Expr { return cmp("=", field, v) }
+
+// Ne is field != v.
+func Ne(field string, v any) Expr { return cmp("!=", field, v) }
+
+// Gt is field > v.
+func Gt(field string, v any) Expr { return cmp(">", field, v) }
+
+// Gte is field >= v.
+func Gte(field string, v any) Expr { return cmp(">=", field, v) }
+
+// Lt is field < v.
+func Lt(field string, v any) Expr { return cmp("<", field, v) }
+
+// Lte is field <= v.
+func Lte(field string, v any) Expr { return cmp("<=", field, v) }
+
+// In is field IN [values...], values can also be a single slice.
+func In(field string, values ...any) Expr { return cmp("IN", field, listOf(values)) }
+
+// NotIn is field NOT IN [values...], values can also be a single slice.
+func NotIn(field string, values ...any) Expr { return cmp("NOT IN", field, listOf(values)) }
+
+// Wildcard is field = wildcard('pattern'), such as Wildcard("message", "*error*").
+func Wildcard(field, pattern string) Expr { return Eq(field, Func("wildcard", pattern)) }
+
+// Match is field = match('text'), full-text match on field.
+func Match(field, text string) Expr { return Eq(field, Func("match", text)) }
+
+// QueryString is field = query_string('text').
+func QueryString(field, text string) Expr { return Eq(field, Func("query_string", text)) }
+
+// Regex is field = re('pattern').
+func Regex(field, pattern string) Expr { return Eq(field, Func("re", pattern)) }
+
+// And join conditions by AND.
+func And(conds ...Expr) Expr { return logical("AND", conds) }
+
+// Or join conditions by OR.
+func Or(conds ...Expr) Expr { return logical("OR", conds) }
+
+// Not is NOT cond.
+func Not(cond Expr) Expr {
+	if cond.err != nil {
+		return cond
+	}
+	return Expr{node: &parser.UnaryExpr{Op: "NOT", X: paren(cond.node)}}
+}
+
+func cmp(op, field string, v any) Expr {
+	rhs := exprOf(v)
+	if rhs.err != nil {
+		return rhs
+	}
+
+	return Expr{node: &parser.BinaryExpr{Op: op, LHS: Field(field).node, RHS: rhs.node}}
+}
+
+func logical(op string, conds []Expr) Expr {
+	if len(conds) == 0 {
+		return Expr{err: fmt.Errorf("%s without conditions", op)}
+	}
+
+	var node parser.Expr
+	for _, c := range conds {
+		if c.err != nil {
+			return c
+		}
+
+		x := c.node
+		if be, ok := x.(*parser.BinaryExpr); ok && be.Op == "OR" && op == "AND" {
+			x = paren(x)
+		}
+
+		if node == nil {
+			node = x
+		} else {
+			node = &parser.BinaryExpr{Op: op, LHS: node, RHS: x}
+		}
+	}
+
+	return Expr{node: node}
+}
+
+// paren wrap binary expression with parenthesis.
+func paren(x parser.Expr) parser.Expr {
+	if _, ok := x.(*parser.BinaryExpr); ok {
+		return &parser.ParenExpr{X: x}
+	}
+	return x
+}
+
+func listOf(values []any) any {
+	if len(values) == 1 {
+		if k := reflect.ValueOf(values[0]).Kind(); k == reflect.Slice || k == reflect.Array {
+			return values[0]
+		}
+	}
+	return values
+}
+
+func exprOf(v any) Expr {
+	if e, ok := v.(Expr); ok {
+		return e
+	}
+	return Value(v)
+}
+
+func stringLit(s string) (parser.Expr, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("invalid UTF-8 string %q", s)
+	}
+	return &parser.StringLit{Value: s}, nil
+}
+
+// literal convert Go value to DQL literal. Supported are nil, string(valid
+// UTF-8), bool, integers, floats, positive time.Duration, time.Time(as UNIX
+// timestamp in ms) and slices of them(as list).
+func literal(v any) (parser.Expr, error) {
+	switch x := v.(type) {
+	case nil:
+		return &parser.NilLit{}, nil
+	case string:
+		return stringLit(x)
+	case bool:
+		return &parser.BoolLit{Value: x}, nil
+	case time.Duration:
+		if x <= 0 { // no negative duration literal within DQL
+			return nil, fmt.Errorf("unsupported value %s of type %T, should be positive", x, v)
+		}
+		return &parser.DurationLit{Raw: parser.FormatDuration(x), Value: x}, nil
+	case time.Time:
+		ms := x.UnixMilli()
+		return &parser.NumberLit{Raw: strconv.FormatInt(ms, 10), Value: float64(ms), IsInt: true}, nil
+	case Expr:
+		return x.node, x.err
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		return &parser.NumberLit{Raw: strconv.FormatInt(n, 10), Value: float64(n), IsInt: true}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := rv.Uint()
+		return &parser.NumberLit{Raw: strconv.FormatUint(n, 10), Value: float64(n), IsInt: true}, nil
+
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid number %v", f)
+		}
+		return &parser.NumberLit{Raw: strconv.FormatFloat(f, 'f', -1, 64), Value: f}, nil
+
+	case reflect.String: // named string types
+		return stringLit(rv.String())
+
+	case reflect.Bool:
+		return &parser.BoolLit{Value: rv.Bool()}, nil
+
+	case reflect.Slice, reflect.Array:
+		l := &parser.ListLit{}
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := literal(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := elem.(*parser.ListLit); ok {
+				return nil, fmt.Errorf("nested list not supported")
+			}
+			l.Elems = append(l.Elems, elem)
+		}
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type %T", v)
+}
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"math"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryBuilder(t *T.T) {
+	t.Run("basic", func(t *T.T) {
+		b := Logging("nginx").
+			Fields("status", Count("__docid").As("n")).
+			Where(Eq("host", "web-1"), Wildcard("message", "*err*")).
+			Range(time.Hour).
+			By("status").
+			Limit(100)
+
+		require.NoError(t, b.Err())
+		assert.Equal(t,
+			"L::nginx:(status, count(__docid) AS n) {host = 'web-1', message = wildcard('*err*')} [1h] BY status LIMIT 100",
+			b.String())
+
+		q, err := b.Build(WithValidate(true))
+		require.NoError(t, err)
+		assert.Equal(t, b.String(), q.DQL)
+	})
+
+	t.Run("long-namespace", func(t *T.T) {
+		for ns, want := range map[Namespace]string{"metric": "M::cpu", "logging": "L::cpu", "L": "L::cpu", NSObject: "O::cpu"} {
+			b := From(ns, "cpu")
+			require.NoError(t, b.Err(), ns)
+			assert.Equal(t, want, b.String(), ns)
+		}
+	})
+
+	t.Run("escape", func(t *T.T) {
+		b := Logging("my source").
+			Where(Eq("host name", "x' } OR 1 = 1 #"), Eq("limit", 1))
+
+		require.NoError(t, b.Err())
+		assert.Equal(t, "L::`my source` {`host name` = 'x\\' } OR 1 = 1 #', `limit` = 1}", b.String())
+
+		// the injected value is still a single string
+		q, err := parser.ParseQuery(b.String())
+		require.NoError(t, err)
+		require.Len(t, q.Where, 2)
+		assert.Equal(t, "x' } OR 1 = 1 #", q.Where[0].(*parser.BinaryExpr).RHS.(*parser.StringLit).Value)
+	})
+
+	t.Run("conditions", func(t *T.T) {
+		b := Metric("cpu").
+			Fields(Fill(Avg("usage"), 0)).
+			Where(
+				Or(Eq("a", 1), Ne("b", true)),
+				And(Or(Gt("c", 1.5), Lt("c", -1)), Not(In("d", "x", "y"))),
+				NotIn("e", []int{1, 2}),
+				Gte("f", nil),
+				Regex("g", `\d+`),
+			).
+			Interval(5*time.Minute).
+			Range(90*time.Minute).
+			Rollup("last").
+			OrderBy("time", DESC).
+			SOrderBy(Max("usage"), ASC).
+			Offset(10).SLimit(5).SOffset(1)
+
+		require.NoError(t, b.Err())
+		assert.Equal(t, "M::cpu:(fill(avg(usage), 0))"+
+			" {a = 1 OR b != true, (c > 1.5 OR c < -1) AND NOT (d IN ['x', 'y']), e NOT IN [1, 2], f >= nil, g = re('\\\\d+')}"+
+			" [1h30m::5m:last] ORDER BY time DESC SORDER BY max(usage) ASC OFFSET 10 SLIMIT 5 SOFFSET 1",
+			b.String())
+
+		_, err := parser.ParseQuery(b.String())
+		assert.NoError(t, err)
+	})
+
+	t.Run("between", func(t *T.T) {
+		start := time.UnixMilli(1672502400000)
+		b := Tracing().SourceRegex(".*").Between(start, start.Add(time.Hour))
+
+		require.NoError(t, b.Err())
+		assert.Equal(t, "T::re('.*') [1672502400000:1672506000000]", b.String())
+
+		b = Tracing("x").Between(start, start)
+		assert.Error(t, b.Err())
+	})
+
+	t.Run("errors", func(t *T.T) {
+		assert.Error(t, From("X", "cpu").Err())
+		assert.Error(t, From("", "cpu").Err())
+		assert.Error(t, Metric().Err())
+		assert.Error(t, Metric("cpu").Where(Eq("a", struct{}{})).Err())
+		assert.Error(t, Metric("cpu").Where(Eq("a", math.NaN())).Err())
+		assert.Error(t, Metric("cpu").Fields(1).Err())
+		assert.Error(t, Metric("cpu").Fields(Func("a b")).Err())
+		assert.Error(t, Metric("cpu").Where(And()).Err())
+		assert.Error(t, Metric("cpu").Rollup("x)").Err())
+		assert.Error(t, Metric("cpu").Range(-time.Hour).Err())
+		assert.Error(t, Metric("cpu").Range(0).Err())
+		assert.Error(t, Metric("cpu").Range(time.Hour).Interval(-time.Minute).Err())
+
+		_, err := Metric().Build()
+		assert.Error(t, err)
+	})
+}
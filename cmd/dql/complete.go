@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/GuanceCloud/dql-go"
+	"github.com/GuanceCloud/dql-go/parser"
+)
+
+// showFuncs are SHOW functions used to complete sources of namespaces, and
+// fields(%s is the quoted source) of the source.
+var showFuncs = map[dql.Namespace]struct {
+	sources string
+	fields  []string
+}{
+	dql.NSMetric:       {"show_measurement()", []string{"show_field_key(from=[%s])", "show_tag_key(from=[%s])"}},
+	dql.NSLogging:      {"show_logging_source()", []string{"show_logging_field(%s)"}},
+	dql.NSObject:       {"show_object_source()", []string{"show_object_field(%s)"}},
+	dql.NSCustomObject: {"show_custom_object_source()", []string{"show_custom_object_field(%s)"}},
+	dql.NSEvent:        {"show_event_source()", []string{"show_event_field(%s)"}},
+	dql.NSTracing:      {"show_tracing_service()", []string{"show_tracing_field(%s)"}},
+	dql.NSRUM:          {"show_rum_type()", []string{"show_rum_field(%s)"}},
+}
+
+// sourceRe match the namespace and source of DQL, such as M::cpu.
+var sourceRe = regexp.MustCompile("([A-Za-z_]+)::([A-Za-z_][A-Za-z0-9_-]*|`[^`]+`)")
+
+// completer complete namespaces, DQL keywords, and sources and fields
+// fetched by SHOW functions.
+type completer struct {
+	// show run the SHOW function, and get values of its first column.
+	show func(q string) ([]string, error)
+
+	// cache is results of SHOW functions, failures cached as nil, so an
+	// unreachable server does not block each completion.
+	cache map[string][]string
+}
+
+// complete get candidates of the word at the end of text, and number of
+// runes of the word. The text is the input so far, and only the last
+// statement within it is completed.
+func (c *completer) complete(text string) ([]string, int) {
+	if seps := separators(text); len(seps) > 0 {
+		text = text[seps[len(seps)-1]+1:]
+	}
+
+	i := identStart(text)
+	before, word := text[:i], text[i:]
+	n := len([]rune(word))
+
+	switch {
+	case strings.HasSuffix(before, "::"):
+		prefix := before[:len(before)-2]
+		ns, err := dql.ParseNamespace(prefix[identStart(prefix):])
+		if err != nil {
+			return nil, n
+		}
+		return matchPrefix(c.fetch(showFuncs[ns].sources), word, false), n
+
+	case strings.TrimSpace(before) == "":
+		var arr []string
+		for _, ns := range dql.Namespaces() {
+			arr = append(arr, ns.String()+"::", ns.Info().Name+"::")
+		}
+		return matchPrefix(arr, word, true), n
+
+	default:
+		keywords := parser.Keywords()
+		if word != "" && unicode.IsLower([]rune(word)[0]) {
+			for i, k := range keywords {
+				keywords[i] = strings.ToLower(k)
+			}
+		}
+
+		cands := matchPrefix(keywords, word, true)
+		if word != "" {
+			cands = append(matchPrefix(c.fields(text), word, false), cands...)
+		}
+		return cands, n
+	}
+}
+
+// fields get fields of the source within text.
+func (c *completer) fields(text string) []string {
+	m := sourceRe.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+
+	ns, err := dql.ParseNamespace(m[1])
+	if err != nil {
+		return nil
+	}
+
+	var res []string
+	for _, f := range showFuncs[ns].fields {
+		res = append(res, c.fetch(fmt.Sprintf(f, parser.QuoteString(strings.Trim(m[2], "`"))))...)
+	}
+	return res
+}
+
+// fetch run SHOW function q, results(nil on failure) cached until reset.
+func (c *completer) fetch(q string) []string {
+	if q == "" || c.show == nil {
+		return nil
+	}
+
+	if res, ok := c.cache[q]; ok {
+		return res
+	}
+
+	res, err := c.show(q)
+	if err != nil {
+		res = nil
+	}
+
+	if c.cache == nil {
+		c.cache = map[string][]string{}
+	}
+	c.cache[q] = res
+	return res
+}
+
+// reset clear the cache, such as the server changed.
+func (c *completer) reset() {
+	c.cache = nil
+}
+
+// identStart get the start index of identifier at the end of s.
+func identStart(s string) int {
+	i := len(s)
+	for i > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i -= size
+	}
+	return i
+}
@@ -0,0 +1,418 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt returned by editor on Ctrl-C.
+var errInterrupt = errors.New("interrupt")
+
+// maxHistory is the max number of history entries kept.
+const maxHistory = 1000
+
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".dql_history")
+}
+
+// history is the input history of the shell. Entries are appended to the
+// history file, one entry per line, and multi-line entries are quoted.
+type history struct {
+	file    string
+	entries []string
+}
+
+// loadHistory load history from file, the file truncated to the latest
+// maxHistory entries. Empty file disable the persistence.
+func loadHistory(file string) *history {
+	h := &history{file: file}
+	if file == "" {
+		return h
+	}
+
+	b, err := os.ReadFile(file) //nolint:gosec
+	if err != nil {
+		return h // no history yet
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, `"`) {
+			if s, err := strconv.Unquote(line); err == nil {
+				line = s
+			}
+		}
+		h.entries = append(h.entries, line)
+	}
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+
+		var sb strings.Builder
+		for _, e := range h.entries {
+			sb.WriteString(historyLine(e))
+		}
+		os.WriteFile(file, []byte(sb.String()), 0o600) //nolint:errcheck,gosec
+	}
+
+	return h
+}
+
+func historyLine(s string) string {
+	if strings.ContainsAny(s, "\r\n") || strings.HasPrefix(s, `"`) {
+		s = strconv.Quote(s)
+	}
+	return s + "\n"
+}
+
+// add append s to history, duplicate of the last entry skipped.
+func (h *history) add(s string) error {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+
+	if n := len(h.entries); n > 0 && h.entries[n-1] == s {
+		return nil
+	}
+
+	h.entries = append(h.entries, s)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+
+	if h.file == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(f, historyLine(s)); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return err
+	}
+	return f.Close()
+}
+
+// editor is a minimal line editor. On terminal(raw set), it supports
+// cursor moving, history and tab completion, otherwise lines are read
+// as is.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+	raw bool
+
+	history *history
+
+	// complete get candidates of the word before cursor within line, and
+	// number of runes of the word.
+	complete func(line string) ([]string, int)
+}
+
+// readLine read a line after prompt. io.EOF returned on Ctrl-D or end of
+// input, and errInterrupt on Ctrl-C.
+func (e *editor) readLine(prompt string) (string, error) {
+	io.WriteString(e.out, prompt) //nolint:errcheck,gosec
+
+	if !e.raw {
+		line, err := e.in.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	return e.edit(prompt)
+}
+
+// readKey read a key after prompt, the prompt is cleared then.
+func (e *editor) readKey(prompt string) (rune, error) {
+	io.WriteString(e.out, prompt) //nolint:errcheck,gosec
+
+	if !e.raw {
+		line, err := e.in.ReadString('\n')
+		io.WriteString(e.out, "\n") //nolint:errcheck,gosec
+		if line = strings.TrimSpace(line); line != "" {
+			return []rune(line)[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return '\n', nil
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+
+	r, _, err := e.in.ReadRune()
+	io.WriteString(e.out, "\r\x1b[K") //nolint:errcheck,gosec
+	return r, err
+}
+
+func ctrl(c byte) rune {
+	return rune(c & 0x1f)
+}
+
+// edit handle keys of raw terminal until Enter pressed.
+func (e *editor) edit(prompt string) (string, error) {
+	var (
+		buf   []rune
+		pos   int
+		hist  = len(e.history.entries)
+		saved []rune // the line edited before walking history
+	)
+
+	replace := func(from, to int, rs []rune) {
+		nb := make([]rune, 0, len(buf)+len(rs))
+		nb = append(nb, buf[:from]...)
+		nb = append(nb, rs...)
+		buf = append(nb, buf[to:]...)
+		pos = from + len(rs)
+	}
+
+	walk := func(i int) {
+		if i < 0 || i > len(e.history.entries) {
+			return
+		}
+
+		if hist == len(e.history.entries) {
+			saved = buf
+		}
+
+		if hist = i; hist == len(e.history.entries) {
+			buf = saved
+		} else {
+			buf = []rune(e.history.entries[hist])
+		}
+		pos = len(buf)
+	}
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n") //nolint:errcheck,gosec
+			return string(buf), nil
+
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\r\n") //nolint:errcheck,gosec
+			return "", errInterrupt
+
+		case ctrl('D'):
+			if len(buf) == 0 {
+				io.WriteString(e.out, "\r\n") //nolint:errcheck,gosec
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				replace(pos, pos+1, nil)
+			}
+
+		case 127, ctrl('H'):
+			if pos > 0 {
+				replace(pos-1, pos, nil)
+			}
+
+		case ctrl('A'):
+			pos = 0
+		case ctrl('E'):
+			pos = len(buf)
+		case ctrl('B'):
+			pos = moveCursor(pos, -1, len(buf))
+		case ctrl('F'):
+			pos = moveCursor(pos, 1, len(buf))
+		case ctrl('P'):
+			walk(hist - 1)
+		case ctrl('N'):
+			walk(hist + 1)
+		case ctrl('U'):
+			replace(0, pos, nil)
+		case ctrl('K'):
+			buf = buf[:pos]
+
+		case ctrl('W'):
+			i := pos
+			for i > 0 && unicode.IsSpace(buf[i-1]) {
+				i--
+			}
+			for i > 0 && !unicode.IsSpace(buf[i-1]) {
+				i--
+			}
+			replace(i, pos, nil)
+
+		case '\t':
+			if e.complete == nil {
+				break
+			}
+
+			cands, n := e.complete(string(buf[:pos]))
+			switch prefix := []rune(commonPrefix(cands)); {
+			case len(cands) == 0:
+				io.WriteString(e.out, "\a") //nolint:errcheck,gosec
+			case len(cands) == 1 || len(prefix) > n:
+				replace(pos-n, pos, prefix)
+			default:
+				fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(cands, "  ")) //nolint:errcheck
+			}
+
+		case 27: // escape sequences
+			switch key := e.escape(); key {
+			case 'A':
+				walk(hist - 1)
+			case 'B':
+				walk(hist + 1)
+			case 'C':
+				pos = moveCursor(pos, 1, len(buf))
+			case 'D':
+				pos = moveCursor(pos, -1, len(buf))
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '3': // delete
+				if pos < len(buf) {
+					replace(pos, pos+1, nil)
+				}
+			}
+
+		default:
+			if unicode.IsPrint(r) {
+				replace(pos, pos, []rune{r})
+			}
+		}
+
+		e.refresh(prompt, buf, pos)
+	}
+}
+
+// escape read escape sequence after ESC, and get its key: A-D for arrows,
+// H for home, F for end and 3 for delete.
+func (e *editor) escape() rune {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+
+	var num []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		switch {
+		case err != nil:
+			return 0
+		case r >= '0' && r <= '9', r == ';':
+			num = append(num, r)
+		case r == '~': // such as ESC[3~
+			switch string(num) {
+			case "1", "7":
+				return 'H'
+			case "4", "8":
+				return 'F'
+			case "3":
+				return '3'
+			}
+			return 0
+		default:
+			return r
+		}
+	}
+}
+
+// refresh redraw the line, newlines within the line(recalled from
+// history) are shown as spaces.
+func (e *editor) refresh(prompt string, buf []rune, pos int) {
+	var sb strings.Builder
+	sb.WriteString("\r")
+	sb.WriteString(prompt)
+	sb.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(string(buf)))
+	sb.WriteString("\x1b[K")
+	if n := len(buf) - pos; n > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dD", n)
+	}
+	io.WriteString(e.out, sb.String()) //nolint:errcheck,gosec
+}
+
+func moveCursor(pos, delta, n int) int {
+	if pos += delta; pos < 0 {
+		return 0
+	} else if pos > n {
+		return n
+	}
+	return pos
+}
+
+// commonPrefix get the longest common prefix of arr.
+func commonPrefix(arr []string) string {
+	if len(arr) == 0 {
+		return ""
+	}
+
+	prefix := []rune(arr[0])
+	for _, s := range arr[1:] {
+		rs := []rune(s)
+		i := 0
+		for i < len(prefix) && i < len(rs) && prefix[i] == rs[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return string(prefix)
+}
+
+// matchPrefix get sorted and unique elements of arr with prefix, fold for
+// case-insensitive match.
+func matchPrefix(arr []string, prefix string, fold bool) []string {
+	var (
+		res  []string
+		seen = map[string]bool{}
+	)
+
+	for _, s := range arr {
+		ok := strings.HasPrefix(s, prefix)
+		if fold {
+			ok = strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+		}
+
+		if ok && !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
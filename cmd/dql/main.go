@@ -0,0 +1,335 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Command dql run DQL queries against Datakit(or openway) and print the
+// results.
+//
+// Queries are read from arguments, files(-f) or stdin, multiple queries
+// within a file are separated by ';'. For example:
+//
+//	dql -host localhost:9529 -limit 10 'M::cpu:(usage_idle) [1h] BY host'
+//	echo 'L::nginx LIMIT 3' | dql -format csv
+//	dql -f queries.dql -format json
+//
+// Without queries and with stdin being a terminal(or flag -i), dql runs as
+// an interactive shell, see \help within the shell for meta-commands.
+//
+// Exit codes:
+//
+//	0  success
+//	1  query failed(Result.ErrorCode set)
+//	2  invalid usage, such as bad flags or options
+//	3  request failed, such as network error
+//	4  DQL parse error
+//	5  token invalid
+//	6  query timeout
+//	7  max duration exceeded
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/GuanceCloud/dql-go"
+)
+
+// Exit codes.
+const (
+	exitOK = iota
+	exitQueryError
+	exitUsage
+	exitRequest
+	exitParse
+	exitTokenInvalid
+	exitTimeout
+	exitMaxDuration
+)
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// usageError is error on invalid flags or queries.
+type usageError struct{ err error }
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+func usagef(format string, args ...any) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// exitCode get the exit code of err.
+func exitCode(err error) int {
+	var (
+		ue *usageError
+		qe *dql.QueryError
+	)
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, dql.ErrMaxDuration): // reported as parse error by Datakit
+		return exitMaxDuration
+	case errors.Is(err, dql.ErrParse):
+		return exitParse
+	case errors.Is(err, dql.ErrTokenInvalid):
+		return exitTokenInvalid
+	case errors.Is(err, dql.ErrTimeout):
+		return exitTimeout
+	case errors.As(err, &ue), errors.Is(err, dql.ErrInvalidOption):
+		return exitUsage
+	case errors.As(err, &qe) && qe.ErrorCode != "":
+		return exitQueryError
+	default:
+		return exitRequest
+	}
+}
+
+type config struct {
+	host    string
+	token   string
+	https   bool
+	format  string
+	files   multiFlag
+	explain bool
+
+	interactive bool
+	history     string
+	pageSize    int
+
+	opts *optionFlags
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dql", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: dql [flags] [query ...]\n\n"+ //nolint:errcheck
+			"Queries are read from arguments, -f files or stdin(or argument \"-\").\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	cfg := &config{opts: newOptionFlags(fs)}
+	fs.StringVar(&cfg.host, "host", "localhost:9529", "Datakit or openway host")
+	fs.StringVar(&cfg.token, "token", "", "workspace token")
+	fs.BoolVar(&cfg.https, "https", false, "query over HTTPS")
+	fs.StringVar(&cfg.format, "format", "table", "print format: table, json, csv, ndjson or lineprotocol")
+	fs.Var(&cfg.files, "f", "read queries from file, can be repeated")
+	fs.BoolVar(&cfg.explain, "echo-explain", false, "echo the explain of the query")
+	fs.BoolVar(&cfg.interactive, "i", false, "run as interactive shell")
+	fs.StringVar(&cfg.history, "history", defaultHistory(), "history file of interactive shell, empty to disable")
+	fs.IntVar(&cfg.pageSize, "page-size", 0, "lines per page of interactive shell, 0 for terminal height, -1 to disable")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	_, tty := terminal(stdin)
+	if cfg.interactive || (fs.NArg() == 0 && len(cfg.files) == 0 && tty) {
+		if err := newREPL(cfg, fs, stdin, stdout, stderr).run(ctx); err != nil {
+			fmt.Fprintf(stderr, "dql: %s\n", err) //nolint:errcheck
+			return exitRequest
+		}
+		return exitOK
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	err := query(ctx, cfg, fs.Args(), stdin, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "dql: %s\n", err) //nolint:errcheck
+	}
+
+	return exitCode(err)
+}
+
+func query(ctx context.Context, cfg *config, args []string, stdin io.Reader, stdout io.Writer) error {
+	texts, err := readQueries(args, cfg.files, stdin)
+	if err != nil {
+		return err
+	}
+
+	if len(texts) == 0 {
+		return usagef("no query specified")
+	}
+
+	return execute(ctx, cfg, dql.NewClient(cfg.host), texts, stdout)
+}
+
+// execute run queries and print the result.
+func execute(ctx context.Context, cfg *config, c *dql.Client, texts []string, stdout io.Writer) error {
+	p, err := newPrinter(cfg.format)
+	if err != nil {
+		return &usageError{err: err}
+	}
+
+	opts, err := cfg.opts.options()
+	if err != nil {
+		return err
+	}
+
+	qopts := queryOptions(cfg)
+
+	var (
+		batch  = append([]dql.QueryOption{}, qopts...)
+		asyncs []func() (*dql.DQLResult, error)
+	)
+
+	for _, s := range texts {
+		q, err := dql.BuildDQL(s, opts...)
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, dql.WithQueries(q))
+		asyncs = append(asyncs, func() (*dql.DQLResult, error) {
+			p := dql.DefaultPollPolicy()
+			p.QueryOptions = qopts
+			return c.QueryAsync(ctx, q, p)
+		})
+	}
+
+	res := &dql.Result{}
+	if cfg.opts.async {
+		for _, fn := range asyncs {
+			r, err := fn()
+			if err != nil {
+				return err
+			}
+			res.Content = append(res.Content, r)
+		}
+	} else if res, err = c.QueryContext(ctx, batch...); err != nil {
+		return err
+	}
+
+	return p.print(stdout, texts, res)
+}
+
+// queryOptions get query options of cfg.
+func queryOptions(cfg *config) []dql.QueryOption {
+	return []dql.QueryOption{
+		dql.WithToken(cfg.token),
+		dql.WithHTTPS(cfg.https),
+		dql.WithEchoExplain(cfg.explain),
+		dql.WithQueryError(true),
+	}
+}
+
+// readQueries read queries from args, files, or stdin if neither of them
+// specified. Argument "-" is stdin.
+func readQueries(args, files []string, stdin io.Reader) ([]string, error) {
+	var queries []string
+
+	if len(args) == 0 && len(files) == 0 {
+		args = []string{"-"}
+	}
+
+	for _, f := range files {
+		b, err := os.ReadFile(f) //nolint:gosec
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		queries = append(queries, splitQueries(string(b))...)
+	}
+
+	for _, arg := range args {
+		if arg != "-" {
+			queries = append(queries, splitQueries(arg)...)
+			continue
+		}
+
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, &usageError{err: err}
+		}
+		queries = append(queries, splitQueries(string(b))...)
+	}
+
+	return queries, nil
+}
+
+// splitQueries split queries separated by ';', ';' within quoted string
+// or comment are skipped, and empty queries are dropped.
+func splitQueries(s string) []string {
+	var (
+		res   []string
+		start int
+	)
+
+	add := func(q string) {
+		if q = strings.TrimSpace(q); q != "" && !isComment(q) {
+			res = append(res, q)
+		}
+	}
+
+	for _, i := range separators(s) {
+		add(s[start:i])
+		start = i + 1
+	}
+	add(s[start:])
+
+	return res
+}
+
+// terminated check if s ended by ';', only spaces or comments allowed
+// after the ';'.
+func terminated(s string) bool {
+	seps := separators(s)
+	return len(seps) > 0 && isComment(s[seps[len(seps)-1]+1:])
+}
+
+// separators get indexes of ';' that separate queries within s.
+func separators(s string) []int {
+	var (
+		res   []int
+		quote byte
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+
+		case c == ';':
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// isComment check if all lines of q are comments.
+func isComment(q string) bool {
+	for _, line := range strings.Split(q, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	T "testing"
+
+	"github.com/GuanceCloud/dql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payload struct {
+	Token   string
+	Queries []map[string]any `json:"queries"`
+}
+
+// server is a fake Datakit, payloads received got by the returned func.
+func server(t *T.T) (*httptest.Server, func() []payload) {
+	t.Helper()
+
+	var (
+		mtx      sync.Mutex
+		received []payload
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decode payload: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.Token = r.URL.Query().Get("token")
+
+		mtx.Lock()
+		received = append(received, p)
+		mtx.Unlock()
+
+		res := &dql.Result{}
+		for _, q := range p.Queries {
+			switch s := q["query"].(string); {
+			case strings.Contains(s, "parse_error"):
+				w.WriteHeader(http.StatusBadRequest)
+				res = &dql.Result{ErrorCode: "query.parse_error", Message: "unexpected token"}
+			case strings.Contains(s, "max_duration"):
+				w.WriteHeader(http.StatusBadRequest)
+				res = &dql.Result{ErrorCode: "query.parse_error", Message: "parse error: time range should less than 1h0m0s"}
+			case strings.Contains(s, "failed"):
+				w.WriteHeader(http.StatusInternalServerError)
+				res = &dql.Result{ErrorCode: "query.internal_error", Message: "oops"}
+			default:
+				res.Content = append(res.Content, &dql.DQLResult{Series: []*dql.Row{{
+					Name:    "cpu",
+					Tags:    map[string]string{"host": "a"},
+					Columns: []string{"time", "usage"},
+					Values:  [][]any{{float64(1000), 1.5}, {float64(2000), nil}},
+				}}})
+			}
+		}
+
+		json.NewEncoder(w).Encode(res) //nolint:errcheck
+	}))
+
+	return ts, func() []payload {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]payload(nil), received...)
+	}
+}
+
+func last(received func() []payload) payload {
+	all := received()
+	return all[len(all)-1]
+}
+
+func runDQL(t *T.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *T.T) {
+	ts, received := server(t)
+	defer ts.Close()
+
+	host := ts.Listener.Addr().String()
+
+	t.Run("table", func(t *T.T) {
+		code, out, errOut := runDQL(t, "", "-host", host, "-token", "tkn_xx", "M::cpu")
+		require.Equal(t, exitOK, code, errOut)
+
+		lines := strings.Split(out, "\n")
+		require.Len(t, lines, 5)
+		assert.Equal(t, []string{"name", "host", "time", "usage"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"cpu", "a"}, strings.Fields(lines[1])[:2])
+		assert.Equal(t, "1.5", strings.Fields(lines[1])[3])
+		assert.Len(t, strings.Fields(lines[2]), 3) // null usage
+		assert.Equal(t, "(2 rows)", lines[3])
+
+		assert.Equal(t, "tkn_xx", last(received).Token)
+	})
+
+	t.Run("options", func(t *T.T) {
+		code, _, errOut := runDQL(t, "", "-host", host,
+			"-limit", "10", "-sampling=false", "-order-by", "time:desc",
+			"-param", "host=a", "-time-range", "1680000000000,1680000060000",
+			"M::cpu { host = $host }")
+		require.Equal(t, exitOK, code, errOut)
+
+		q := last(received).Queries[0]
+		assert.Equal(t, "M::cpu { host = 'a' }", q["query"])
+		assert.Equal(t, 10.0, q["limit"])
+		assert.Equal(t, true, q["disable_sampling"])
+		assert.Equal(t, []any{map[string]any{"time": "desc"}}, q["order_by"])
+		assert.Equal(t, []any{1680000000000.0, 1680000060000.0}, q["time_range"])
+
+		// large-query not set, default kept
+		assert.Equal(t, false, q["disallow_large_query"])
+	})
+
+	t.Run("stdin-and-files", func(t *T.T) {
+		f := filepath.Join(t.TempDir(), "q.dql")
+		require.NoError(t, os.WriteFile(f, []byte("# comment\nM::a;\nM::b { x = ';' };"), 0o600))
+
+		code, out, errOut := runDQL(t, "M::c; M::d", "-host", host, "-f", f, "-format", "csv", "-")
+		require.Equal(t, exitOK, code, errOut)
+
+		var queries []string
+		for _, q := range last(received).Queries {
+			queries = append(queries, q["query"].(string))
+		}
+		assert.Equal(t, []string{"# comment\nM::a", "M::b { x = ';' }", "M::c", "M::d"}, queries)
+		assert.Equal(t, 4, strings.Count(out, "name,host,time,usage\n"))
+	})
+
+	t.Run("json", func(t *T.T) {
+		code, out, _ := runDQL(t, "", "-host", host, "-format", "json", "M::cpu")
+		require.Equal(t, exitOK, code)
+
+		var res dql.Result
+		require.NoError(t, json.Unmarshal([]byte(out), &res))
+		assert.Len(t, res.Content, 1)
+	})
+
+	t.Run("exit-codes", func(t *T.T) {
+		cases := []struct {
+			args []string
+			code int
+		}{
+			{[]string{"-host", host, "M::parse_error"}, exitParse},
+			{[]string{"-host", host, "M::max_duration"}, exitMaxDuration},
+			{[]string{"-host", host, "M::failed"}, exitQueryError},
+			{[]string{"-host", "127.0.0.1:1", "M::cpu"}, exitRequest},
+			{[]string{"-host", host, "-no-such-flag", "M::cpu"}, exitUsage},
+			{[]string{"-host", host, "-format", "xml", "M::cpu"}, exitUsage},
+			{[]string{"-host", host, "-qtype", "sql", "M::cpu"}, exitUsage},
+			{[]string{"-host", host, "-order-by", "time:up", "M::cpu"}, exitUsage},
+			{[]string{"-host", host, "-validate", "M::cpu {"}, exitParse},
+			{[]string{"-host", host, "-f", "/no/such/file"}, exitUsage},
+			{[]string{"-host", host, "-"}, exitUsage}, // empty stdin
+			{[]string{"-h"}, exitOK},
+		}
+
+		for _, tc := range cases {
+			code, _, errOut := runDQL(t, "", tc.args...)
+			assert.Equal(t, tc.code, code, "%v: %s", tc.args, errOut)
+		}
+	})
+}
+
+func TestSplitQueries(t *T.T) {
+	assert.Equal(t, []string{"M::a", `L::b { msg = "a;b" }`, "L::re(`x;`)"},
+		splitQueries("M::a;\n"+`L::b { msg = "a;b" };`+"L::re(`x;`);;  \n"))
+
+	assert.Equal(t, []string{"M::a # c;d"}, splitQueries("M::a # c;d\n;# only comment"))
+	assert.Empty(t, splitQueries(" ; \n"))
+}
+
+func TestParamValue(t *T.T) {
+	assert.Equal(t, int64(10), paramValue("10"))
+	assert.Equal(t, 1.5, paramValue("1.5"))
+	assert.Equal(t, true, paramValue("true"))
+	assert.Equal(t, []any{"a", "b"}, paramValue(`["a","b"]`))
+	assert.Equal(t, "abc", paramValue("abc"))
+}
@@ -0,0 +1,313 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+)
+
+// multiFlag is a flag can be repeated.
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// optionFlags are flags of DQL options, only options of flags set on
+// command line are applied.
+type optionFlags struct {
+	fs *flag.FlagSet
+
+	profile, optimized, maskVisible, async, showLabel, highlight bool
+	disableExpensiveQuery, disableMultipleField, disableSLimit   bool
+	disableQueryParse, sampling, alignTime, largeQuery, validate bool
+
+	conditions, outputFormat, asyncID, qtype string
+	timeRange, window, searchAfter           string
+	roleRules, workspaceRules                string
+
+	asyncTimeout, timeout, maxDuration, last, align time.Duration
+
+	maxPoint, offset, soffset, slimit int
+	cursorTime, stepInterval, limit   int64
+
+	orderBy, sorderBy, params, args multiFlag
+}
+
+func newOptionFlags(fs *flag.FlagSet) *optionFlags {
+	o := &optionFlags{fs: fs}
+
+	fs.BoolVar(&o.profile, "profile", false, "enable query profile")
+	fs.BoolVar(&o.optimized, "optimized", false, "enable query optimization")
+	fs.BoolVar(&o.maskVisible, "mask-visible", false, "show masked fields")
+	fs.BoolVar(&o.async, "async", false, "run as async query and poll the result")
+	fs.BoolVar(&o.showLabel, "show-label", false, "show labels of objects")
+	fs.BoolVar(&o.highlight, "highlight", false, "highlight matched keywords")
+	fs.BoolVar(&o.disableExpensiveQuery, "disable-expensive-query", false, "disable expensive query")
+	fs.BoolVar(&o.disableMultipleField, "disable-multiple-field", false, "disable multiple fields")
+	fs.BoolVar(&o.disableSLimit, "disable-slimit", false, "disable default slimit")
+	fs.BoolVar(&o.disableQueryParse, "disable-query-parse", false, "disable query parse within result")
+	fs.BoolVar(&o.sampling, "sampling", true, "enable sampling of the result")
+	fs.BoolVar(&o.alignTime, "align-time", false, "align time of the result")
+	fs.BoolVar(&o.largeQuery, "large-query", true, "allow large-data-set query")
+	fs.BoolVar(&o.validate, "validate", false, "validate the query before sending")
+
+	fs.StringVar(&o.conditions, "conditions", "", "extra where conditions")
+	fs.StringVar(&o.outputFormat, "output-format", "", "server-side result format, such as lineprotocol")
+	fs.StringVar(&o.asyncID, "async-id", "", "fetch result of async query ID")
+	fs.StringVar(&o.qtype, "qtype", "", "query type: dql or promql")
+	fs.StringVar(&o.timeRange, "time-range", "", "time range in UNIX ms: start,end")
+	fs.StringVar(&o.window, "window", "", "time window in RFC3339: start,end")
+	fs.StringVar(&o.searchAfter, "search-after", "", "search-after in JSON array")
+	fs.StringVar(&o.roleRules, "role-rules", "", "role rules in JSON, map of namespace to rules")
+	fs.StringVar(&o.workspaceRules, "workspace-rules", "", "multiple workspace index rules in JSON array")
+
+	fs.DurationVar(&o.asyncTimeout, "async-timeout", 0, "async query timeout")
+	fs.DurationVar(&o.timeout, "timeout", 0, "query timeout")
+	fs.DurationVar(&o.maxDuration, "max-duration", 0, "max time range of the query")
+	fs.DurationVar(&o.last, "last", 0, "query the last duration, such as 15m")
+	fs.DurationVar(&o.align, "align", 0, "align time window to the step")
+
+	fs.IntVar(&o.maxPoint, "max-point", 0, "max points of each series")
+	fs.IntVar(&o.offset, "offset", 0, "offset of rows")
+	fs.IntVar(&o.soffset, "soffset", 0, "offset of series")
+	fs.IntVar(&o.slimit, "slimit", 0, "max series")
+	fs.Int64Var(&o.cursorTime, "cursor-time", 0, "cursor timestamp for paging")
+	fs.Int64Var(&o.stepInterval, "step-interval", 0, "step interval of time-aggregate query")
+	fs.Int64Var(&o.limit, "limit", 0, "max rows")
+
+	fs.Var(&o.orderBy, "order-by", "order by field: field[:asc|desc], can be repeated")
+	fs.Var(&o.sorderBy, "sorder-by", "order series by field: field[:asc|desc], can be repeated")
+	fs.Var(&o.params, "param", "named parameter: name=value, can be repeated")
+	fs.Var(&o.args, "arg", "positional parameter($1, $2...), can be repeated")
+
+	return o
+}
+
+// options get DQL options of flags set on command line.
+func (o *optionFlags) options() ([]dql.DQLOption, error) {
+	var (
+		opts []dql.DQLOption
+		err  error
+	)
+
+	o.fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		var list []dql.DQLOption
+		switch f.Name {
+		case "order-by":
+			list, err = orderByOptions(o.orderBy, dql.WithOrderBy)
+		case "sorder-by":
+			list, err = orderByOptions(o.sorderBy, dql.WithSOrderBy)
+		default:
+			var opt dql.DQLOption
+			if opt, err = o.option(f.Name); opt != nil {
+				list = append(list, opt)
+			}
+		}
+
+		if err != nil {
+			err = usagef("invalid flag -%s: %s", f.Name, err)
+		}
+		opts = append(opts, list...)
+	})
+
+	return opts, err
+}
+
+func (o *optionFlags) option(name string) (dql.DQLOption, error) {
+	switch name {
+	case "profile":
+		return dql.WithProfile(o.profile), nil
+	case "optimized":
+		return dql.WithOptimized(o.optimized), nil
+	case "mask-visible":
+		return dql.WithMaskVisible(o.maskVisible), nil
+	case "async":
+		return dql.WithAsync(o.async), nil
+	case "show-label":
+		return dql.WithShowLabel(o.showLabel), nil
+	case "highlight":
+		return dql.WithHighlight(o.highlight), nil
+	case "disable-expensive-query":
+		return dql.WithDisableExpensiveQuery(o.disableExpensiveQuery), nil
+	case "disable-multiple-field":
+		return dql.WithDisableMultipleField(o.disableMultipleField), nil
+	case "disable-slimit":
+		return dql.WithDisableSLimit(o.disableSLimit), nil
+	case "disable-query-parse":
+		return dql.WithDisableQueryParse(o.disableQueryParse), nil
+	case "sampling":
+		return dql.WithSampling(o.sampling), nil
+	case "align-time":
+		return dql.WithAlignTime(o.alignTime), nil
+	case "large-query":
+		return dql.WithLargeQuery(o.largeQuery), nil
+	case "validate":
+		return dql.WithValidate(o.validate), nil
+
+	case "conditions":
+		return dql.WithConditions(o.conditions), nil
+	case "output-format":
+		of, err := dql.ParseOutputFormat(o.outputFormat)
+		if err != nil {
+			return nil, err
+		}
+		return dql.WithOutputFormat(of), nil
+	case "async-id":
+		return dql.WithAsyncID(o.asyncID), nil
+	case "qtype":
+		return dql.WithQueryType(o.qtype), nil
+	case "time-range":
+		start, end, err := pair(o.timeRange)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err1 := strconv.ParseInt(start, 10, 64)
+		e, err2 := strconv.ParseInt(end, 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("expect UNIX ms: start,end")
+		}
+		return dql.WithTimeWindow(time.UnixMilli(s), time.UnixMilli(e)), nil
+	case "window":
+		start, end, err := pair(o.window)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err1 := time.Parse(time.RFC3339Nano, start)
+		e, err2 := time.Parse(time.RFC3339Nano, end)
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("expect RFC3339 time: start,end")
+		}
+		return dql.WithTimeWindow(s, e), nil
+	case "search-after":
+		var after []any
+		if err := json.Unmarshal([]byte(o.searchAfter), &after); err != nil {
+			return nil, err
+		}
+		return dql.WithSearchAfter(after...), nil
+	case "role-rules":
+		var rules map[string][]dql.QueryRule
+		if err := json.Unmarshal([]byte(o.roleRules), &rules); err != nil {
+			return nil, err
+		}
+		return dql.WithRoleRules(rules), nil
+	case "workspace-rules":
+		var rules []*dql.WorkspaceIndexRule
+		if err := json.Unmarshal([]byte(o.workspaceRules), &rules); err != nil {
+			return nil, err
+		}
+		return dql.WithMultipleWorkspaceRules(rules...), nil
+
+	case "async-timeout":
+		return dql.WithAsyncTimeout(o.asyncTimeout), nil
+	case "timeout":
+		return dql.WithTimeout(o.timeout), nil
+	case "max-duration":
+		return dql.WithMaxDuration(o.maxDuration), nil
+	case "last":
+		return dql.WithLast(o.last), nil
+	case "align":
+		return dql.WithAlignedWindow(o.align), nil
+
+	case "max-point":
+		return dql.WithMaxPoint(o.maxPoint), nil
+	case "offset":
+		return dql.WithOffset(o.offset), nil
+	case "soffset":
+		return dql.WithSOffset(o.soffset), nil
+	case "slimit":
+		return dql.WithSLimit(o.slimit), nil
+	case "cursor-time":
+		return dql.WithCursorTime(o.cursorTime), nil
+	case "step-interval":
+		return dql.WithStepInterval(o.stepInterval), nil
+	case "limit":
+		return dql.WithLimit(o.limit), nil
+
+	case "param":
+		params := map[string]any{}
+		for _, p := range o.params {
+			k, v, ok := strings.Cut(p, "=")
+			if !ok || k == "" {
+				return nil, fmt.Errorf("expect name=value, got %q", p)
+			}
+			params[k] = paramValue(v)
+		}
+		return dql.WithParams(params), nil
+	case "arg":
+		args := make([]any, 0, len(o.args))
+		for _, a := range o.args {
+			args = append(args, paramValue(a))
+		}
+		return dql.WithArgs(args...), nil
+	}
+
+	return nil, nil // not DQL option
+}
+
+func orderByOptions(list []string, with func(string, dql.OrderByOrder) dql.DQLOption) ([]dql.DQLOption, error) {
+	var opts []dql.DQLOption
+	for _, s := range list {
+		field, order, _ := strings.Cut(s, ":")
+		if field == "" {
+			return nil, errors.New("empty order-by field")
+		}
+
+		switch strings.ToLower(order) {
+		case "", "asc":
+			opts = append(opts, with(field, dql.ASC))
+		case "desc":
+			opts = append(opts, with(field, dql.DESC))
+		default:
+			return nil, fmt.Errorf("invalid order %q, expect asc or desc", order)
+		}
+	}
+
+	return opts, nil
+}
+
+// pair split s into start,end.
+func pair(s string) (string, string, error) {
+	start, end, ok := strings.Cut(s, ",")
+	if !ok {
+		return "", "", fmt.Errorf("expect start,end, got %q", s)
+	}
+	return strings.TrimSpace(start), strings.TrimSpace(end), nil
+}
+
+// paramValue parse parameter value in JSON(number, bool, null, string
+// or array), and non-JSON value used as string. Integers are kept as
+// int64.
+func paramValue(s string) any {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
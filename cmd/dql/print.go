@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GuanceCloud/dql-go"
+)
+
+// A printer print the result of queries.
+type printer interface {
+	print(w io.Writer, queries []string, res *dql.Result) error
+}
+
+func newPrinter(format string) (printer, error) {
+	switch format {
+	case "table":
+		return tablePrinter{}, nil
+	case "json":
+		return jsonPrinter{}, nil
+	}
+
+	of, err := dql.ParseOutputFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("unknown print format %q", format)
+	}
+	return exportPrinter{of: of}, nil
+}
+
+// jsonPrinter print the whole result in indented JSON.
+type jsonPrinter struct{}
+
+func (jsonPrinter) print(w io.Writer, _ []string, res *dql.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// exportPrinter print each result by DQLResult.Export.
+type exportPrinter struct {
+	of dql.OutputFormat
+}
+
+func (p exportPrinter) print(w io.Writer, _ []string, res *dql.Result) error {
+	for _, r := range res.Content {
+		if err := r.Export(w, p.of); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tablePrinter print each result as aligned table.
+type tablePrinter struct{}
+
+func (tablePrinter) print(w io.Writer, queries []string, res *dql.Result) error {
+	for i, r := range res.Content {
+		if i > 0 {
+			fmt.Fprintln(w) //nolint:errcheck
+		}
+
+		if len(res.Content) > 1 && i < len(queries) {
+			fmt.Fprintf(w, "# %s\n", strings.ReplaceAll(queries[i], "\n", " ")) //nolint:errcheck
+		}
+
+		if r.RawQuery != "" {
+			fmt.Fprintf(w, "# explain: %s\n", strings.ReplaceAll(r.RawQuery, "\n", " ")) //nolint:errcheck
+		}
+
+		if r.QueryWarning != "" {
+			fmt.Fprintf(w, "# warning: %s\n", r.QueryWarning) //nolint:errcheck
+		}
+
+		if err := printTable(w, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printTable(w io.Writer, r *dql.DQLResult) error {
+	f, err := r.Frame()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	cols := f.Columns()
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		names = append(names, c.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(names, "\t")) //nolint:errcheck
+
+	vals := make([]string, len(cols))
+	for i := 0; i < f.Len(); i++ {
+		for j, c := range cols {
+			vals[j] = strings.ReplaceAll(c.StringValue(i), "\n", `\n`)
+		}
+		fmt.Fprintln(tw, strings.Join(vals, "\t")) //nolint:errcheck
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "(%d rows)\n", f.Len())
+	return err
+}
@@ -0,0 +1,379 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+)
+
+const replHelp = `Statements are ended by ';', and may span multiple lines.
+
+Meta-commands:
+  \help, \?               show this help
+  \quit, \q               quit the shell(or Ctrl-D)
+  \set [flag [value]]     set flag, such as \set limit 10, flags set listed if no flag
+  \highlight [on|off]     toggle highlight of matched keywords
+  \explain [on|off]       toggle echo explain of the query
+  \format [format]        set print format: table, json, csv, ndjson or lineprotocol
+  \pager [on|off|lines]   toggle paging of long output, or set lines per page
+  \history                show history
+  \ns                     list namespaces
+
+Keys: Tab to complete, Up/Down for history, Ctrl-C to cancel the input.
+`
+
+// showTimeout is the timeout of SHOW functions for completion.
+const showTimeout = 5 * time.Second
+
+// repl is the interactive shell.
+type repl struct {
+	cfg *config
+	fs  *flag.FlagSet
+	c   *dql.Client
+
+	ed     *editor
+	comp   *completer
+	stdout io.Writer
+	stderr io.Writer
+
+	// pending is the input of unterminated statement.
+	pending string
+}
+
+func newREPL(cfg *config, fs *flag.FlagSet, stdin io.Reader, stdout, stderr io.Writer) *repl {
+	r := &repl{
+		cfg:    cfg,
+		fs:     fs,
+		c:      dql.NewClient(cfg.host),
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	fd, tty := terminal(stdin)
+	r.comp = &completer{show: r.show}
+	r.ed = &editor{
+		in:      bufio.NewReader(stdin),
+		out:     stdout,
+		fd:      fd,
+		raw:     tty,
+		history: loadHistory(cfg.history),
+		complete: func(line string) ([]string, int) {
+			return r.comp.complete(r.pending + line)
+		},
+	}
+
+	return r
+}
+
+func (r *repl) run(ctx context.Context) error {
+	fmt.Fprintln(r.stdout, `Type \help for help, statements are ended by ';'.`) //nolint:errcheck
+
+	for {
+		prompt := "dql> "
+		if r.pending != "" {
+			prompt = "  -> "
+		}
+
+		line, err := r.ed.readLine(prompt)
+		switch {
+		case errors.Is(err, errInterrupt):
+			r.pending = ""
+			continue
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if r.pending == "" {
+			switch s := strings.TrimSpace(line); {
+			case s == "":
+				continue
+			case s == "exit", s == "quit":
+				return nil
+			case strings.HasPrefix(s, `\`):
+				r.addHistory(s)
+				if r.meta(s) {
+					return nil
+				}
+				continue
+			}
+		}
+
+		r.pending += line + "\n"
+		if !terminated(r.pending) {
+			continue
+		}
+
+		texts := splitQueries(r.pending)
+		r.addHistory(r.pending)
+		r.pending = ""
+
+		if len(texts) > 0 {
+			r.exec(ctx, texts)
+		}
+	}
+}
+
+func (r *repl) errorf(format string, args ...any) {
+	fmt.Fprintf(r.stderr, "error: "+format+"\n", args...) //nolint:errcheck
+}
+
+// addHistory add s to history, the history file disabled on error.
+func (r *repl) addHistory(s string) {
+	if err := r.ed.history.add(s); err != nil {
+		r.errorf("save history: %s, history file disabled", err)
+		r.ed.history.file = ""
+	}
+}
+
+// exec run queries, Ctrl-C cancel the running queries.
+func (r *repl) exec(ctx context.Context, texts []string) {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	defer cancel()
+
+	var buf bytes.Buffer
+	if err := execute(ctx, r.cfg, r.c, texts, &buf); err != nil {
+		r.errorf("%s", err)
+		return
+	}
+
+	r.page(buf.String())
+}
+
+// page print s page by page.
+func (r *repl) page(s string) {
+	size := r.cfg.pageSize
+	if size == 0 {
+		size = termRows(r.stdout)
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// keep a line for the prompt
+	if size--; size <= 0 || len(lines) <= size {
+		io.WriteString(r.stdout, s) //nolint:errcheck,gosec
+		return
+	}
+
+	for i := 0; i < len(lines); i += size {
+		end := i + size
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		io.WriteString(r.stdout, strings.Join(lines[i:end], "")) //nolint:errcheck,gosec
+		if end == len(lines) {
+			return
+		}
+
+		key, err := r.ed.readKey(fmt.Sprintf("-- %d/%d lines, Enter for next page, q to quit --", end, len(lines)))
+		if err != nil || key == 'q' || key == 'Q' || key == ctrl('C') {
+			return
+		}
+	}
+}
+
+// show run SHOW function q, and get values of the first non-time column.
+func (r *repl) show(q string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), showTimeout)
+	defer cancel()
+
+	query, err := dql.BuildDQL(q)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.c.QueryContext(ctx, append(queryOptions(r.cfg), dql.WithQueries(query))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var arr []string
+	for _, dr := range res.Content {
+		for _, row := range dr.Series {
+			col := 0
+			if len(row.Columns) > 1 && row.Columns[0] == "time" {
+				col = 1
+			}
+
+			for _, v := range row.Values {
+				if col < len(v) && v[col] != nil {
+					arr = append(arr, fmt.Sprint(v[col]))
+				}
+			}
+		}
+	}
+
+	return arr, nil
+}
+
+// meta run meta-command, it returns true to quit the shell.
+func (r *repl) meta(line string) bool {
+	fields := strings.Fields(line)
+	cmd, args := strings.TrimPrefix(fields[0], `\`), fields[1:]
+
+	var err error
+	switch cmd {
+	case "q", "quit":
+		return true
+	case "h", "help", "?":
+		fmt.Fprint(r.stdout, replHelp) //nolint:errcheck
+	case "set":
+		err = r.set(args)
+	case "highlight":
+		err = r.toggle("highlight", args)
+	case "explain":
+		err = r.toggle("echo-explain", args)
+	case "format":
+		err = r.set(append([]string{"format"}, args...))
+	case "pager":
+		err = r.pager(args)
+	case "history":
+		for i, h := range r.ed.history.entries {
+			fmt.Fprintf(r.stdout, "%5d  %s\n", i+1, strings.ReplaceAll(h, "\n", " ")) //nolint:errcheck
+		}
+	case "ns":
+		for _, ns := range dql.Namespaces() {
+			fmt.Fprintf(r.stdout, "%-4s%s\n", ns, ns.Info().Name) //nolint:errcheck
+		}
+	default:
+		err = fmt.Errorf("unknown command \\%s, see \\help", cmd)
+	}
+
+	if err != nil {
+		r.errorf("%s", err)
+	}
+	return false
+}
+
+// set set flag args[0] to the rest of args, flags set are listed if no
+// args, and value of the flag shown if no value.
+func (r *repl) set(args []string) error {
+	if len(args) == 0 {
+		r.fs.Visit(r.printFlag)
+		return nil
+	}
+
+	name := strings.TrimPrefix(args[0], "-")
+	f := r.fs.Lookup(name)
+	switch {
+	case f == nil:
+		return fmt.Errorf("unknown flag -%s", name)
+	case name == "f" || name == "i" || name == "history":
+		return fmt.Errorf("flag -%s not available within shell", name)
+	case len(args) == 1:
+		r.printFlag(f)
+		return nil
+	}
+
+	value := strings.Join(args[1:], " ")
+	if name == "format" {
+		if _, err := newPrinter(value); err != nil {
+			return err
+		}
+	}
+
+	if err := r.fs.Set(name, value); err != nil {
+		return err
+	}
+
+	switch name {
+	case "host":
+		r.c = dql.NewClient(r.cfg.host)
+		r.comp.reset()
+	case "token", "https":
+		r.comp.reset()
+	}
+
+	// options checked on set, instead of on each query
+	_, err := r.cfg.opts.options()
+	return err
+}
+
+// printFlag print flag f, token masked.
+func (r *repl) printFlag(f *flag.Flag) {
+	v := f.Value.String()
+	if f.Name == "token" && v != "" {
+		v = "****"
+	}
+	fmt.Fprintf(r.stdout, "-%s=%s\n", f.Name, v) //nolint:errcheck
+}
+
+// toggle switch bool flag name, or set it by on/off.
+func (r *repl) toggle(name string, args []string) error {
+	on, err := onOff(r.fs.Lookup(name).Value.String() != "true", args)
+	if err != nil {
+		return err
+	}
+
+	if err := r.fs.Set(name, strconv.FormatBool(on)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(r.stdout, "%s: %s\n", name, onOffString(on)) //nolint:errcheck
+	return nil
+}
+
+// pager toggle paging, or set lines per page.
+func (r *repl) pager(args []string) error {
+	if len(args) == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+			r.cfg.pageSize = n
+			fmt.Fprintf(r.stdout, "pager: %d lines\n", n) //nolint:errcheck
+			return nil
+		}
+	}
+
+	on, err := onOff(r.cfg.pageSize < 0, args)
+	if err != nil {
+		return err
+	}
+
+	if r.cfg.pageSize = -1; on {
+		r.cfg.pageSize = 0
+	}
+	fmt.Fprintf(r.stdout, "pager: %s\n", onOffString(on)) //nolint:errcheck
+	return nil
+}
+
+// onOff parse on/off of args, toggled used if no args.
+func onOff(toggled bool, args []string) (bool, error) {
+	if len(args) == 0 {
+		return toggled, nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expect on or off, got %q", args[0])
+}
+
+func onOffString(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,189 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestREPL(t *T.T) {
+	ts, received := server(t)
+	defer ts.Close()
+
+	hist := filepath.Join(t.TempDir(), "history")
+
+	input := strings.Join([]string{
+		`\highlight`,
+		`\explain on`,
+		"M::cpu",
+		"  LIMIT 1;",
+		"", // next page
+		`\set limit 5`,
+		`\set nope 1`,
+		`\format xml`,
+		`\pager off`,
+		"M::a; M::b;",
+		"M::c {",
+		`\q`, // within statement, not a meta-command
+		"};",
+		`\q`,
+		"M::never;",
+	}, "\n")
+
+	code, out, errOut := runDQL(t, input, "-i", "-host", ts.Listener.Addr().String(),
+		"-history", hist, "-page-size", "4")
+	require.Equal(t, exitOK, code, errOut)
+
+	assert.Contains(t, out, "highlight: on\n")
+	assert.Contains(t, out, "echo-explain: on\n")
+	assert.Contains(t, out, "-- 3/4 lines, Enter for next page, q to quit --")
+	assert.Contains(t, out, "pager: off\n")
+	assert.Contains(t, errOut, "error: unknown flag -nope\n")
+	assert.Contains(t, errOut, `error: unknown print format "xml"`)
+
+	all := received()
+	require.Len(t, all, 3)
+
+	q := all[0].Queries[0]
+	assert.Equal(t, "M::cpu\n  LIMIT 1", q["query"])
+	assert.Equal(t, true, q["highlight"])
+
+	assert.Len(t, all[1].Queries, 2)
+	assert.Equal(t, 5.0, all[1].Queries[0]["limit"])
+	assert.Equal(t, "M::c {\n\\q\n}", all[2].Queries[0]["query"])
+
+	// history persisted and reloaded
+	h := loadHistory(hist)
+	assert.Equal(t, []string{
+		`\highlight`,
+		`\explain on`,
+		"M::cpu\n  LIMIT 1;",
+		`\set limit 5`,
+		`\set nope 1`,
+		`\format xml`,
+		`\pager off`,
+		"M::a; M::b;",
+		"M::c {\n\\q\n};",
+		`\q`,
+	}, h.entries)
+
+	b, err := os.ReadFile(hist)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"M::cpu\n  LIMIT 1;"`+"\n")
+}
+
+func TestEditor(t *T.T) {
+	comp := &completer{show: func(q string) ([]string, error) {
+		switch q {
+		case "show_field_key(from=['cpu'])":
+			return []string{"usage", "used"}, nil
+		case "show_tag_key(from=['cpu'])":
+			return []string{"host"}, nil
+		}
+		return nil, errors.New("unknown")
+	}}
+
+	edit := func(keys string) (string, string, error) {
+		var out bytes.Buffer
+		e := &editor{
+			in:       bufio.NewReader(strings.NewReader(keys)),
+			out:      &out,
+			history:  &history{entries: []string{"M::a;", "M::b;"}},
+			complete: comp.complete,
+		}
+		line, err := e.edit("> ")
+		return line, out.String(), err
+	}
+
+	cases := []struct {
+		keys, line string
+	}{
+		{"abc\x1b[D\x1b[DX\r", "aXbc"},
+		{"abc\x7f\r", "ab"},
+		{"abc\x01X\x05Y\r", "XabcY"},
+		{"abc\x1b[D\x1b[3~\r", "ab"},
+		{"foo bar\x17\r", "foo "},
+		{"foo bar\x02\x02\x0b\r", "foo b"},
+		{"\x1b[A\r", "M::b;"},
+		{"\x1b[A\x1b[A\x1b[A\r", "M::a;"},
+		{"x\x1b[A\x1b[B\r", "x"},
+		{"M::cpu { ho\t\r", "M::cpu { host"},
+		{"M::cpu { us\ta\t\r", "M::cpu { usage"},
+		{"M::cpu li\t\r", "M::cpu limit"},
+		{"me\t\r", "metric::"},
+		{"中文\x7f\r", "中"},
+	}
+
+	for _, tc := range cases {
+		line, _, err := edit(tc.keys)
+		require.NoError(t, err, "%q", tc.keys)
+		assert.Equal(t, tc.line, line, "%q", tc.keys)
+	}
+
+	_, out, _ := edit("M::cpu { us\t\r")
+	assert.Contains(t, out, "\r\nusage  used\r\n")
+
+	_, _, err := edit("abc\x03")
+	assert.ErrorIs(t, err, errInterrupt)
+
+	_, _, err = edit("\x04")
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestComplete(t *T.T) {
+	var calls []string
+	c := &completer{show: func(q string) ([]string, error) {
+		calls = append(calls, q)
+		switch q {
+		case "show_measurement()":
+			return []string{"cpu", "cache", "mem"}, nil
+		case "show_logging_source()":
+			return []string{"nginx"}, nil
+		}
+		return nil, errors.New("unknown")
+	}}
+
+	cands, n := c.complete("M::c")
+	assert.Equal(t, []string{"cache", "cpu"}, cands)
+	assert.Equal(t, 1, n)
+
+	cands, n = c.complete("M::cpu;\nlogging::")
+	assert.Equal(t, []string{"nginx"}, cands)
+	assert.Equal(t, 0, n)
+
+	cands, _ = c.complete("  l")
+	assert.Equal(t, []string{"L::", "logging::"}, cands)
+
+	cands, _ = c.complete("L::nginx OR")
+	assert.Equal(t, []string{"OR", "ORDER"}, cands)
+
+	cands, _ = c.complete("M::`x")
+	assert.Empty(t, cands)
+
+	// failures cached too
+	c.complete("M::m")
+	c.complete("L::nginx x")
+	c.complete("L::nginx x")
+	assert.Equal(t, []string{
+		"show_measurement()",
+		"show_logging_source()",
+		"show_logging_field('nginx')",
+	}, calls)
+
+	c.reset()
+	c.complete("L::nginx x")
+	assert.Len(t, calls, 4)
+}
@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "errors"
+
+// terminal not supported, the interactive shell read input line by line.
+func terminal(any) (int, bool) { return 0, false }
+
+func makeRaw(int) (func(), error) { return nil, errors.New("raw terminal not supported") }
+
+func termRows(any) int { return 0 }
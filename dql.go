@@ -6,6 +6,8 @@
 // Package dql wraps DQL query SDK.
 package dql
 
+import "time"
+
 type QueryRule struct {
 	Rule  string   `json:"rule"`
 	Index []string `json:"indexes"`
@@ -28,7 +30,7 @@ type DorisIndices struct {
 //	https://confluence.jiagouyun.com/pages/viewpage.action?pageId=196018193
 type dql struct {
 	SearchAfter []any                 `json:"search_after"`
-	TimeRange   []int                 `json:"time_range,omitempty"`
+	TimeRange   []int64               `json:"time_range,omitempty"`
 	OrderBy     []orderBy             `json:"orderby,omitempty"`
 	NOrderBy    []map[string]string   `json:"order_by"`  // the newer order-by
 	NSOrderBy   []map[string]string   `json:"sorder_by"` // the newer sorder-by
@@ -67,4 +69,10 @@ type dql struct {
 	DisableSampling    bool `json:"disable_sampling,omitempty"`
 	AlignTime          bool `json:"align_time"`
 	DisallowLargeQuery bool `json:"disallow_large_query"`
+
+	validate bool          // client-side validation within BuildDQL
+	err      error         // error during applying options
+	window   *timeWindow   // set by WithTimeWindow or WithLast
+	align    time.Duration // set by WithAlignedWindow
+	instant  bool          // PromQL instant query, see BuildInstantPromQL
 }
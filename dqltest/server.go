@@ -0,0 +1,462 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package dqltest provides an in-process fake Datakit for testing DQL
+// queries without a live Datakit or openway. For example:
+//
+//	srv := dqltest.NewServer()
+//	defer srv.Close()
+//
+//	srv.Handle(`^M::cpu`, &dqltest.Response{Result: &dql.DQLResult{...}})
+//
+//	c := srv.Client()
+//	res, err := c.Query(dql.WithQueries(dql.MustBuildDQL("M::cpu LIMIT 1")))
+//	...
+//	q := srv.Queries()[0] // the query received
+//
+// The server implements POST /v1/query/raw, and simulates async queries,
+// paging(limit, offset, slimit, soffset and search_after), errors and
+// latency.
+package dqltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+)
+
+// A Query is a DQL query within the request.
+type Query struct {
+	DQL         string  `json:"query"`
+	QType       string  `json:"qtype"`
+	TimeRange   []int64 `json:"time_range"`
+	Limit       int64   `json:"limit"`
+	Offset      int64   `json:"offset"`
+	SLimit      int64   `json:"slimit"`
+	SOffset     int64   `json:"soffset"`
+	SearchAfter []any   `json:"search_after"`
+	AsyncID     string  `json:"async_id"`
+	IsAsync     bool    `json:"is_async"`
+	Timeout     string  `json:"search_timeout"`
+
+	// Raw is all fields of the query, such as raw["highlight"].
+	Raw map[string]any `json:"-"`
+}
+
+// A Request is a query request received.
+type Request struct {
+	Token       string
+	EchoExplain bool
+	Queries     []*Query
+
+	Header http.Header
+	Body   []byte
+}
+
+// A Response is the scripted response of a query.
+type Response struct {
+	// Result of the query, the series paged by limit, offset, slimit,
+	// soffset and search_after of the query. The search_after within the
+	// response is the number of rows fetched, and total hits set if not
+	// specified.
+	Result *dql.DQLResult
+
+	// Status, ErrorCode and Message of the error response, Status default
+	// to 400. As Datakit does, the whole request failed if any query
+	// within it failed.
+	Status    int
+	ErrorCode string
+	Message   string
+
+	// Latency delay the response.
+	Latency time.Duration
+
+	// Polls is the number of polls to get the result of async query,
+	// the query is running on submit and earlier polls.
+	Polls int
+}
+
+func (r *Response) failed() bool {
+	return r.ErrorCode != "" || r.Status >= http.StatusBadRequest
+}
+
+// ParseError get the response of DQL parse error.
+func ParseError(msg string) *Response {
+	return &Response{Status: http.StatusBadRequest, ErrorCode: "query.parse_error", Message: msg}
+}
+
+// TokenInvalid get the response of invalid token.
+func TokenInvalid() *Response {
+	return &Response{Status: http.StatusUnauthorized, ErrorCode: "kodo.tokenNotFound", Message: "token not found"}
+}
+
+// Timeout get the response of query timeout.
+func Timeout() *Response {
+	return &Response{Status: http.StatusGatewayTimeout, ErrorCode: "query.timeout", Message: "query timeout"}
+}
+
+// MaxDuration get the response of time range exceeded.
+func MaxDuration() *Response {
+	return &Response{Status: http.StatusBadRequest, ErrorCode: "query.max_duration", Message: "time range should less than max duration"}
+}
+
+// A HandlerFunc get the response of query q.
+type HandlerFunc func(q *Query) *Response
+
+type handler struct {
+	re *regexp.Regexp
+	fn HandlerFunc
+}
+
+// asyncTask is a running async query.
+type asyncTask struct {
+	q     *Query
+	resp  *Response
+	polls int
+}
+
+// A Server is a fake Datakit.
+type Server struct {
+	*httptest.Server
+
+	token   string
+	latency time.Duration
+
+	mu       sync.Mutex
+	handlers []*handler
+	requests []*Request
+	asyncs   map[string]*asyncTask
+	asyncSeq int
+}
+
+// ServerOption used to configure the server.
+type ServerOption func(*Server)
+
+// WithToken set the token required, requests with other token responded
+// with TokenInvalid.
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithLatency delay each response.
+func WithLatency(du time.Duration) ServerOption {
+	return func(s *Server) {
+		s.latency = du
+	}
+}
+
+// NewServer create and start a fake Datakit, it should be closed by Close.
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{asyncs: map[string]*asyncTask{}}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/query/raw", s.serveQuery)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Host get the IP:Port of the server.
+func (s *Server) Host() string {
+	return s.Listener.Addr().String()
+}
+
+// Client create a DQL client connecting to the server.
+func (s *Server) Client(opts ...dql.ClientOption) *dql.Client {
+	return dql.NewClient(s.Host(), opts...)
+}
+
+// Handle set the response of queries matching pattern, the pattern is
+// a regular expression on the DQL. Handlers are matched in the order
+// added, and queries matching none of them responded with error
+// dqltest.unmatched.
+func (s *Server) Handle(pattern string, resp *Response) {
+	s.HandleFunc(pattern, func(*Query) *Response { return resp })
+}
+
+// HandleFunc is the same as Handle, but the response got by fn.
+func (s *Server) HandleFunc(pattern string, fn HandlerFunc) {
+	re := regexp.MustCompile(pattern)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, &handler{re: re, fn: fn})
+}
+
+// Requests get requests received.
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// Queries get queries of all requests received.
+func (s *Server) Queries() []*Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var arr []*Query
+	for _, r := range s.requests {
+		arr = append(arr, r.Queries...)
+	}
+	return arr
+}
+
+// Reset clear requests received and running async queries, handlers
+// are kept.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.asyncs = map[string]*asyncTask{}
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResult(w, http.StatusMethodNotAllowed, &dql.Result{ErrorCode: "dqltest.method_not_allowed", Message: r.Method})
+		return
+	}
+
+	req, err := decodeRequest(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, &dql.Result{ErrorCode: "dqltest.bad_request", Message: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if s.token != "" && req.Token != s.token {
+		resp := TokenInvalid()
+		writeResult(w, resp.Status, &dql.Result{ErrorCode: resp.ErrorCode, Message: resp.Message})
+		return
+	}
+
+	latency := s.latency
+	status, res := http.StatusOK, &dql.Result{}
+
+	for _, q := range req.Queries {
+		resp, dr := s.respond(q)
+		latency += resp.Latency
+
+		if resp.failed() {
+			if status = resp.Status; status == 0 {
+				status = http.StatusBadRequest
+			}
+			res = &dql.Result{ErrorCode: resp.ErrorCode, Message: resp.Message}
+			break
+		}
+
+		res.Content = append(res.Content, dr)
+	}
+
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		defer t.Stop()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+		}
+	}
+
+	writeResult(w, status, res)
+}
+
+// respond get the response of q, and the result for succeeded response.
+func (s *Server) respond(q *Query) (*Response, *dql.DQLResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q.AsyncID != "" {
+		task, ok := s.asyncs[q.AsyncID]
+		if !ok {
+			return &Response{
+				Status:    http.StatusNotFound,
+				ErrorCode: "query.async_not_found",
+				Message:   fmt.Sprintf("async query %s not found", q.AsyncID),
+			}, nil
+		}
+
+		if task.polls++; task.polls < task.resp.Polls {
+			return task.resp, &dql.DQLResult{AsyncID: q.AsyncID, IsRunning: true}
+		}
+
+		delete(s.asyncs, q.AsyncID)
+		dr := page(task.resp.Result, task.q)
+		dr.AsyncID, dr.Complete = q.AsyncID, true
+		return task.resp, dr
+	}
+
+	var resp *Response
+	for _, h := range s.handlers {
+		if h.re.MatchString(q.DQL) {
+			resp = h.fn(q)
+			break
+		}
+	}
+
+	switch {
+	case resp == nil:
+		return &Response{
+			Status:    http.StatusNotFound,
+			ErrorCode: "dqltest.unmatched",
+			Message:   fmt.Sprintf("no handler for %q", q.DQL),
+		}, nil
+	case resp.failed():
+		return resp, nil
+	case q.IsAsync && resp.Polls > 0:
+		s.asyncSeq++
+		id := fmt.Sprintf("dqltest-async-%d", s.asyncSeq)
+		s.asyncs[id] = &asyncTask{q: q, resp: resp}
+		return resp, &dql.DQLResult{AsyncID: id, IsRunning: true}
+	}
+
+	dr := page(resp.Result, q)
+	dr.Complete = q.IsAsync
+	return resp, dr
+}
+
+// page get the page of res requested by q. res not changed.
+func page(res *dql.DQLResult, q *Query) *dql.DQLResult {
+	if res == nil {
+		return &dql.DQLResult{}
+	}
+
+	dr := *res
+	series := window(res.Series, q.SOffset, q.SLimit)
+
+	var total int64
+	for _, s := range series {
+		total += int64(len(s.Values))
+	}
+
+	start := q.Offset
+	if len(q.SearchAfter) > 0 {
+		start += toInt(q.SearchAfter[0])
+	}
+
+	end := total
+	if q.Limit > 0 && start+q.Limit < total {
+		end = start + q.Limit
+	}
+
+	dr.Series = nil
+	var i int64
+	for _, s := range series {
+		n := int64(len(s.Values))
+		if values := window(s.Values, start-i, end-start); i+n > start && i < end && len(values) > 0 {
+			row := *s
+			row.Values = values
+			dr.Series = append(dr.Series, &row)
+		}
+		i += n
+	}
+
+	if dr.Totalhits == 0 {
+		dr.Totalhits = total
+	}
+
+	if q.SearchAfter != nil && q.Limit > 0 {
+		if dr.SearchAfter = nil; end < total {
+			dr.SearchAfter = []any{end - q.Offset}
+		}
+	}
+
+	return &dr
+}
+
+// window get arr[offset:offset+limit], limit <= 0 for no limit.
+func window[T any](arr []T, offset, limit int64) []T {
+	if offset < 0 {
+		limit += offset
+		offset = 0
+	}
+
+	if offset >= int64(len(arr)) {
+		return nil
+	}
+
+	arr = arr[offset:]
+	if limit > 0 && limit < int64(len(arr)) {
+		arr = arr[:limit]
+	}
+	return arr
+}
+
+func toInt(v any) int64 {
+	switch x := v.(type) {
+	case float64:
+		return int64(x)
+	case int64:
+		return x
+	case json.Number:
+		n, _ := x.Int64()
+		return n
+	}
+	return 0
+}
+
+func decodeRequest(r *http.Request) (*Request, error) {
+	req := &Request{
+		Token:  r.URL.Query().Get("token"),
+		Header: r.Header.Clone(),
+	}
+
+	var err error
+	if req.Body, err = io.ReadAll(r.Body); err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		EchoExplain bool              `json:"echo_explain"`
+		Token       string            `json:"token"`
+		Queries     []json.RawMessage `json:"queries"`
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		return nil, err
+	}
+
+	if req.Token == "" {
+		req.Token = body.Token
+	}
+	req.EchoExplain = body.EchoExplain
+
+	for _, raw := range body.Queries {
+		q := &Query{}
+		if err := json.Unmarshal(raw, q); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &q.Raw); err != nil {
+			return nil, err
+		}
+		req.Queries = append(req.Queries, q)
+	}
+
+	return req, nil
+}
+
+func writeResult(w http.ResponseWriter, status int, res *dql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res) //nolint:errcheck,gosec
+}
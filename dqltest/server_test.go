@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dqltest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rows(name string, n int) *dql.Row {
+	r := &dql.Row{Name: name, Columns: []string{"time", "message"}}
+	for i := 0; i < n; i++ {
+		r.Values = append(r.Values, []any{float64(i * 1000), name})
+	}
+	return r
+}
+
+func TestServer(t *T.T) {
+	t.Run("query", func(t *T.T) {
+		srv := NewServer()
+		defer srv.Close()
+
+		srv.Handle(`^M::cpu`, &Response{Result: &dql.DQLResult{Series: []*dql.Row{rows("cpu", 2)}}})
+		srv.HandleFunc(`^M::`, func(q *Query) *Response {
+			return &Response{Result: &dql.DQLResult{Series: []*dql.Row{rows(strings.TrimPrefix(q.DQL, "M::"), 1)}}}
+		})
+
+		c := srv.Client()
+		res, err := c.Query(dql.WithToken("tkn_xx"), dql.WithEchoExplain(true), dql.WithQueries(
+			dql.MustBuildDQL("M::cpu", dql.WithHighlight(true)),
+			dql.MustBuildDQL("M::mem"),
+		))
+		require.NoError(t, err)
+		require.Len(t, res.Content, 2)
+		assert.Len(t, res.Content[0].Series[0].Values, 2)
+		assert.Equal(t, "mem", res.Content[1].Series[0].Name)
+
+		reqs := srv.Requests()
+		require.Len(t, reqs, 1)
+		assert.Equal(t, "tkn_xx", reqs[0].Token)
+		assert.True(t, reqs[0].EchoExplain)
+
+		qs := srv.Queries()
+		require.Len(t, qs, 2)
+		assert.Equal(t, "M::cpu", qs[0].DQL)
+		assert.Equal(t, true, qs[0].Raw["highlight"])
+
+		srv.Reset()
+		assert.Empty(t, srv.Requests())
+	})
+
+	t.Run("errors", func(t *T.T) {
+		srv := NewServer(WithToken("tkn_xx"))
+		defer srv.Close()
+
+		srv.Handle(`parse`, ParseError("unexpected token"))
+		srv.Handle(`timeout`, Timeout())
+		srv.Handle(`duration`, MaxDuration())
+		srv.Handle(`.`, &Response{Result: &dql.DQLResult{}})
+
+		c := srv.Client()
+		cases := []struct {
+			token, q string
+			err      error
+		}{
+			{"tkn_xx", "M::parse", dql.ErrParse},
+			{"tkn_xx", "M::timeout", dql.ErrTimeout},
+			{"tkn_xx", "M::duration", dql.ErrMaxDuration},
+			{"tkn_yy", "M::cpu", dql.ErrTokenInvalid},
+		}
+
+		for _, tc := range cases {
+			_, err := c.Query(dql.WithToken(tc.token), dql.WithQueryError(true),
+				dql.WithQueries(dql.MustBuildDQL("M::cpu"), dql.MustBuildDQL(tc.q)))
+			assert.ErrorIs(t, err, tc.err, tc.q)
+		}
+
+		// unmatched
+		srv2 := NewServer()
+		defer srv2.Close()
+
+		res, err := srv2.Client().Query(dql.WithQueries(dql.MustBuildDQL("M::cpu")))
+		require.NoError(t, err)
+		assert.Equal(t, "dqltest.unmatched", res.ErrorCode)
+
+		resp, err := http.Get(srv2.URL + "/v1/query/raw") //nolint:noctx
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("async", func(t *T.T) {
+		srv := NewServer()
+		defer srv.Close()
+
+		srv.Handle(`^L::`, &Response{Result: &dql.DQLResult{Series: []*dql.Row{rows("nginx", 3)}}, Polls: 2})
+
+		var partials int
+		res, err := srv.Client().QueryAsync(context.Background(), dql.MustBuildDQL("L::nginx"), &dql.PollPolicy{
+			Interval:  time.Millisecond,
+			OnPartial: func(*dql.DQLResult) { partials++ },
+		})
+		require.NoError(t, err)
+		assert.True(t, res.Complete)
+		assert.Len(t, res.Series[0].Values, 3)
+		assert.Equal(t, 2, partials)
+
+		qs := srv.Queries()
+		require.Len(t, qs, 3)
+		assert.True(t, qs[0].IsAsync)
+		assert.Equal(t, "dqltest-async-1", qs[1].AsyncID)
+
+		// polled after completed
+		res2, err := srv.Client().Query(dql.WithQueries(dql.MustBuildDQL("L::nginx", dql.WithAsyncID(res.AsyncID))))
+		require.NoError(t, err)
+		assert.Equal(t, "query.async_not_found", res2.ErrorCode)
+	})
+
+	t.Run("paging", func(t *T.T) {
+		srv := NewServer()
+		defer srv.Close()
+
+		srv.Handle(`.`, &Response{Result: &dql.DQLResult{Series: []*dql.Row{rows("a", 3), rows("b", 2), rows("c", 2)}}})
+
+		c := srv.Client()
+		p := c.Paginate(context.Background(), dql.MustBuildDQL("L::x"), dql.WithPageSize(3))
+
+		var names []string
+		for p.Next() {
+			names = append(names, p.Row().Name)
+		}
+		require.NoError(t, p.Err())
+		assert.Equal(t, []string{"a", "a", "a", "b", "b", "c", "c"}, names)
+		assert.Equal(t, 3, p.Pages())
+
+		var after [][]any
+		for _, q := range srv.Queries() {
+			after = append(after, q.SearchAfter)
+		}
+		assert.Equal(t, [][]any{{}, {3.0}, {6.0}}, after)
+
+		// offset and series window
+		res, err := c.Query(dql.WithQueries(dql.MustBuildDQL("L::x",
+			dql.WithSOffset(1), dql.WithSLimit(2), dql.WithOffset(1), dql.WithLimit(2))))
+		require.NoError(t, err)
+
+		dr := res.Content[0]
+		require.Len(t, dr.Series, 2)
+		assert.Equal(t, "b", dr.Series[0].Name)
+		assert.Len(t, dr.Series[0].Values, 1)
+		assert.Equal(t, "c", dr.Series[1].Name)
+		assert.Len(t, dr.Series[1].Values, 1)
+		assert.Equal(t, int64(4), dr.Totalhits)
+	})
+
+	t.Run("latency", func(t *T.T) {
+		srv := NewServer(WithLatency(time.Second))
+		defer srv.Close()
+
+		srv.Handle(`.`, &Response{Result: &dql.DQLResult{}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := srv.Client().QueryContext(ctx, dql.WithQueries(dql.MustBuildDQL("M::cpu")))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	})
+}
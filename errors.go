@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors for common query failures. A *QueryError matches them
+// with errors.Is(), for example:
+//
+//	if errors.Is(err, dql.ErrMaxDuration) {
+//		// shrink the time range and retry
+//	}
+var (
+	ErrParse        = errors.New("dql parse error")
+	ErrMaxDuration  = errors.New("dql max duration exceeded")
+	ErrTokenInvalid = errors.New("dql token invalid")
+	ErrTimeout      = errors.New("dql query timeout")
+)
+
+// maxErrorBody is the max bytes of non-JSON response body kept in QueryError.
+const maxErrorBody = 512
+
+// A QueryError is the error of a failed query request, derived from
+// HTTP status and Result.ErrorCode/Message.
+type QueryError struct {
+	// HTTP status code of the query response.
+	StatusCode int
+
+	// ErrorCode and Message from the Result. For non-JSON response,
+	// Message is the (truncated) response body.
+	ErrorCode string
+	Message   string
+
+	// Index of the offending DQL within the query, -1 if unknown.
+	Index int
+}
+
+// Error implements error interface.
+func (e *QueryError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("dql query failed")
+
+	var attrs []string
+	if e.StatusCode > 0 {
+		attrs = append(attrs, fmt.Sprintf("status %d", e.StatusCode))
+	}
+	if e.Index >= 0 {
+		attrs = append(attrs, fmt.Sprintf("index %d", e.Index))
+	}
+	if len(attrs) > 0 {
+		sb.WriteString("(" + strings.Join(attrs, ", ") + ")")
+	}
+
+	if e.ErrorCode != "" {
+		sb.WriteString(": " + e.ErrorCode)
+	}
+
+	if e.Message != "" {
+		sb.WriteString(": " + e.Message)
+	}
+
+	return sb.String()
+}
+
+// errorCodes are known error codes of sentinel errors, in lower case
+// without '_'. The namespace of the code(such as "kodo." in
+// kodo.tokenNotFound) is not compared.
+var errorCodes = map[error][]string{
+	ErrParse:        {"parseerror", "syntaxerror"},
+	ErrMaxDuration:  {"maxduration", "maxdurationexceeded", "timerangeexceeded"},
+	ErrTokenInvalid: {"tokennotfound", "tokeninvalid", "invalidtoken", "tokenexpired"},
+	ErrTimeout:      {"timeout", "querytimeout", "searchtimeout"},
+}
+
+// errorMessages are messages of sentinel errors, used if no error code
+// within the response, such as the response of a gateway. For
+// ErrMaxDuration, Datakit report it as a parse error with the message
+// "time range should less than ...", so the message is checked even
+// with an error code.
+var errorMessages = map[error][]string{
+	ErrParse:        {"parse error"},
+	ErrMaxDuration:  {"time range should less than"},
+	ErrTokenInvalid: {"token not found", "invalid token", "token invalid"},
+	ErrTimeout:      {"timeout", "timed out"},
+}
+
+// Is used to match the error against sentinel errors like ErrParse. The
+// error matched by the known error code and HTTP status, and if no error
+// code, by the message.
+func (e *QueryError) Is(target error) bool {
+	switch target { //nolint:errorlint
+	case ErrMaxDuration:
+		return e.hasCode(target) || e.hasMessage(target)
+	case ErrParse:
+		return e.hasCode(target) || (e.ErrorCode == "" && e.hasMessage(target))
+	case ErrTokenInvalid:
+		return e.StatusCode == http.StatusUnauthorized ||
+			e.StatusCode == http.StatusForbidden ||
+			e.hasCode(target) ||
+			(e.ErrorCode == "" && e.hasMessage(target))
+	case ErrTimeout:
+		return e.StatusCode == http.StatusRequestTimeout ||
+			e.StatusCode == http.StatusGatewayTimeout ||
+			e.hasCode(target) ||
+			(e.ErrorCode == "" && e.hasMessage(target))
+	default:
+		return false
+	}
+}
+
+// hasCode check if the error code is a known code of target.
+func (e *QueryError) hasCode(target error) bool {
+	code := e.ErrorCode
+	if i := strings.LastIndexByte(code, '.'); i >= 0 {
+		code = code[i+1:]
+	}
+	code = strings.ToLower(strings.ReplaceAll(code, "_", ""))
+
+	for _, c := range errorCodes[target] {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMessage check if the message contains any known message of target
+// (case-insensitive).
+func (e *QueryError) hasMessage(target error) bool {
+	msg := strings.ToLower(e.Message)
+	for _, m := range errorMessages[target] {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// newQueryError build error from response status and result. If r is nil,
+// body used as the error message.
+func newQueryError(q *query, status int, r *Result, body []byte) *QueryError {
+	e := &QueryError{
+		StatusCode: status,
+		Index:      -1,
+	}
+
+	if r != nil {
+		e.ErrorCode = r.ErrorCode
+		e.Message = r.Message
+	} else {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > maxErrorBody {
+			msg = msg[:maxErrorBody] + "..."
+		}
+		e.Message = msg
+	}
+
+	if e.ErrorCode == "" && e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+
+	// Result.ErrorCode do not tell us which DQL failed, but we known
+	// it if there is only one DQL. Non-JSON response(such as from a
+	// gateway) do not point to any DQL.
+	if r != nil && r.ErrorCode != "" && q != nil && len(q.Queries) == 1 {
+		e.Index = 0
+	}
+
+	return e
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryError(t *T.T) {
+	t.Run("sentinel", func(t *T.T) {
+		cases := []struct {
+			err    *QueryError
+			target error
+		}{
+			{&QueryError{ErrorCode: "dql.parse_error", Message: "unexpected token"}, ErrParse},
+			{&QueryError{Message: "parse error: time range should less than 1h0m0s"}, ErrMaxDuration},
+			{&QueryError{StatusCode: http.StatusUnauthorized}, ErrTokenInvalid},
+			{&QueryError{ErrorCode: "kodo.tokenNotFound"}, ErrTokenInvalid},
+			{&QueryError{ErrorCode: "query.timeout"}, ErrTimeout},
+			{&QueryError{StatusCode: http.StatusGatewayTimeout}, ErrTimeout},
+		}
+
+		for _, tc := range cases {
+			assert.True(t, errors.Is(tc.err, tc.target), "%s should be %s", tc.err, tc.target)
+		}
+
+		notCases := []struct {
+			err    *QueryError
+			target error
+		}{
+			{&QueryError{ErrorCode: "dql.parse_error"}, ErrTimeout},
+			{&QueryError{ErrorCode: "dql.parse_error", Message: "unexpected token"}, ErrTokenInvalid},
+			{&QueryError{ErrorCode: "query.internal_error", Message: "parse config failed"}, ErrParse},
+			{&QueryError{ErrorCode: "query.internal_error", Message: "field timeout not found"}, ErrTimeout},
+			{&QueryError{ErrorCode: "query.internal_error", Message: "bad token"}, ErrTokenInvalid},
+		}
+
+		for _, tc := range notCases {
+			assert.False(t, errors.Is(tc.err, tc.target), "%s should not be %s", tc.err, tc.target)
+		}
+
+		// message used if no error code
+		assert.True(t, errors.Is(&QueryError{Message: "upstream timed out"}, ErrTimeout))
+		assert.True(t, errors.Is(&QueryError{Message: "Parse error: unexpected token"}, ErrParse))
+		assert.True(t, errors.Is(&QueryError{Message: "token not found"}, ErrTokenInvalid))
+	})
+
+	t.Run("error-string", func(t *T.T) {
+		e := &QueryError{StatusCode: 400, ErrorCode: "some.code", Message: "some message", Index: 0}
+		assert.Equal(t, "dql query failed(status 400, index 0): some.code: some message", e.Error())
+
+		e = &QueryError{ErrorCode: "some.code", Index: -1}
+		assert.Equal(t, "dql query failed: some.code", e.Error())
+	})
+
+	t.Run("non-json-body", func(t *T.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>")) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String())
+
+		_, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+
+		var qe *QueryError
+		require.True(t, errors.As(err, &qe))
+		assert.Equal(t, http.StatusBadGateway, qe.StatusCode)
+		assert.Equal(t, "<html>bad gateway</html>", qe.Message)
+		assert.Equal(t, -1, qe.Index) // no DQL failed
+	})
+
+	t.Run("with-query-error", func(t *T.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error_code":"dql.parse_error","message":"parse error: time range should less than 1h0m0s"}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String())
+
+		// default: error within Result
+		r, err := c.Query(WithQueries(MustBuildDQL("L::nginx [30d:]", WithMaxDuration(time.Hour))))
+		require.NoError(t, err)
+		assert.Equal(t, "dql.parse_error", r.ErrorCode)
+
+		r, err = c.Query(WithQueryError(true),
+			WithQueries(MustBuildDQL("L::nginx [30d:]"), MustBuildDQL("M::cpu")))
+		require.Error(t, err)
+		assert.NotNil(t, r)
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+		assert.True(t, errors.Is(err, ErrParse))
+
+		var qe *QueryError
+		require.True(t, errors.As(err, &qe))
+		assert.Equal(t, -1, qe.Index)
+		assert.Equal(t, http.StatusBadRequest, qe.StatusCode)
+	})
+}
@@ -0,0 +1,394 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export convert the result into format of and write to w. Series within the
+// result are flattened into a table, each value of the series is a row, and
+// the columns are series name, tags and value columns. If there is no series
+// but lineprotocol points(see WithOutputFormat), the points are used.
+func (r *DQLResult) Export(w io.Writer, of OutputFormat) error {
+	if of == LineProtocol {
+		return r.exportLineProtocol(w)
+	}
+
+	t, err := r.table()
+	if err != nil {
+		return err
+	}
+
+	switch of {
+	case CSV:
+		return t.writeCSV(w)
+	case JSONLines:
+		return t.writeJSONLines(w)
+	case Arrow:
+		return t.writeArrow(w)
+	case LineProtocol: // handled above
+	}
+
+	return fmt.Errorf("unknown output format %d", of)
+}
+
+// column kinds within exportTable.
+const (
+	colName = iota
+	colTag
+	colValue
+)
+
+type exportColumn struct {
+	name string
+	kind int
+}
+
+// exportTable is the flattened result.
+type exportTable struct {
+	columns []*exportColumn
+	rows    [][]any
+}
+
+// table flatten series(or points) into table.
+func (r *DQLResult) table() (*exportTable, error) {
+	series := r.Series
+
+	if len(series) == 0 && len(r.Points) > 0 {
+		pts, err := r.DecodePoints(PrecisionNS)
+		if err != nil {
+			return nil, err
+		}
+		series = pointsToSeries(pts)
+	}
+
+	var (
+		valueCols []string
+		tagKeys   []string
+		hasName   bool
+		seen      = map[string]bool{}
+		tagSeen   = map[string]bool{}
+	)
+
+	for _, s := range series {
+		hasName = hasName || s.Name != ""
+
+		for _, col := range s.Columns {
+			if !seen[col] {
+				seen[col] = true
+				valueCols = append(valueCols, col)
+			}
+		}
+
+		for k := range s.Tags {
+			if !tagSeen[k] {
+				tagSeen[k] = true
+				tagKeys = append(tagKeys, k)
+			}
+		}
+	}
+
+	sort.Strings(tagKeys)
+
+	// value column > tag > series name on name conflict
+	t := &exportTable{}
+	if hasName && !seen["name"] && !tagSeen["name"] {
+		t.columns = append(t.columns, &exportColumn{name: "name", kind: colName})
+	}
+
+	for _, k := range tagKeys {
+		if !seen[k] {
+			t.columns = append(t.columns, &exportColumn{name: k, kind: colTag})
+		}
+	}
+
+	for _, col := range valueCols {
+		t.columns = append(t.columns, &exportColumn{name: col, kind: colValue})
+	}
+
+	for _, s := range series {
+		idx := make(map[string]int, len(s.Columns))
+		for i, col := range s.Columns {
+			idx[col] = i
+		}
+
+		for _, vals := range s.Values {
+			row := make([]any, len(t.columns))
+
+			for i, col := range t.columns {
+				switch col.kind {
+				case colName:
+					row[i] = s.Name
+				case colTag:
+					if v, ok := s.Tags[col.name]; ok {
+						row[i] = v
+					}
+				case colValue:
+					if j, ok := idx[col.name]; ok && j < len(vals) {
+						row[i] = vals[j]
+					}
+				}
+			}
+
+			t.rows = append(t.rows, row)
+		}
+	}
+
+	return t, nil
+}
+
+// pointsToSeries convert each point to a single-value series, the
+// time is UNIX timestamp in ms, same as series returned by the backend.
+func pointsToSeries(pts []*Point) []*Row {
+	var series []*Row
+
+	for _, pt := range pts {
+		keys := make([]string, 0, len(pt.Fields))
+		for k := range pt.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		s := &Row{
+			Name:    pt.Measurement,
+			Tags:    pt.Tags,
+			Columns: append([]string{"time"}, keys...),
+		}
+
+		vals := make([]any, 0, len(s.Columns))
+		if pt.Time.IsZero() {
+			vals = append(vals, nil)
+		} else {
+			vals = append(vals, float64(pt.Time.UnixMilli()))
+		}
+
+		for _, k := range keys {
+			vals = append(vals, pt.Fields[k])
+		}
+
+		s.Values = [][]any{vals}
+		series = append(series, s)
+	}
+
+	return series
+}
+
+// formatValue format JSON value into string, nil formatted as empty string.
+func formatValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	default:
+		j, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprintf("%v", x)
+		}
+		return string(j)
+	}
+}
+
+func (t *exportTable) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, 0, len(t.columns))
+	for _, col := range t.columns {
+		header = append(header, col.name)
+	}
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.columns))
+	for _, row := range t.rows {
+		for i, v := range row {
+			record[i] = formatValue(v)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (t *exportTable) writeJSONLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, row := range t.rows {
+		if err := bw.WriteByte('{'); err != nil {
+			return err
+		}
+
+		n := 0
+		for i, v := range row {
+			if v == nil {
+				continue
+			}
+
+			k, err := json.Marshal(t.columns[i].name)
+			if err != nil {
+				return err
+			}
+
+			val, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+
+			if n > 0 {
+				bw.WriteByte(',') //nolint:errcheck
+			}
+			n++
+
+			bw.Write(k)       //nolint:errcheck
+			bw.WriteByte(':') //nolint:errcheck
+			bw.Write(val)     //nolint:errcheck
+		}
+
+		bw.WriteString("}\n") //nolint:errcheck
+	}
+
+	return bw.Flush()
+}
+
+// exportLineProtocol write lineprotocol points, or convert series into
+// lineprotocol if no points within the result.
+func (r *DQLResult) exportLineProtocol(w io.Writer) error {
+	if len(r.Series) == 0 && len(r.Points) > 0 {
+		for _, pt := range r.Points {
+			data, err := base64.StdEncoding.DecodeString(pt)
+			if err != nil {
+				return err
+			}
+
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, s := range r.Series {
+		name := s.Name
+		if name == "" {
+			return fmt.Errorf("series without name can not convert to lineprotocol")
+		}
+
+		tagKeys := make([]string, 0, len(s.Tags))
+		for k := range s.Tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+
+		var prefix strings.Builder
+		prefix.WriteString(lpEscape(name, ", "))
+		for _, k := range tagKeys {
+			prefix.WriteString("," + lpEscape(k, ",= ") + "=" + lpEscape(s.Tags[k], ",= "))
+		}
+
+		for _, vals := range s.Values {
+			var (
+				fields []string
+				ts     string
+			)
+
+			for i, col := range s.Columns {
+				if i >= len(vals) || vals[i] == nil {
+					continue
+				}
+
+				if col == "time" {
+					if f, ok := vals[i].(float64); ok {
+						ts = strconv.FormatInt(int64(f)*1e6, 10) // ms to ns
+						continue
+					}
+				}
+
+				fields = append(fields, lpEscape(col, ",= ")+"="+lpFieldValue(vals[i]))
+			}
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			bw.WriteString(prefix.String() + " " + strings.Join(fields, ",")) //nolint:errcheck
+			if ts != "" {
+				bw.WriteString(" " + ts) //nolint:errcheck
+			}
+			bw.WriteByte('\n') //nolint:errcheck
+		}
+	}
+
+	return bw.Flush()
+}
+
+func lpEscape(s, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(chars, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+func lpFieldValue(v any) string {
+	switch x := v.(type) {
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return strconv.Quote(formatValue(x))
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(x, 10) + "i"
+	case uint64:
+		return strconv.FormatUint(x, 10) + "u"
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		s := formatValue(x)
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		return `"` + s + `"`
+	}
+}
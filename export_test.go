@@ -0,0 +1,233 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportResult(t *T.T) *DQLResult {
+	t.Helper()
+
+	var r DQLResult
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "series": [
+    {
+      "name": "cpu",
+      "tags": {"host": "h1"},
+      "columns": ["time", "usage", "cores", "message"],
+      "values": [[1680172008117, 1.5, 4, "a,b"], [1680172008118, null, 8, "c\"d"]]
+    },
+    {
+      "name": "cpu",
+      "tags": {"host": "h 2", "region": "cn"},
+      "columns": ["time", "usage", "ok"],
+      "values": [[1680172008119, 2, true]]
+    }
+  ]
+}`), &r))
+
+	return &r
+}
+
+func TestExport(t *T.T) {
+	t.Run("csv", func(t *T.T) {
+		var buf bytes.Buffer
+		require.NoError(t, exportResult(t).Export(&buf, CSV))
+
+		assert.Equal(t, `name,host,region,time,usage,cores,message,ok
+cpu,h1,,1680172008117,1.5,4,"a,b",
+cpu,h1,,1680172008118,,8,"c""d",
+cpu,h 2,cn,1680172008119,2,,,true
+`, buf.String())
+	})
+
+	t.Run("ndjson", func(t *T.T) {
+		var buf bytes.Buffer
+		require.NoError(t, exportResult(t).Export(&buf, JSONLines))
+
+		assert.Equal(t, `{"name":"cpu","host":"h1","time":1680172008117,"usage":1.5,"cores":4,"message":"a,b"}
+{"name":"cpu","host":"h1","time":1680172008118,"cores":8,"message":"c\"d"}
+{"name":"cpu","host":"h 2","region":"cn","time":1680172008119,"usage":2,"ok":true}
+`, buf.String())
+	})
+
+	t.Run("lineprotocol", func(t *T.T) {
+		var buf bytes.Buffer
+		require.NoError(t, exportResult(t).Export(&buf, LineProtocol))
+
+		assert.Equal(t, `cpu,host=h1 usage=1.5,cores=4,message="a,b" 1680172008117000000
+cpu,host=h1 cores=8,message="c\"d" 1680172008118000000
+cpu,host=h\ 2,region=cn usage=2,ok=true 1680172008119000000
+`, buf.String())
+
+		// round trip
+		res := &DQLResult{Points: []string{base64.StdEncoding.EncodeToString(buf.Bytes())}}
+		pts, err := res.DecodePoints(PrecisionNS)
+		require.NoError(t, err)
+		require.Len(t, pts, 3)
+		assert.Equal(t, "h 2", pts[2].Tags["host"])
+		assert.Equal(t, `c"d`, pts[1].Fields["message"])
+
+		var buf2 bytes.Buffer
+		require.NoError(t, res.Export(&buf2, LineProtocol))
+		assert.Equal(t, buf.String(), buf2.String())
+	})
+
+	t.Run("points-to-csv", func(t *T.T) {
+		res := &DQLResult{Points: []string{base64.StdEncoding.EncodeToString(
+			[]byte("nginx,host=h1 status=200i,msg=\"ok\" 1680172008117000000"))}}
+
+		var buf bytes.Buffer
+		require.NoError(t, res.Export(&buf, CSV))
+		assert.Equal(t, "name,host,time,msg,status\nnginx,h1,1680172008117,ok,200\n", buf.String())
+	})
+
+	t.Run("parse-format", func(t *T.T) {
+		for _, of := range []OutputFormat{LineProtocol, CSV, JSONLines, Arrow} {
+			x, err := ParseOutputFormat(of.String())
+			require.NoError(t, err)
+			assert.Equal(t, of, x)
+		}
+
+		_, err := ParseOutputFormat("xml")
+		assert.Error(t, err)
+	})
+}
+
+// fbReader is a minimal flatbuffers reader to verify arrow messages.
+type fbReader []byte
+
+func (b fbReader) u16(pos int) int { return int(binary.LittleEndian.Uint16(b[pos:])) }
+func (b fbReader) u32(pos int) int { return int(binary.LittleEndian.Uint32(b[pos:])) }
+func (b fbReader) i64(pos int) int64 {
+	return int64(binary.LittleEndian.Uint64(b[pos:]))
+}
+
+func (b fbReader) deref(pos int) int { return pos + b.u32(pos) }
+
+// field get position of field id within table, -1 if absent.
+func (b fbReader) field(table, id int) int {
+	vt := table - int(int32(binary.LittleEndian.Uint32(b[table:])))
+	if 4+2*id >= b.u16(vt) {
+		return -1
+	}
+	off := b.u16(vt + 4 + 2*id)
+	if off == 0 {
+		return -1
+	}
+	return table + off
+}
+
+func (b fbReader) str(pos int) string {
+	pos = b.deref(pos)
+	return string(b[pos+4 : pos+4+b.u32(pos)])
+}
+
+func TestExportArrow(t *T.T) {
+	var buf bytes.Buffer
+	require.NoError(t, exportResult(t).Export(&buf, Arrow))
+
+	stream := buf.Bytes()
+	require.Zero(t, len(stream)%8)
+
+	// read encapsulated messages
+	type message struct {
+		meta fbReader
+		body []byte
+	}
+
+	var msgs []message
+	for pos := 0; ; {
+		require.Equal(t, uint32(0xFFFFFFFF), binary.LittleEndian.Uint32(stream[pos:]))
+		size := int(binary.LittleEndian.Uint32(stream[pos+4:]))
+		if size == 0 {
+			require.Equal(t, len(stream), pos+8)
+			break
+		}
+
+		require.Zero(t, size%8)
+		meta := fbReader(stream[pos+8 : pos+8+size])
+		msg := meta.deref(0)
+		bodyLen := int(meta.i64(meta.field(msg, 3)))
+
+		msgs = append(msgs, message{meta: meta, body: stream[pos+8+size : pos+8+size+bodyLen]})
+		pos += 8 + size + bodyLen
+	}
+
+	require.Len(t, msgs, 2)
+
+	// schema
+	meta := msgs[0].meta
+	msg := meta.deref(0)
+	assert.Equal(t, 4, meta.u16(meta.field(msg, 0))) // V5
+	assert.Equal(t, byte(1), meta[meta.field(msg, 1)])
+
+	schema := meta.deref(meta.field(msg, 2))
+	fields := meta.deref(meta.field(schema, 1))
+
+	var (
+		names []string
+		types []byte
+	)
+
+	for i := 0; i < meta.u32(fields); i++ {
+		f := meta.deref(fields + 4 + 4*i)
+		require.Zero(t, f%4)
+		names = append(names, meta.str(meta.field(f, 0)))
+		types = append(types, meta[meta.field(f, 2)])
+		assert.NotEqual(t, -1, meta.field(f, 5), "children required")
+	}
+
+	assert.Equal(t, []string{"name", "host", "region", "time", "usage", "cores", "message", "ok"}, names)
+	assert.Equal(t, []byte{
+		arrowTypeUtf8, arrowTypeUtf8, arrowTypeUtf8,
+		arrowTypeTimestamp, arrowTypeFloatingPoint, arrowTypeInt, arrowTypeUtf8, arrowTypeBool,
+	}, types)
+
+	// record batch
+	meta = msgs[1].meta
+	msg = meta.deref(0)
+	assert.Equal(t, byte(3), meta[meta.field(msg, 1)])
+
+	batch := meta.deref(meta.field(msg, 2))
+	assert.Equal(t, int64(3), meta.i64(meta.field(batch, 0)))
+
+	nodes := meta.deref(meta.field(batch, 1))
+	require.Equal(t, 8, meta.u32(nodes))
+	require.Zero(t, (nodes+4)%8)
+	// null count of usage
+	assert.Equal(t, int64(1), meta.i64(nodes+4+16*4+8))
+
+	buffers := meta.deref(meta.field(batch, 2))
+	require.Equal(t, 4*3+3*2+2, meta.u32(buffers)) // 4 strings, 3 primitives, 1 bool
+
+	buffer := func(i int) []byte {
+		off := meta.i64(buffers + 4 + 16*i)
+		n := meta.i64(buffers + 4 + 16*i + 8)
+		require.Zero(t, off%8)
+		return msgs[1].body[off : off+n]
+	}
+
+	// time: buffers 9(validity) and 10(values)
+	assert.Equal(t, []byte{0x7}, buffer(9))
+	assert.Equal(t, int64(1680172008118), int64(binary.LittleEndian.Uint64(buffer(10)[8:])))
+
+	// usage: validity 0b101
+	assert.Equal(t, []byte{0x5}, buffer(11))
+	assert.Equal(t, 2.0, math.Float64frombits(binary.LittleEndian.Uint64(buffer(12)[16:])))
+
+	// host: offsets and data
+	assert.Equal(t, "h1h1h 2", string(buffer(5)))
+}
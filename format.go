@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"strings"
+
+	"github.com/GuanceCloud/dql-go/parser"
+)
+
+type formatConf struct {
+	compact bool
+	indent  string
+}
+
+// FormatOption used to set options of Format.
+type FormatOption func(*formatConf)
+
+// WithCompact format DQL in single line.
+func WithCompact(on bool) FormatOption {
+	return func(c *formatConf) {
+		c.compact = on
+	}
+}
+
+// WithIndent set indent of multi-line format, default 2 spaces.
+func WithIndent(indent string) FormatOption {
+	return func(c *formatConf) {
+		c.indent = indent
+	}
+}
+
+// Format format DQL in canonical form: namespace in short name(such as
+// metric:: to M::), keywords in upper case, identifiers and strings are
+// quoted only if needed, and clauses are in order of
+//
+//	{where} [time-range] BY HAVING ORDER BY SORDER BY LIMIT OFFSET SLIMIT SOFFSET
+//
+// So equivalent queries get the same result. By default, each clause is
+// in its own line, and targets and where conditions are one per line if
+// more than one. With WithCompact(true), the query is in single line.
+// Comments within the DQL are dropped.
+func Format(dqlStr string, opts ...FormatOption) (string, error) {
+	c := &formatConf{indent: "  "}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+
+	stmt, err := parser.Parse(dqlStr)
+	if err != nil {
+		return "", err
+	}
+
+	q, ok := stmt.(*parser.Query)
+	if !ok || c.compact {
+		return stmt.String(), nil
+	}
+
+	return c.pretty(q), nil
+}
+
+func (c *formatConf) pretty(q *parser.Query) string {
+	var sb strings.Builder
+
+	if q.Namespace != "" {
+		sb.WriteString(parser.NamespaceShort(q.Namespace) + "::")
+	}
+
+	sources := make([]string, 0, len(q.Sources))
+	for _, s := range q.Sources {
+		sources = append(sources, s.String())
+	}
+	sb.WriteString(strings.Join(sources, ", "))
+
+	if len(q.Targets) > 0 {
+		targets := make([]string, 0, len(q.Targets))
+		for _, t := range q.Targets {
+			targets = append(targets, t.String())
+		}
+		sb.WriteString(":" + c.block("(", ")", targets))
+	}
+
+	clauses := q.Clauses()
+	if len(q.Where) > 0 { // where is the first clause
+		conds := make([]string, 0, len(q.Where))
+		for _, e := range q.Where {
+			conds = append(conds, e.String())
+		}
+		clauses[0] = c.block("{", "}", conds)
+	}
+
+	for _, clause := range clauses {
+		sb.WriteString("\n" + clause)
+	}
+
+	return sb.String()
+}
+
+// block wrap items within lb and rb, one item per line if more than one.
+func (c *formatConf) block(lb, rb string, items []string) string {
+	if len(items) <= 1 {
+		return lb + strings.Join(items, "") + rb
+	}
+	return lb + "\n" + c.indent + strings.Join(items, ",\n"+c.indent) + "\n" + rb
+}
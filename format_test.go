@@ -0,0 +1,80 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	T "testing"
+
+	"github.com/GuanceCloud/dql-go/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *T.T) {
+	t.Run("pretty", func(t *T.T) {
+		s, err := Format(`logging::nginx:(status,count(__docid) as n) limit 10 by status
+			[1h] { host=="web-1" and  status != "ok",  message = wildcard("*err*") } # comment`)
+		require.NoError(t, err)
+
+		assert.Equal(t, `L::nginx:(
+  status,
+  count(__docid) AS n
+)
+{
+  host = 'web-1' AND status != 'ok',
+  message = wildcard('*err*')
+}
+[1h]
+BY status
+LIMIT 10`, s)
+	})
+
+	t.Run("pretty-single-items", func(t *T.T) {
+		s, err := Format("M::cpu:(avg(usage)) {host='a'} [1h::5m] order by time desc", WithIndent("\t"))
+		require.NoError(t, err)
+		assert.Equal(t, "M::cpu:(avg(usage))\n{host = 'a'}\n[1h::5m]\nORDER BY time DESC", s)
+	})
+
+	t.Run("compact", func(t *T.T) {
+		s, err := Format("metric::`cpu`:(  `usage` ) {`host`='a'} slimit 1 LIMIT 2", WithCompact(true))
+		require.NoError(t, err)
+		assert.Equal(t, "M::cpu:(usage) {host = 'a'} LIMIT 2 SLIMIT 1", s)
+	})
+
+	t.Run("equivalent", func(t *T.T) {
+		a, err := Format("L::nginx LIMIT 1 {a = 1, b <> \"x\"}")
+		require.NoError(t, err)
+
+		b, err := Format("logging::nginx {a == 1, b != 'x'} limit 1")
+		require.NoError(t, err)
+
+		assert.Equal(t, a, b)
+	})
+
+	t.Run("idempotent", func(t *T.T) {
+		s, err := Format("M::cpu:(a, b) {x = 1, y = 2} BY host")
+		require.NoError(t, err)
+
+		s2, err := Format(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, s2)
+	})
+
+	t.Run("call-stmt", func(t *T.T) {
+		s, err := Format("metric::show_measurement( )")
+		require.NoError(t, err)
+		assert.Equal(t, "M::show_measurement()", s)
+	})
+
+	t.Run("error", func(t *T.T) {
+		_, err := Format("M::cpu {")
+		require.Error(t, err)
+
+		var perr *parser.Error
+		assert.True(t, errors.As(err, &perr))
+	})
+}
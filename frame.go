@@ -0,0 +1,761 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ColumnType is the value type of Column.
+type ColumnType int
+
+// Column types, all columns are nullable.
+const (
+	ColumnString ColumnType = iota
+	ColumnFloat
+	ColumnInt
+	ColumnBool
+	ColumnTime
+)
+
+// String used to get the column type in string representation.
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnString:
+		return "string"
+	case ColumnFloat:
+		return "float"
+	case ColumnInt:
+		return "int"
+	case ColumnBool:
+		return "bool"
+	case ColumnTime:
+		return "time"
+	}
+	return ""
+}
+
+// A Column is a typed column of Frame. Non-null values within the column
+// are string, float64, int64, bool or time.Time according to the type.
+type Column struct {
+	Name string
+	Type ColumnType
+	Tag  bool // from series name or tags
+
+	values []any
+}
+
+// NewColumn create a column, values are converted to typ, and error
+// returned if any value can not convert.
+func NewColumn(name string, typ ColumnType, values ...any) (*Column, error) {
+	c := &Column{Name: name, Type: typ, values: make([]any, 0, len(values))}
+
+	for _, v := range values {
+		x, err := convertValue(v, typ)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", name, err)
+		}
+		c.values = append(c.values, x)
+	}
+
+	return c, nil
+}
+
+// Len get number of values.
+func (c *Column) Len() int {
+	return len(c.values)
+}
+
+// Value get value i, nil if it's null.
+func (c *Column) Value(i int) any {
+	return c.values[i]
+}
+
+// IsNull check if value i is null.
+func (c *Column) IsNull(i int) bool {
+	return c.values[i] == nil
+}
+
+// Float get value i as float64, int and time(UNIX ms) columns are converted.
+func (c *Column) Float(i int) (float64, bool) {
+	switch x := c.values[i].(type) {
+	case float64:
+		return x, true
+	case int64:
+		return float64(x), true
+	case time.Time:
+		return float64(x.UnixMilli()), true
+	}
+	return 0, false
+}
+
+// Int get value i of int column.
+func (c *Column) Int(i int) (int64, bool) {
+	x, ok := c.values[i].(int64)
+	return x, ok
+}
+
+// Bool get value i of bool column.
+func (c *Column) Bool(i int) (bool, bool) {
+	x, ok := c.values[i].(bool)
+	return x, ok
+}
+
+// Time get value i of time column.
+func (c *Column) Time(i int) (time.Time, bool) {
+	x, ok := c.values[i].(time.Time)
+	return x, ok
+}
+
+// StringValue get value i in string, empty if it's null.
+func (c *Column) StringValue(i int) string {
+	switch x := c.values[i].(type) {
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		return formatValue(x)
+	}
+}
+
+func (c *Column) empty() *Column {
+	return &Column{Name: c.Name, Type: c.Type, Tag: c.Tag}
+}
+
+// A Frame is a columnar table, all columns have the same length.
+type Frame struct {
+	columns []*Column
+	n       int
+}
+
+// NewFrame create a frame on columns.
+func NewFrame(columns ...*Column) (*Frame, error) {
+	f := &Frame{}
+
+	seen := map[string]bool{}
+	for i, c := range columns {
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate column %q", c.Name)
+		}
+		seen[c.Name] = true
+
+		if i > 0 && c.Len() != f.n {
+			return nil, fmt.Errorf("column %q length %d, expect %d", c.Name, c.Len(), f.n)
+		}
+
+		f.n = c.Len()
+		f.columns = append(f.columns, c)
+	}
+
+	return f, nil
+}
+
+// Frame convert series(or points) within the result into a frame. Series
+// are flattened the same as Export: columns are series name(column "name"),
+// tags and value columns, and each value of the series is a row. Column
+// "time" is converted to time column, and types of other value columns are
+// inferred from values.
+func (r *DQLResult) Frame() (*Frame, error) {
+	t, err := r.table()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Frame{n: len(t.rows)}
+
+	for i, col := range t.columns {
+		c := &Column{
+			Name:   col.name,
+			Type:   t.columnType(i),
+			Tag:    col.kind != colValue,
+			values: make([]any, 0, len(t.rows)),
+		}
+
+		for _, row := range t.rows {
+			x, err := convertValue(row[i], c.Type)
+			if err != nil { // should not happen, the type is inferred from values
+				x = nil
+			}
+			c.values = append(c.values, x)
+		}
+
+		f.columns = append(f.columns, c)
+	}
+
+	return f, nil
+}
+
+// columnType infer type of column i of the table.
+func (t *exportTable) columnType(i int) ColumnType {
+	col := t.columns[i]
+	if col.kind != colValue {
+		return ColumnString
+	}
+
+	var nums, ints, bools, strs, others int
+	for _, row := range t.rows {
+		switch x := row[i].(type) {
+		case nil:
+		case float64:
+			nums++
+			if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+				ints++
+			}
+		case int64, uint64:
+			nums++
+			ints++
+		case bool:
+			bools++
+		case string:
+			strs++
+		default:
+			others++
+		}
+	}
+
+	switch {
+	case others > 0 || strs > 0 && (nums > 0 || bools > 0) || nums > 0 && bools > 0:
+		return ColumnString
+	case nums > 0 && ints == nums && col.name == "time":
+		return ColumnTime
+	case nums > 0 && ints == nums:
+		return ColumnInt
+	case nums > 0:
+		return ColumnFloat
+	case bools > 0:
+		return ColumnBool
+	default:
+		return ColumnString
+	}
+}
+
+// convertValue convert v to the Go type of column type typ.
+func convertValue(v any, typ ColumnType) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case ColumnString:
+		if t, ok := v.(time.Time); ok {
+			return t.Format(time.RFC3339Nano), nil
+		}
+		return formatValue(v), nil
+
+	case ColumnFloat:
+		if f, err := toFloat(v); err == nil {
+			return f, nil
+		}
+
+	case ColumnInt:
+		switch x := v.(type) {
+		case uint64:
+			return int64(x), nil
+		case float64:
+			if x == math.Trunc(x) {
+				return int64(x), nil
+			}
+		default:
+			if f, err := toFloat(v); err == nil && f == math.Trunc(f) {
+				return int64(f), nil
+			}
+		}
+
+	case ColumnBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+
+	case ColumnTime:
+		if t, ok := v.(time.Time); ok {
+			return t, nil
+		}
+		if t, err := toTime(v); err == nil {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("can not convert %v(%T) to %s", v, v, typ)
+}
+
+// Len get number of rows.
+func (f *Frame) Len() int {
+	return f.n
+}
+
+// Columns get all columns.
+func (f *Frame) Columns() []*Column {
+	return f.columns
+}
+
+// Column get column by name, nil if not found.
+func (f *Frame) Column(name string) *Column {
+	for _, c := range f.columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Row get row i, null values are omitted.
+func (f *Frame) Row(i int) map[string]any {
+	row := make(map[string]any, len(f.columns))
+	for _, c := range f.columns {
+		if v := c.values[i]; v != nil {
+			row[c.Name] = v
+		}
+	}
+	return row
+}
+
+// Select get a new frame with columns names.
+func (f *Frame) Select(names ...string) (*Frame, error) {
+	cols, err := f.lookup(names)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Frame{columns: cols, n: f.n}, nil
+}
+
+// A FrameRow is a row of Frame used within Filter.
+type FrameRow struct {
+	f *Frame
+	i int
+}
+
+// Index get index of the row within the frame.
+func (r FrameRow) Index() int {
+	return r.i
+}
+
+// Value get value of column name, nil if it's null or column not found.
+func (r FrameRow) Value(name string) any {
+	if c := r.f.Column(name); c != nil {
+		return c.values[r.i]
+	}
+	return nil
+}
+
+// Float get value of column name as float64.
+func (r FrameRow) Float(name string) (float64, bool) {
+	if c := r.f.Column(name); c != nil {
+		return c.Float(r.i)
+	}
+	return 0, false
+}
+
+// StringValue get value of column name in string.
+func (r FrameRow) StringValue(name string) string {
+	if c := r.f.Column(name); c != nil {
+		return c.StringValue(r.i)
+	}
+	return ""
+}
+
+// Filter get a new frame with rows that fn returns true.
+func (f *Frame) Filter(fn func(r FrameRow) bool) *Frame {
+	var idx []int
+	for i := 0; i < f.n; i++ {
+		if fn(FrameRow{f: f, i: i}) {
+			idx = append(idx, i)
+		}
+	}
+	return f.take(idx)
+}
+
+// SortBy get a new frame sorted by column name, the sort is stable and
+// nulls are always the last.
+func (f *Frame) SortBy(name string, order OrderByOrder) (*Frame, error) {
+	c := f.Column(name)
+	if c == nil {
+		return nil, fmt.Errorf("column %q not found", name)
+	}
+
+	idx := make([]int, f.n)
+	for i := range idx {
+		idx[i] = i
+	}
+
+	sort.SliceStable(idx, func(a, b int) bool {
+		va, vb := c.values[idx[a]], c.values[idx[b]]
+		switch {
+		case va == nil:
+			return false
+		case vb == nil:
+			return true
+		case order == DESC:
+			return compareValues(vb, va) < 0
+		default:
+			return compareValues(va, vb) < 0
+		}
+	})
+
+	return f.take(idx), nil
+}
+
+// take get a new frame with rows idx.
+func (f *Frame) take(idx []int) *Frame {
+	nf := &Frame{n: len(idx)}
+
+	for _, c := range f.columns {
+		nc := c.empty()
+		nc.values = make([]any, 0, len(idx))
+		for _, i := range idx {
+			nc.values = append(nc.values, c.values[i])
+		}
+		nf.columns = append(nf.columns, nc)
+	}
+
+	return nf
+}
+
+// compareValues compare non-null values of the same column.
+func compareValues(a, b any) int {
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		default:
+			return 1
+		}
+	case time.Time:
+		y := b.(time.Time)
+		switch {
+		case x.Before(y):
+			return -1
+		case x.After(y):
+			return 1
+		}
+		return 0
+	}
+
+	fa, _ := toFloat(a)
+	fb, _ := toFloat(b)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// hasNull check if any value of row i on columns is null.
+func hasNull(cols []*Column, i int) bool {
+	for _, c := range cols {
+		if c.values[i] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// keyOf get the key of row i on columns, numbers are compared as float.
+func keyOf(cols []*Column, i int) string {
+	var sb strings.Builder
+	for _, c := range cols {
+		switch x := c.values[i].(type) {
+		case nil:
+			sb.WriteString("n")
+		case string:
+			sb.WriteString("s" + x)
+		case bool:
+			sb.WriteString("b" + strconv.FormatBool(x))
+		case time.Time:
+			sb.WriteString("t" + strconv.FormatInt(x.UnixNano(), 10))
+		default:
+			f, _ := toFloat(x)
+			sb.WriteString("f" + strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+func (f *Frame) lookup(names []string) ([]*Column, error) {
+	cols := make([]*Column, 0, len(names))
+	for _, name := range names {
+		c := f.Column(name)
+		if c == nil {
+			return nil, fmt.Errorf("column %q not found", name)
+		}
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+// A Group is a group of rows within GroupedFrame.
+type Group struct {
+	Keys  map[string]any // values of group keys
+	Frame *Frame
+}
+
+// A GroupedFrame is the frame grouped by key columns.
+type GroupedFrame struct {
+	keys   []string
+	groups []*Group
+}
+
+// GroupBy group rows by key columns, groups are in order of first seen.
+func (f *Frame) GroupBy(keys ...string) (*GroupedFrame, error) {
+	keyCols, err := f.lookup(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		order []string
+		idx   = map[string][]int{}
+	)
+
+	for i := 0; i < f.n; i++ {
+		k := keyOf(keyCols, i)
+		if _, ok := idx[k]; !ok {
+			order = append(order, k)
+		}
+		idx[k] = append(idx[k], i)
+	}
+
+	g := &GroupedFrame{keys: keys}
+	for _, k := range order {
+		rows := idx[k]
+
+		grp := &Group{Keys: map[string]any{}, Frame: f.take(rows)}
+		for _, c := range keyCols {
+			grp.Keys[c.Name] = c.values[rows[0]]
+		}
+		g.groups = append(g.groups, grp)
+	}
+
+	return g, nil
+}
+
+// Groups get all groups.
+func (g *GroupedFrame) Groups() []*Group {
+	return g.groups
+}
+
+// An AggFunc is aggregation function used within GroupedFrame.Agg.
+type AggFunc int
+
+// Aggregation functions. Nulls are ignored.
+const (
+	AggCount AggFunc = iota // number of non-null values, int
+	AggSum                  // float
+	AggMean                 // float
+	AggMin                  // same type as the column
+	AggMax                  // same type as the column
+	AggFirst                // first non-null value
+	AggLast                 // last non-null value
+)
+
+// An Agg is an aggregation on column.
+type Agg struct {
+	Column string
+	Func   AggFunc
+	As     string // result column name, default is the Column
+}
+
+// Agg aggregate each group into a row, the result frame contains key
+// columns and aggregation columns.
+func (g *GroupedFrame) Agg(aggs ...Agg) (*Frame, error) {
+	var cols []*Column
+
+	if len(g.groups) == 0 {
+		return &Frame{}, nil
+	}
+
+	first := g.groups[0].Frame
+	for _, k := range g.keys {
+		c := first.Column(k).empty()
+		for _, grp := range g.groups {
+			c.values = append(c.values, grp.Keys[k])
+		}
+		cols = append(cols, c)
+	}
+
+	for _, agg := range aggs {
+		src := first.Column(agg.Column)
+		if src == nil {
+			return nil, fmt.Errorf("column %q not found", agg.Column)
+		}
+
+		c := &Column{Name: agg.As, Type: src.Type}
+		if c.Name == "" {
+			c.Name = agg.Column
+		}
+
+		switch agg.Func {
+		case AggCount:
+			c.Type = ColumnInt
+		case AggSum, AggMean:
+			c.Type = ColumnFloat
+		case AggMin, AggMax, AggFirst, AggLast:
+		default:
+			return nil, fmt.Errorf("unknown aggregation %d", agg.Func)
+		}
+
+		for _, grp := range g.groups {
+			c.values = append(c.values, aggregate(grp.Frame.Column(agg.Column), agg.Func))
+		}
+
+		cols = append(cols, c)
+	}
+
+	return NewFrame(cols...)
+}
+
+func aggregate(c *Column, fn AggFunc) any {
+	var (
+		res   any
+		n     int64
+		sum   float64
+		valid bool
+	)
+
+	for i, v := range c.values {
+		if v == nil {
+			continue
+		}
+		n++
+
+		if f, ok := c.Float(i); ok {
+			sum += f
+			valid = true
+		}
+
+		switch fn {
+		case AggFirst:
+			if res == nil {
+				res = v
+			}
+		case AggLast:
+			res = v
+		case AggMin:
+			if res == nil || compareValues(v, res) < 0 {
+				res = v
+			}
+		case AggMax:
+			if res == nil || compareValues(v, res) > 0 {
+				res = v
+			}
+		case AggCount, AggSum, AggMean:
+		}
+	}
+
+	switch fn {
+	case AggCount:
+		return n
+	case AggSum:
+		if valid {
+			return sum
+		}
+		return nil
+	case AggMean:
+		if valid {
+			return sum / float64(n)
+		}
+		return nil
+	case AggMin, AggMax, AggFirst, AggLast:
+	}
+
+	return res
+}
+
+// A JoinType is the type of Frame.Join.
+type JoinType int
+
+// Join types.
+const (
+	InnerJoin JoinType = iota
+	LeftJoin
+)
+
+// Join join other frame on key columns, such as join cpu and memory frame
+// on "time" and "host". Result columns are columns of f, and non-key
+// columns of other, name conflicted columns of other are renamed with
+// suffix "_right"(repeated until the name is unique). Like SQL, rows with
+// null key never match.
+func (f *Frame) Join(other *Frame, on []string, how JoinType) (*Frame, error) {
+	lkeys, err := f.lookup(on)
+	if err != nil {
+		return nil, err
+	}
+
+	rkeys, err := other.lookup(on)
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string][]int{}
+	for j := 0; j < other.n; j++ {
+		if !hasNull(rkeys, j) {
+			k := keyOf(rkeys, j)
+			index[k] = append(index[k], j)
+		}
+	}
+
+	var lidx, ridx []int // ridx -1 for null
+	for i := 0; i < f.n; i++ {
+		var matches []int
+		if !hasNull(lkeys, i) {
+			matches = index[keyOf(lkeys, i)]
+		}
+
+		if len(matches) == 0 && how == LeftJoin {
+			lidx = append(lidx, i)
+			ridx = append(ridx, -1)
+		}
+
+		for _, j := range matches {
+			lidx = append(lidx, i)
+			ridx = append(ridx, j)
+		}
+	}
+
+	res := f.take(lidx)
+
+	isKey := map[string]bool{}
+	for _, k := range on {
+		isKey[k] = true
+	}
+
+	for _, c := range other.columns {
+		if isKey[c.Name] {
+			continue
+		}
+
+		nc := c.empty()
+		for res.Column(nc.Name) != nil {
+			nc.Name += "_right"
+		}
+
+		nc.values = make([]any, 0, len(ridx))
+		for _, j := range ridx {
+			if j < 0 {
+				nc.values = append(nc.values, nil)
+			} else {
+				nc.values = append(nc.values, c.values[j])
+			}
+		}
+
+		res.columns = append(res.columns, nc)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frameTestResult() *DQLResult {
+	return &DQLResult{
+		Series: []*Row{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "a"},
+				Columns: []string{"time", "usage", "ok"},
+				Values: [][]any{
+					{float64(1000), 1.5, true},
+					{float64(2000), nil, false},
+				},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "b"},
+				Columns: []string{"time", "usage", "ok"},
+				Values: [][]any{
+					{float64(1000), 3.5, true},
+					{float64(2000), 0.5, true},
+				},
+			},
+		},
+	}
+}
+
+func TestFrame(t *T.T) {
+	t.Run("from-result", func(t *T.T) {
+		f, err := frameTestResult().Frame()
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, f.Len())
+
+		var names []string
+		for _, c := range f.Columns() {
+			names = append(names, c.Name+":"+c.Type.String())
+		}
+		assert.Equal(t, []string{"name:string", "host:string", "time:time", "usage:float", "ok:bool"}, names)
+
+		assert.True(t, f.Column("host").Tag)
+		assert.False(t, f.Column("usage").Tag)
+
+		tm, ok := f.Column("time").Time(1)
+		require.True(t, ok)
+		assert.Equal(t, time.UnixMilli(2000), tm)
+
+		assert.True(t, f.Column("usage").IsNull(1))
+		assert.Equal(t, map[string]any{
+			"name": "cpu", "host": "a", "time": time.UnixMilli(2000), "ok": false,
+		}, f.Row(1))
+	})
+
+	t.Run("select-filter-sort", func(t *T.T) {
+		f, err := frameTestResult().Frame()
+		require.NoError(t, err)
+
+		sel, err := f.Select("host", "usage")
+		require.NoError(t, err)
+		assert.Len(t, sel.Columns(), 2)
+
+		_, err = f.Select("xxx")
+		assert.Error(t, err)
+
+		high := f.Filter(func(r FrameRow) bool {
+			v, ok := r.Float("usage")
+			return ok && v > 1
+		})
+		require.Equal(t, 2, high.Len())
+		assert.Equal(t, "a", high.Column("host").StringValue(0))
+		assert.Equal(t, "b", high.Column("host").StringValue(1))
+
+		sorted, err := f.SortBy("usage", DESC)
+		require.NoError(t, err)
+
+		var usages []any
+		for i := 0; i < sorted.Len(); i++ {
+			usages = append(usages, sorted.Column("usage").Value(i))
+		}
+		assert.Equal(t, []any{3.5, 1.5, 0.5, nil}, usages)
+
+		sorted, err = f.SortBy("usage", ASC)
+		require.NoError(t, err)
+		assert.Equal(t, 0.5, sorted.Column("usage").Value(0))
+		assert.Nil(t, sorted.Column("usage").Value(3))
+
+		// the original frame not changed
+		assert.Equal(t, 1.5, f.Column("usage").Value(0))
+	})
+
+	t.Run("group-by", func(t *T.T) {
+		f, err := frameTestResult().Frame()
+		require.NoError(t, err)
+
+		g, err := f.GroupBy("host")
+		require.NoError(t, err)
+		require.Len(t, g.Groups(), 2)
+		assert.Equal(t, "a", g.Groups()[0].Keys["host"])
+		assert.Equal(t, 2, g.Groups()[0].Frame.Len())
+
+		res, err := g.Agg(
+			Agg{Column: "usage", Func: AggCount, As: "n"},
+			Agg{Column: "usage", Func: AggMean, As: "avg"},
+			Agg{Column: "usage", Func: AggMax},
+			Agg{Column: "time", Func: AggLast, As: "last"},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]any{
+			"host": "a", "n": int64(1), "avg": 1.5, "usage": 1.5, "last": time.UnixMilli(2000),
+		}, res.Row(0))
+		assert.Equal(t, map[string]any{
+			"host": "b", "n": int64(2), "avg": 2.0, "usage": 3.5, "last": time.UnixMilli(2000),
+		}, res.Row(1))
+		assert.Equal(t, ColumnInt, res.Column("n").Type)
+
+		_, err = g.Agg(Agg{Column: "xxx"})
+		assert.Error(t, err)
+
+		_, err = f.GroupBy("xxx")
+		assert.Error(t, err)
+	})
+
+	t.Run("join", func(t *T.T) {
+		host, err := NewColumn("host", ColumnString, "a", "b", "c")
+		require.NoError(t, err)
+		usage, err := NewColumn("usage", ColumnFloat, 10, 20.5, nil)
+		require.NoError(t, err)
+
+		mem, err := NewFrame(host, usage)
+		require.NoError(t, err)
+
+		f, err := frameTestResult().Frame()
+		require.NoError(t, err)
+
+		last, err := f.Filter(func(r FrameRow) bool {
+			tm, _ := r.Value("time").(time.Time)
+			return tm.Equal(time.UnixMilli(2000))
+		}).Select("host", "usage")
+		require.NoError(t, err)
+
+		inner, err := last.Join(mem, []string{"host"}, InnerJoin)
+		require.NoError(t, err)
+		require.Equal(t, 2, inner.Len())
+		assert.Equal(t, map[string]any{"host": "b", "usage": 0.5, "usage_right": 20.5}, inner.Row(1))
+
+		left, err := mem.Join(last, []string{"host"}, LeftJoin)
+		require.NoError(t, err)
+		require.Equal(t, 3, left.Len())
+		assert.Equal(t, map[string]any{"host": "c"}, left.Row(2))
+
+		_, err = mem.Join(last, []string{"xxx"}, InnerJoin)
+		assert.Error(t, err)
+
+		// suffixed until unique
+		right, err := NewColumn("usage_right", ColumnFloat, 1, 2, 3)
+		require.NoError(t, err)
+		mem2, err := NewFrame(host, usage, right)
+		require.NoError(t, err)
+
+		inner, err = inner.Join(mem2, []string{"host"}, InnerJoin)
+		require.NoError(t, err)
+		var names []string
+		for _, c := range inner.Columns() {
+			names = append(names, c.Name)
+		}
+		assert.Equal(t, []string{"host", "usage", "usage_right", "usage_right_right", "usage_right_right_right"}, names)
+		assert.Equal(t, map[string]any{
+			"host": "b", "usage": 0.5, "usage_right": 20.5, "usage_right_right": 20.5, "usage_right_right_right": 2.0,
+		}, inner.Row(1))
+
+		// null keys never match
+		k1, err := NewColumn("k", ColumnString, "a", nil)
+		require.NoError(t, err)
+		k2, err := NewColumn("k", ColumnString, nil, "a")
+		require.NoError(t, err)
+		v, err := NewColumn("v", ColumnInt, 1, 2)
+		require.NoError(t, err)
+
+		l, err := NewFrame(k1)
+		require.NoError(t, err)
+		r, err := NewFrame(k2, v)
+		require.NoError(t, err)
+
+		nulls, err := l.Join(r, []string{"k"}, LeftJoin)
+		require.NoError(t, err)
+		require.Equal(t, 2, nulls.Len())
+		assert.Equal(t, map[string]any{"k": "a", "v": int64(2)}, nulls.Row(0))
+		assert.Equal(t, map[string]any{}, nulls.Row(1))
+
+		nulls, err = l.Join(r, []string{"k"}, InnerJoin)
+		require.NoError(t, err)
+		assert.Equal(t, 1, nulls.Len())
+	})
+
+	t.Run("new-frame-errors", func(t *T.T) {
+		_, err := NewColumn("x", ColumnInt, 1.5)
+		assert.Error(t, err)
+
+		a, _ := NewColumn("a", ColumnInt, 1, 2)
+		b, _ := NewColumn("b", ColumnInt, 1)
+		_, err = NewFrame(a, b)
+		assert.Error(t, err)
+
+		_, err = NewFrame(a, a)
+		assert.Error(t, err)
+	})
+}
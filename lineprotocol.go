@@ -0,0 +1,368 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Precision is the timestamp precision of lineprotocol.
+type Precision int
+
+// Lineprotocol timestamp precisions.
+const (
+	PrecisionNS Precision = iota
+	PrecisionUS
+	PrecisionMS
+	PrecisionS
+)
+
+// String used to get the precision in string representation.
+func (p Precision) String() string {
+	switch p {
+	case PrecisionNS:
+		return "ns"
+	case PrecisionUS:
+		return "us"
+	case PrecisionMS:
+		return "ms"
+	case PrecisionS:
+		return "s"
+	}
+	return ""
+}
+
+func (p Precision) toTime(n int64) time.Time {
+	switch p {
+	case PrecisionUS:
+		return time.UnixMicro(n)
+	case PrecisionMS:
+		return time.UnixMilli(n)
+	case PrecisionS:
+		return time.Unix(n, 0)
+	case PrecisionNS:
+		return time.Unix(0, n)
+	}
+	return time.Unix(0, n)
+}
+
+// A Point is a lineprotocol point decoded from DQLResult.Points.
+//
+// Field values are int64, uint64, float64, bool or string.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]any
+
+	// Time is zero if there is no timestamp within the point.
+	Time time.Time
+}
+
+// DecodePoints decode Points of the result(query with WithOutputFormat(LineProtocol))
+// into structured points. The timestamps within points are in precision prec.
+func (r *DQLResult) DecodePoints(prec Precision) ([]*Point, error) {
+	var pts []*Point
+
+	it := r.PointIterator(prec)
+	for it.Next() {
+		pts = append(pts, it.Point())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return pts, nil
+}
+
+// A PointIterator iterate points within DQLResult.Points, and each
+// base64 encoded lineprotocol text are decoded in streaming.
+type PointIterator struct {
+	encoded []string
+	prec    Precision
+
+	idx     int // index of next encoded string
+	lineNum int
+	scanner *bufio.Scanner
+	pt      *Point
+	err     error
+}
+
+// PointIterator create a iterator on Points of the result.
+func (r *DQLResult) PointIterator(prec Precision) *PointIterator {
+	return &PointIterator{
+		encoded: r.Points,
+		prec:    prec,
+	}
+}
+
+// Next advance to next point, it returns false if no more points or
+// error occurred, and we should check the error by Err().
+func (it *PointIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for {
+		if it.scanner == nil {
+			if it.idx >= len(it.encoded) {
+				return false
+			}
+
+			it.scanner = bufio.NewScanner(
+				base64.NewDecoder(base64.StdEncoding, strings.NewReader(it.encoded[it.idx])))
+			it.scanner.Buffer(make([]byte, 0, 4096), 64*1024*1024)
+			it.scanner.Split(scanLines)
+			it.idx++
+			it.lineNum = 0
+		}
+
+		if !it.scanner.Scan() {
+			if err := it.scanner.Err(); err != nil {
+				it.err = fmt.Errorf("decode point %d: %w", it.idx-1, err)
+				return false
+			}
+			it.scanner = nil
+			continue
+		}
+
+		it.lineNum++
+
+		line := bytes.TrimSpace(it.scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		pt, err := ParseLineProtocol(line, it.prec)
+		if err != nil {
+			it.err = fmt.Errorf("decode point %d line %d: %w", it.idx-1, it.lineNum, err)
+			return false
+		}
+
+		it.pt = pt
+		return true
+	}
+}
+
+// Point get current point.
+func (it *PointIterator) Point() *Point {
+	return it.pt
+}
+
+// Err get the error during iteration.
+func (it *PointIterator) Err() error {
+	return it.err
+}
+
+// ParseLineProtocol parse a single lineprotocol line.
+func ParseLineProtocol(line []byte, prec Precision) (*Point, error) {
+	p := &lpParser{buf: line}
+
+	pt := &Point{
+		Tags:   map[string]string{},
+		Fields: map[string]any{},
+	}
+
+	pt.Measurement = p.token(", ", false)
+	if pt.Measurement == "" {
+		return nil, errors.New("missing measurement")
+	}
+
+	// tags
+	for p.peek() == ',' {
+		p.pos++
+
+		k := p.token("= ,", true)
+		if p.peek() != '=' || k == "" {
+			return nil, fmt.Errorf("invalid tag at %d", p.pos)
+		}
+		p.pos++
+
+		v := p.token(", ", true)
+		pt.Tags[k] = v
+	}
+
+	if p.peek() != ' ' {
+		return nil, fmt.Errorf("missing fields at %d", p.pos)
+	}
+	p.skipSpaces()
+
+	// fields
+	for {
+		k := p.token("= ,", true)
+		if p.peek() != '=' || k == "" {
+			return nil, fmt.Errorf("invalid field at %d", p.pos)
+		}
+		p.pos++
+
+		v, err := p.fieldValue()
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", k, err)
+		}
+		pt.Fields[k] = v
+
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	// timestamp
+	p.skipSpaces()
+	if ts := strings.TrimSpace(string(p.buf[p.pos:])); ts != "" {
+		n, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", ts)
+		}
+		pt.Time = prec.toTime(n)
+	}
+
+	return pt, nil
+}
+
+// scanLines is a bufio.SplitFunc split lineprotocol lines. Different from
+// bufio.ScanLines, newlines within string field values are not line ends.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	section := 0 // 0: measurement and tags, 1: fields, 2: timestamp
+	inQuote := false
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c == '\\':
+			i++ // skip escaped byte
+		case inQuote:
+			if c == '"' {
+				inQuote = false
+			}
+		case c == '"' && section == 1:
+			inQuote = true
+		case c == ' ' && section < 2:
+			section++
+			for i+1 < len(data) && data[i+1] == ' ' {
+				i++
+			}
+		case c == '\n':
+			return i + 1, bytes.TrimRight(data[:i], "\r"), nil
+		}
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	// request more data
+	return 0, nil, nil
+}
+
+type lpParser struct {
+	buf []byte
+	pos int
+}
+
+func (p *lpParser) peek() byte {
+	if p.pos >= len(p.buf) {
+		return 0
+	}
+	return p.buf[p.pos]
+}
+
+func (p *lpParser) skipSpaces() {
+	for p.pos < len(p.buf) && p.buf[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// token read until any unescaped byte within stops. Escaped comma, space
+// and equal sign are unescaped.
+func (p *lpParser) token(stops string, escapeEqual bool) string {
+	var sb strings.Builder
+
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+
+		if c == '\\' && p.pos+1 < len(p.buf) {
+			next := p.buf[p.pos+1]
+			if next == ',' || next == ' ' || (escapeEqual && next == '=') {
+				sb.WriteByte(next)
+				p.pos += 2
+				continue
+			}
+		}
+
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+
+		sb.WriteByte(c)
+		p.pos++
+	}
+
+	return sb.String()
+}
+
+func (p *lpParser) fieldValue() (any, error) {
+	if p.peek() == '"' {
+		return p.stringValue()
+	}
+
+	start := p.pos
+	for p.pos < len(p.buf) && p.buf[p.pos] != ',' && p.buf[p.pos] != ' ' {
+		p.pos++
+	}
+
+	v := string(p.buf[start:p.pos])
+	if v == "" {
+		return nil, errors.New("missing value")
+	}
+
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch v[len(v)-1] {
+	case 'i':
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case 'u':
+		return strconv.ParseUint(v[:len(v)-1], 10, 64)
+	default:
+		return strconv.ParseFloat(v, 64)
+	}
+}
+
+func (p *lpParser) stringValue() (string, error) {
+	var sb strings.Builder
+
+	p.pos++ // skip leading quote
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+
+		switch {
+		case c == '\\' && p.pos+1 < len(p.buf) && (p.buf[p.pos+1] == '"' || p.buf[p.pos+1] == '\\'):
+			sb.WriteByte(p.buf[p.pos+1])
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", errors.New("unterminated string")
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/base64"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLineProtocol(t *T.T) {
+	t.Run("basic", func(t *T.T) {
+		pt, err := ParseLineProtocol([]byte(
+			`nginx,host=host-1,region=cn status=200i,bytes=1024u,cost=1.5,ok=true,message="hello \"world\"" 1680172008117`),
+			PrecisionMS)
+		require.NoError(t, err)
+
+		assert.Equal(t, "nginx", pt.Measurement)
+		assert.Equal(t, map[string]string{"host": "host-1", "region": "cn"}, pt.Tags)
+		assert.Equal(t, int64(200), pt.Fields["status"])
+		assert.Equal(t, uint64(1024), pt.Fields["bytes"])
+		assert.Equal(t, 1.5, pt.Fields["cost"])
+		assert.Equal(t, true, pt.Fields["ok"])
+		assert.Equal(t, `hello "world"`, pt.Fields["message"])
+		assert.Equal(t, int64(1680172008117), pt.Time.UnixMilli())
+	})
+
+	t.Run("escaped", func(t *T.T) {
+		pt, err := ParseLineProtocol([]byte(
+			`my\ measurement,tag\ key=tag\,value,k\=1=v\=1 field\ key="a,b c",f=F`), PrecisionNS)
+		require.NoError(t, err)
+
+		assert.Equal(t, "my measurement", pt.Measurement)
+		assert.Equal(t, "tag,value", pt.Tags["tag key"])
+		assert.Equal(t, "v=1", pt.Tags["k=1"])
+		assert.Equal(t, "a,b c", pt.Fields["field key"])
+		assert.Equal(t, false, pt.Fields["f"])
+		assert.True(t, pt.Time.IsZero())
+	})
+
+	t.Run("precision", func(t *T.T) {
+		pt, err := ParseLineProtocol([]byte(`cpu usage=1 1680172008`), PrecisionS)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(1680172008, 0), pt.Time)
+
+		pt, err = ParseLineProtocol([]byte(`cpu usage=1 1680172008000000000`), PrecisionNS)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(1680172008, 0), pt.Time)
+	})
+
+	t.Run("invalid", func(t *T.T) {
+		for _, line := range []string{
+			`cpu`,
+			`cpu,host usage=1`,
+			`cpu usage=abc`,
+			`cpu usage=1i,msg="abc`,
+			`cpu usage=1 abc`,
+		} {
+			_, err := ParseLineProtocol([]byte(line), PrecisionNS)
+			assert.Error(t, err, line)
+		}
+	})
+}
+
+func TestDecodePoints(t *T.T) {
+	enc := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	res := &DQLResult{
+		Points: []string{
+			enc("nginx,host=h1 message=\"line1\nline2\",status=200i 1680172008117000000\n" +
+				"nginx,host=h2 message=\"ok\",status=404i 1680172008118000000\n"),
+			enc("# comment\n\nmysql,host=h3 cost=1.5 1680172008119000000"),
+		},
+	}
+
+	pts, err := res.DecodePoints(PrecisionNS)
+	require.NoError(t, err)
+	require.Len(t, pts, 3)
+
+	assert.Equal(t, "line1\nline2", pts[0].Fields["message"])
+	assert.Equal(t, int64(404), pts[1].Fields["status"])
+	assert.Equal(t, "mysql", pts[2].Measurement)
+	assert.Equal(t, int64(1680172008119), pts[2].Time.UnixMilli())
+
+	t.Run("iterator-error", func(t *T.T) {
+		res := &DQLResult{Points: []string{enc("cpu usage=1\n"), enc("cpu usage=\n")}}
+
+		it := res.PointIterator(PrecisionNS)
+		assert.True(t, it.Next())
+		assert.False(t, it.Next())
+		require.Error(t, it.Err())
+		assert.Contains(t, it.Err().Error(), "point 1 line 1")
+
+		res = &DQLResult{Points: []string{"not-base64!"}}
+		_, err := res.DecodePoints(PrecisionNS)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/GuanceCloud/dql-go/parser"
+)
+
+// A Namespace is the data namespace of DQL, such as M:: for metric.
+type Namespace string
+
+// DQL namespaces, in short name.
+const (
+	NSMetric       Namespace = "M"
+	NSLogging      Namespace = "L"
+	NSObject       Namespace = "O"
+	NSCustomObject Namespace = "CO"
+	NSEvent        Namespace = "E"
+	NSTracing      Namespace = "T"
+	NSRUM          Namespace = "R"
+	NSSecurity     Namespace = "S"
+	NSNetwork      Namespace = "N"
+	NSProfiling    Namespace = "P"
+	NSBackupLog    Namespace = "BL"
+)
+
+// A Feature is a DQL option that not available on all namespaces.
+type Feature string
+
+// Features of DQL options.
+const (
+	FeatureProfile     Feature = "profile"      // WithProfile
+	FeatureOptimized   Feature = "optimized"    // WithOptimized
+	FeatureHighlight   Feature = "highlight"    // WithHighlight
+	FeatureShowLabel   Feature = "show_label"   // WithShowLabel
+	FeatureSearchAfter Feature = "search_after" // WithSearchAfter
+	FeatureSampling    Feature = "sampling"     // WithSampling(false)
+)
+
+// NamespaceInfo is the metadata of a namespace.
+type NamespaceInfo struct {
+	Namespace Namespace
+	Name      string // long name, such as metric
+
+	// Features are available features on the namespace.
+	Features []Feature
+}
+
+// Features on document(text-based) namespaces.
+var docFeatures = []Feature{
+	FeatureProfile,
+	FeatureOptimized,
+	FeatureHighlight,
+	FeatureSearchAfter,
+}
+
+var namespaceInfos = []*NamespaceInfo{
+	{Namespace: NSMetric, Name: "metric"},
+	{Namespace: NSLogging, Name: "logging", Features: append([]Feature{FeatureSampling}, docFeatures...)},
+	{Namespace: NSObject, Name: "object", Features: append([]Feature{FeatureShowLabel}, docFeatures...)},
+	{Namespace: NSCustomObject, Name: "custom_object", Features: append([]Feature{FeatureShowLabel}, docFeatures...)},
+	{Namespace: NSEvent, Name: "event", Features: docFeatures},
+	{Namespace: NSTracing, Name: "tracing", Features: append([]Feature{FeatureSampling}, docFeatures...)},
+	{Namespace: NSRUM, Name: "rum", Features: append([]Feature{FeatureSampling}, docFeatures...)},
+	{Namespace: NSSecurity, Name: "security", Features: docFeatures},
+	{Namespace: NSNetwork, Name: "network", Features: docFeatures},
+	{Namespace: NSProfiling, Name: "profiling", Features: docFeatures},
+	{Namespace: NSBackupLog, Name: "backup_log", Features: docFeatures},
+}
+
+// Namespaces get all namespaces.
+func Namespaces() []Namespace {
+	arr := make([]Namespace, 0, len(namespaceInfos))
+	for _, ni := range namespaceInfos {
+		arr = append(arr, ni.Namespace)
+	}
+	return arr
+}
+
+// ParseNamespace parse short or long name(case-insensitive) of namespace,
+// such as "M" or "metric".
+func ParseNamespace(s string) (Namespace, error) {
+	short := parser.NamespaceShort(s)
+	if short == "" {
+		return "", fmt.Errorf("unknown namespace %q", s)
+	}
+	return Namespace(short), nil
+}
+
+// String get short name of the namespace.
+func (ns Namespace) String() string {
+	return string(ns)
+}
+
+// Info get metadata of the namespace, nil if the namespace unknown.
+func (ns Namespace) Info() *NamespaceInfo {
+	for _, ni := range namespaceInfos {
+		if ni.Namespace == ns {
+			return ni
+		}
+	}
+	return nil
+}
+
+// Supports check if feature f available on the namespace.
+func (ns Namespace) Supports(f Feature) bool {
+	ni := ns.Info()
+	if ni == nil {
+		return false
+	}
+
+	for _, x := range ni.Features {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// features get features used by the query.
+func (q *dql) features() []Feature {
+	var arr []Feature
+
+	for _, x := range []struct {
+		f  Feature
+		on bool
+	}{
+		{FeatureProfile, q.Profile},
+		{FeatureOptimized, q.Optimized},
+		{FeatureHighlight, q.Highlight},
+		{FeatureShowLabel, q.ShowLabelDeprecated},
+		{FeatureSearchAfter, len(q.SearchAfter) > 0},
+		{FeatureSampling, q.DisableSampling},
+	} {
+		if x.on {
+			arr = append(arr, x.f)
+		}
+	}
+
+	return arr
+}
+
+// Namespace get namespace of the query, empty if no namespace within the
+// DQL or it's a PromQL query.
+func (q *dql) Namespace() Namespace {
+	if q.QType == "promql" {
+		return ""
+	}
+
+	ns, err := ParseNamespace(namespacePrefix(q.DQL))
+	if err != nil {
+		return ""
+	}
+	return ns
+}
+
+// Warnings get warnings of the query, such as option not available on
+// the namespace of the query. With WithValidate(true), BuildDQL fail on
+// these warnings.
+func (q *dql) Warnings() []string {
+	ns := q.Namespace()
+	if ns == "" {
+		return nil
+	}
+
+	var warnings []string
+	for _, f := range q.features() {
+		if !ns.Supports(f) {
+			warnings = append(warnings,
+				fmt.Sprintf("option %s not available on %s::(%s)", f, ns, ns.Info().Name))
+		}
+	}
+
+	return warnings
+}
+
+// namespacePrefix get the namespace before :: within DQL, comments and
+// spaces before it are skipped.
+func namespacePrefix(s string) string {
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if !strings.HasPrefix(s, "#") {
+			break
+		}
+
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			return ""
+		}
+		s = s[i:]
+	}
+
+	ns, _, ok := strings.Cut(s, "::")
+	if !ok {
+		return ""
+	}
+	return ns
+}
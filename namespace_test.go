@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespace(t *T.T) {
+	t.Run("parse", func(t *T.T) {
+		for _, s := range []string{"M", "m", "metric", "METRIC"} {
+			ns, err := ParseNamespace(s)
+			require.NoError(t, err)
+			assert.Equal(t, NSMetric, ns)
+		}
+
+		ns, err := ParseNamespace("backup_log")
+		require.NoError(t, err)
+		assert.Equal(t, NSBackupLog, ns)
+		assert.Equal(t, "backup_log", ns.Info().Name)
+
+		_, err = ParseNamespace("X")
+		assert.Error(t, err)
+
+		assert.Len(t, Namespaces(), 11)
+		for _, ns := range Namespaces() {
+			assert.NotNil(t, ns.Info(), ns)
+		}
+	})
+
+	t.Run("supports", func(t *T.T) {
+		assert.False(t, NSMetric.Supports(FeatureOptimized))
+		assert.True(t, NSLogging.Supports(FeatureOptimized))
+		assert.True(t, NSObject.Supports(FeatureShowLabel))
+		assert.False(t, NSLogging.Supports(FeatureShowLabel))
+		assert.False(t, Namespace("X").Supports(FeatureProfile))
+	})
+
+	t.Run("query-namespace", func(t *T.T) {
+		assert.Equal(t, NSLogging, MustBuildDQL("  # comment\n logging::nginx").Namespace())
+		assert.Equal(t, NSMetric, MustBuildDQL("M::cpu").Namespace())
+		assert.Equal(t, Namespace(""), MustBuildDQL("cpu").Namespace())
+		assert.Equal(t, Namespace(""), MustBuildDQL("up{job='x'}", WithQueryType("promql")).Namespace())
+	})
+
+	t.Run("warnings", func(t *T.T) {
+		q := MustBuildDQL("M::cpu", WithOptimized(true), WithProfile(true))
+		assert.Equal(t, []string{
+			"option profile not available on M::(metric)",
+			"option optimized not available on M::(metric)",
+		}, q.Warnings())
+
+		assert.Empty(t, MustBuildDQL("L::nginx", WithOptimized(true), WithSampling(false)).Warnings())
+		assert.Len(t, MustBuildDQL("O::host", WithSampling(false)).Warnings(), 1)
+
+		_, err := BuildDQL("M::cpu", WithHighlight(true), WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithHighlight(true), WithValidate(true))
+		assert.NoError(t, err)
+	})
+}
@@ -5,7 +5,14 @@
 
 package dql
 
-import "time"
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
 
 // DQLOption used to set various DQL options.
 type DQLOption func(*dql)
@@ -36,7 +43,9 @@ func WithConditions(conditions string) DQLOption {
 	}
 }
 
-// WithOutputFormat set output format, currently only support LineProtocol.
+// WithOutputFormat set output format of the backend. LineProtocol are supported
+// by all backends, other formats may not available on some backend, and we can
+// always convert the result on client side with DQLResult.Export.
 func WithOutputFormat(of OutputFormat) DQLOption {
 	return func(q *dql) {
 		q.OutputFormat = of.String()
@@ -185,11 +194,13 @@ func WithSLimit(n int) DQLOption {
 }
 
 // WithTimeRange used to set time range of the DQL query.
-// start and end are UNIX timestamp in ms.
+// start and end are UNIX timestamp in ms. It replace time range
+// set by other options. See WithTimeWindow and WithLast for time
+// based options.
 func WithTimeRange(start, end int) DQLOption {
 	return func(q *dql) {
-		q.TimeRange = append(q.TimeRange, start)
-		q.TimeRange = append(q.TimeRange, end)
+		q.window = nil
+		q.TimeRange = []int64{int64(start), int64(end)}
 	}
 }
 
@@ -224,13 +235,16 @@ func WithSampling(on bool) DQLOption {
 	}
 }
 
-// WithQueryType set query type, only "dql" or "promql" are allowed.
+// WithQueryType set query type, only "dql" or "promql" are allowed, and
+// other type get ErrInvalidOption on BuildDQL. For PromQL, BuildPromQL and
+// BuildInstantPromQL are preferred.
 func WithQueryType(t string) DQLOption {
 	return func(q *dql) {
 		switch t {
 		case "dql", "promql":
 			q.QType = t
-		default: // pass
+		default:
+			q.setErr(fmt.Errorf("%w: invalid query type %q", ErrInvalidOption, t))
 		}
 	}
 }
@@ -344,3 +358,85 @@ func WithHTTPS(on bool) QueryOption {
 		q.https = on
 	}
 }
+
+// WithQueryError make Query return a *QueryError if the Result
+// contains ErrorCode or the HTTP status is not 2xx. By default,
+// the failed query is returned as a Result with ErrorCode and Message set.
+// We can check the error with errors.Is(err, ErrParse) and so on.
+func WithQueryError(on bool) QueryOption {
+	return func(q *query) {
+		q.queryError = on
+	}
+}
+
+// ClientOption used to set various client options.
+type ClientOption func(*Client)
+
+// WithHTTPClient set the HTTP client used to send queries, we can
+// set timeout or custom transport on it.
+func WithHTTPClient(cli *http.Client) ClientOption {
+	return func(c *Client) {
+		if cli != nil {
+			c.cli = cli
+		}
+	}
+}
+
+// WithTLSConfig set TLS config(root CAs, client certificates and so on)
+// used to connect HTTPS Datakit/Dataway. The config only applied to
+// *http.Transport, custom RoundTripper set by WithHTTPClient are not changed.
+func WithTLSConfig(conf *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConf = conf
+	}
+}
+
+// WithProxy set HTTP proxy used to send queries. Same as WithTLSConfig,
+// the proxy only applied to *http.Transport.
+func WithProxy(proxy *url.URL) ClientOption {
+	return func(c *Client) {
+		if proxy != nil {
+			c.proxy = http.ProxyURL(proxy)
+		}
+	}
+}
+
+// WithHeader add extra HTTP header to each query request, for example
+// a tenant header required by some gateway.
+func WithHeader(k, v string) ClientOption {
+	return func(c *Client) {
+		c.headers.Add(k, v)
+	}
+}
+
+// WithUserAgent set User-Agent of each query request.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.headers.Set("User-Agent", ua)
+	}
+}
+
+// WithBaseURL set the base URL(scheme, host and path prefix) of the query API,
+// for example:
+//
+//	https://gateway.example.com/datakit
+//
+// then queries are sent to https://gateway.example.com/datakit/v1/query/raw.
+// If base URL set, host of the client and the WithHTTPS option are ignored.
+func WithBaseURL(u string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(u, "/")
+	}
+}
+
+// WithRetry set retry policy on transient failures, see DefaultRetryPolicy.
+// Retry are disabled by default.
+func WithRetry(p *RetryPolicy) ClientOption {
+	return func(c *Client) {
+		if p != nil && p.MaxAttempts > 1 {
+			c.retry = p
+		} else {
+			c.retry = nil
+		}
+	}
+}
@@ -6,9 +6,14 @@
 package dql
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
 	T "testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOptions(t *T.T) {
@@ -26,3 +31,56 @@ func TestOptions(t *T.T) {
 		assert.Equal(t, LineProtocol.String(), q.OutputFormat)
 	})
 }
+
+func TestClientOptions(t *T.T) {
+	t.Run("header-and-base-url", func(t *T.T) {
+		var (
+			path, tenant, ua string
+		)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			tenant = r.Header.Get("X-Tenant")
+			ua = r.Header.Get("User-Agent")
+			w.Write([]byte(`{"content":[]}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient("",
+			WithBaseURL(ts.URL+"/datakit/"),
+			WithHeader("X-Tenant", "tenant-a"),
+			WithUserAgent("my-app/1.0"))
+
+		_, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.NoError(t, err)
+
+		assert.Equal(t, "/datakit/v1/query/raw", path)
+		assert.Equal(t, "tenant-a", tenant)
+		assert.Equal(t, "my-app/1.0", ua)
+	})
+
+	t.Run("tls-config", func(t *T.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"content":[]}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		// default client do not trust the test server
+		c := NewClient(ts.Listener.Addr().String())
+		_, err := c.Query(WithHTTPS(true), WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		assert.Error(t, err)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+
+		cli := &http.Client{}
+		c = NewClient(ts.Listener.Addr().String(),
+			WithHTTPClient(cli),
+			WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}))
+
+		_, err = c.Query(WithHTTPS(true), WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		assert.NoError(t, err)
+
+		assert.Nil(t, cli.Transport, "user's HTTP client should not be changed")
+	})
+}
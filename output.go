@@ -5,20 +5,45 @@
 
 package dql
 
+import "fmt"
+
 // A OutputFormat is the query result format.
 type OutputFormat int
 
 // Query result formats.
 const (
 	LineProtocol OutputFormat = iota
+	CSV
+	JSONLines // aka NDJSON
+	Arrow     // Apache Arrow IPC streaming format
 )
 
 // String used to get the output format in string representation.
 func (of OutputFormat) String() string {
-	//nolint:gocritic
 	switch of {
 	case LineProtocol:
 		return "lineprotocol"
+	case CSV:
+		return "csv"
+	case JSONLines:
+		return "ndjson"
+	case Arrow:
+		return "arrow"
 	}
 	return ""
 }
+
+// ParseOutputFormat get the output format from it's string representation.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch s {
+	case "lineprotocol", "line-protocol", "lp":
+		return LineProtocol, nil
+	case "csv":
+		return CSV, nil
+	case "ndjson", "jsonl", "json-lines":
+		return JSONLines, nil
+	case "arrow":
+		return Arrow, nil
+	}
+	return 0, fmt.Errorf("unknown output format %q", s)
+}
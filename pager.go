@@ -0,0 +1,313 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// A Pager walk a DQL query page by page. For example:
+//
+//	p := c.OffsetPager(ctx, MustBuildDQL("L::nginx"), WithPageSize(100))
+//	for p.NextPage() {
+//		page := p.Page()
+//		...
+//	}
+//	if err := p.Err(); err != nil {
+//		...
+//	}
+//
+// A Pager stops if a page returned fewer rows(series for SeriesPager)
+// than requested.
+type Pager interface {
+	// NextPage fetch next page, it returns false if no more pages or error
+	// occurred, and we should check the error by Err().
+	NextPage() bool
+
+	// Page get current page's query result.
+	Page() *DQLResult
+
+	// Pages get number of pages fetched.
+	Pages() int
+
+	// Err get the error during paging.
+	Err() error
+}
+
+var (
+	_ Pager = (*OffsetPager)(nil)
+	_ Pager = (*SeriesPager)(nil)
+	_ Pager = (*CursorPager)(nil)
+)
+
+// pager is the common part of pagers.
+type pager struct {
+	pageConf
+
+	ctx context.Context
+	cli *Client
+	q   *dql
+
+	// prepare set paging options on the page DQL, it returns
+	// the number of requested rows.
+	prepare func(page *dql, left int64) int64
+
+	// count get the number of rows within the page result.
+	count func(res *DQLResult) int64
+
+	// advance update paging state on the page result, it returns
+	// false if paging can't be moved forward.
+	advance func(res *DQLResult) bool
+
+	page    *DQLResult
+	pages   int
+	fetched int64
+	done    bool
+	err     error
+}
+
+func newPager(ctx context.Context, c *Client, q *dql, opts []PaginateOption) pager {
+	p := pager{
+		ctx: ctx,
+		cli: c,
+		q:   q,
+	}
+
+	p.apply(opts)
+	return p
+}
+
+// NextPage implements Pager.
+func (p *pager) NextPage() bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	var left int64
+	if p.maxRows > 0 {
+		left = p.maxRows - p.fetched
+		if left <= 0 {
+			p.done = true
+			return false
+		}
+	}
+
+	page := *p.q
+	requested := p.prepare(&page, left)
+
+	res, err := p.queryPage(p.ctx, p.cli, &page)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	n := p.count(res)
+	p.page = res
+	p.pages++
+	p.fetched += n
+
+	if n == 0 {
+		p.done = true
+		return false
+	}
+
+	if (requested > 0 && n < requested) || !p.advance(res) {
+		p.done = true // this is the last page
+	}
+
+	return true
+}
+
+// Page implements Pager.
+func (p *pager) Page() *DQLResult {
+	return p.page
+}
+
+// Pages implements Pager.
+func (p *pager) Pages() int {
+	return p.pages
+}
+
+// Err implements Pager.
+func (p *pager) Err() error {
+	return p.err
+}
+
+// pageLimit get the page size limited by left rows.
+func pageLimit(size, left int64) int64 {
+	if left > 0 && (size == 0 || left < size) {
+		return left
+	}
+	return size
+}
+
+// countPoints get number of points among all series.
+func countPoints(res *DQLResult) int64 {
+	var n int64
+	for _, s := range res.Series {
+		n += int64(len(s.Values))
+	}
+	return n
+}
+
+// An OffsetPager walk points with offset/limit(see WithOffset and WithLimit).
+type OffsetPager struct {
+	pager
+	offset int64
+}
+
+// OffsetPager create a pager walking points of q with offset/limit.
+// If page size not set, the limit of q used.
+func (c *Client) OffsetPager(ctx context.Context, q *dql, opts ...PaginateOption) *OffsetPager {
+	p := &OffsetPager{
+		pager:  newPager(ctx, c, q, opts),
+		offset: int64(q.Offset),
+	}
+
+	if p.pageSize == 0 {
+		p.pageSize = q.Limit
+	}
+
+	p.prepare = func(page *dql, left int64) int64 {
+		page.Offset = int(p.offset)
+		page.Limit = pageLimit(p.pageSize, left)
+		return page.Limit
+	}
+
+	p.count = countPoints
+
+	p.advance = func(res *DQLResult) bool {
+		p.offset += countPoints(res)
+		return true
+	}
+
+	return p
+}
+
+// A SeriesPager walk series with soffset/slimit(see WithSOffset and WithSLimit).
+type SeriesPager struct {
+	pager
+	soffset int64
+}
+
+// SeriesPager create a pager walking series of q with soffset/slimit.
+// If page size not set, the slimit of q used.
+func (c *Client) SeriesPager(ctx context.Context, q *dql, opts ...PaginateOption) *SeriesPager {
+	p := &SeriesPager{
+		pager:   newPager(ctx, c, q, opts),
+		soffset: int64(q.SOffset),
+	}
+
+	if p.pageSize == 0 {
+		p.pageSize = int64(q.SLimit)
+	}
+
+	p.prepare = func(page *dql, left int64) int64 {
+		page.SOffset = int(p.soffset)
+		page.SLimit = int(pageLimit(p.pageSize, left))
+		return int64(page.SLimit)
+	}
+
+	p.count = func(res *DQLResult) int64 {
+		return int64(len(res.Series))
+	}
+
+	p.advance = func(res *DQLResult) bool {
+		p.soffset += int64(len(res.Series))
+		return true
+	}
+
+	return p
+}
+
+// A CursorPager walk points with cursor time(see WithCursorTime). The
+// cursor of next page is the time of the last point within current page.
+type CursorPager struct {
+	pager
+	cursor int64
+}
+
+// CursorPager create a pager walking points of q with cursor time.
+// If page size not set, the limit of q used.
+func (c *Client) CursorPager(ctx context.Context, q *dql, opts ...PaginateOption) *CursorPager {
+	p := &CursorPager{
+		pager:  newPager(ctx, c, q, opts),
+		cursor: q.CursorTime,
+	}
+
+	if p.pageSize == 0 {
+		p.pageSize = q.Limit
+	}
+
+	p.prepare = func(page *dql, left int64) int64 {
+		page.CursorTime = p.cursor
+		page.Limit = pageLimit(p.pageSize, left)
+		return page.Limit
+	}
+
+	p.count = countPoints
+
+	p.advance = func(res *DQLResult) bool {
+		cursor, ok := lastTime(res)
+		if !ok || cursor == p.cursor {
+			return false
+		}
+
+		p.cursor = cursor
+		return true
+	}
+
+	return p
+}
+
+// Cursor get the cursor time of next page.
+func (p *CursorPager) Cursor() int64 {
+	return p.cursor
+}
+
+// lastTime get the time of the last point within res.
+func lastTime(res *DQLResult) (int64, bool) {
+	for i := len(res.Series) - 1; i >= 0; i-- {
+		s := res.Series[i]
+		if len(s.Values) == 0 {
+			continue
+		}
+
+		idx := -1
+		for j, col := range s.Columns {
+			if col == "time" {
+				idx = j
+				break
+			}
+		}
+
+		if idx < 0 {
+			return 0, false
+		}
+
+		row := s.Values[len(s.Values)-1]
+		if idx >= len(row) {
+			return 0, false
+		}
+
+		switch v := row[idx].(type) {
+		case float64:
+			return int64(v), true
+		case int64:
+			return v, true
+		case int:
+			return int64(v), true
+		case json.Number:
+			n, err := v.Int64()
+			return n, err == nil
+		default:
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
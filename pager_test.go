@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"fmt"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagingServer serve total points within a single series, or total series
+// if series is true. Points time start from 1000 and decreased by 1, and
+// cursor time is exclusive.
+func pagingServer(t *T.T, total int, series bool) *queryServer {
+	t.Helper()
+
+	return newQueryServer(t, func(d *dql) *DQLResult {
+		res := &DQLResult{}
+
+		if series {
+			for i := d.SOffset; i < total && len(res.Series) < d.SLimit; i++ {
+				res.Series = append(res.Series, &Row{
+					Name:    "cpu",
+					Tags:    map[string]string{"host": fmt.Sprintf("host-%d", i)},
+					Columns: []string{"time", "usage"},
+					Values:  [][]any{{1000, i}},
+				})
+			}
+			return res
+		}
+
+		start := d.Offset
+		if d.CursorTime > 0 {
+			start = 1000 - int(d.CursorTime) + 1
+		}
+
+		row := &Row{Name: "nginx", Columns: []string{"time", "n"}}
+		for i := start; i < total && int64(len(row.Values)) < d.Limit; i++ {
+			row.Values = append(row.Values, []any{1000 - i, i})
+		}
+		res.Series = []*Row{row}
+		return res
+	})
+}
+
+func collectPoints(t *T.T, p Pager) []float64 {
+	t.Helper()
+
+	var ns []float64
+	for p.NextPage() {
+		for _, s := range p.Page().Series {
+			for _, v := range s.Values {
+				ns = append(ns, v[1].(float64))
+			}
+		}
+	}
+
+	require.NoError(t, p.Err())
+	return ns
+}
+
+func TestPager(t *T.T) {
+	t.Run("offset", func(t *T.T) {
+		ts := pagingServer(t, 25, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.OffsetPager(context.Background(), MustBuildDQL("L::nginx", WithLimit(10)))
+
+		ns := collectPoints(t, p)
+		require.Len(t, ns, 25)
+		for i, n := range ns {
+			assert.Equal(t, float64(i), n)
+		}
+
+		assert.Equal(t, 3, p.Pages())
+		received := ts.queries()
+		require.Len(t, received, 3)
+		assert.Equal(t, 20, received[2].Offset)
+	})
+
+	t.Run("offset-max-rows", func(t *T.T) {
+		ts := pagingServer(t, 100, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.OffsetPager(context.Background(), MustBuildDQL("L::nginx"), WithPageSize(10), WithMaxRows(15))
+
+		assert.Len(t, collectPoints(t, p), 15)
+		received := ts.queries()
+		require.Len(t, received, 2)
+		assert.Equal(t, int64(5), received[1].Limit)
+	})
+
+	t.Run("series", func(t *T.T) {
+		ts := pagingServer(t, 7, true)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.SeriesPager(context.Background(), MustBuildDQL("M::cpu", WithSLimit(3)))
+
+		var hosts []string
+		for p.NextPage() {
+			for _, s := range p.Page().Series {
+				hosts = append(hosts, s.Tags["host"])
+			}
+		}
+
+		require.NoError(t, p.Err())
+		assert.Len(t, hosts, 7)
+		assert.Equal(t, "host-6", hosts[6])
+		assert.Equal(t, 3, p.Pages())
+		assert.Equal(t, 6, ts.queries()[2].SOffset)
+	})
+
+	t.Run("cursor", func(t *T.T) {
+		ts := pagingServer(t, 25, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.CursorPager(context.Background(), MustBuildDQL("L::nginx", WithCursorTime(1001)), WithPageSize(10))
+
+		ns := collectPoints(t, p)
+		require.Len(t, ns, 25)
+		assert.Equal(t, float64(24), ns[24])
+
+		received := ts.queries()
+		require.Len(t, received, 3)
+		assert.Equal(t, int64(1001), received[0].CursorTime)
+		assert.Equal(t, int64(991), received[1].CursorTime)
+	})
+}
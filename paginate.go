@@ -0,0 +1,230 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// A Paginator iterate rows of a DQL query page by page with search-after.
+// For example:
+//
+//	p := c.Paginate(ctx, MustBuildDQL("L::nginx"), WithPageSize(100))
+//	for p.Next() {
+//		row := p.Row()
+//		...
+//	}
+//	if err := p.Err(); err != nil {
+//		...
+//	}
+//
+// A Paginator is not safe for concurrent use.
+type Paginator struct {
+	pageConf
+
+	ctx context.Context
+	cli *Client
+	q   *dql
+
+	page     *DQLResult
+	rows     []*Row
+	rowIdx   int
+	fetched  int64 // rows fetched from backend
+	yielded  int64 // rows returned by Next()
+	pages    int
+	after    []any
+	lastPage bool
+	err      error
+}
+
+// pageConf is the configure shared by Paginator and pagers.
+type pageConf struct {
+	opts     []QueryOption
+	pageSize int64
+	maxRows  int64
+}
+
+func (pc *pageConf) apply(opts []PaginateOption) {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(pc)
+		}
+	}
+}
+
+// queryPage query a single page.
+func (pc *pageConf) queryPage(ctx context.Context, c *Client, page *dql) (*DQLResult, error) {
+	opts := append([]QueryOption{}, pc.opts...)
+	opts = append(opts, WithQueries(page), WithQueryError(true))
+
+	r, err := c.QueryContext(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Content) == 0 {
+		return nil, errors.New("dql query returned no content")
+	}
+
+	return r.Content[0], nil
+}
+
+// PaginateOption used to set various pagination options.
+type PaginateOption func(*pageConf)
+
+// WithPageSize set rows(or series for SeriesPager) of each page,
+// if not set, the limit(slimit) of the DQL used.
+func WithPageSize(n int64) PaginateOption {
+	return func(pc *pageConf) {
+		if n > 0 {
+			pc.pageSize = n
+		}
+	}
+}
+
+// WithMaxRows set max rows(or series for SeriesPager) returned.
+func WithMaxRows(n int64) PaginateOption {
+	return func(pc *pageConf) {
+		if n > 0 {
+			pc.maxRows = n
+		}
+	}
+}
+
+// WithPageQueryOptions set query options(such as WithToken and WithHTTPS)
+// for each page request.
+func WithPageQueryOptions(opts ...QueryOption) PaginateOption {
+	return func(pc *pageConf) {
+		pc.opts = append(pc.opts, opts...)
+	}
+}
+
+// Paginate create a Paginator on the DQL query q. The paginator follows
+// search-after of each page, until the search-after exhausted, a page
+// returned fewer rows than the page size, total hits reached, or max
+// rows reached.
+func (c *Client) Paginate(ctx context.Context, q *dql, opts ...PaginateOption) *Paginator {
+	p := &Paginator{
+		ctx: ctx,
+		cli: c,
+		q:   q,
+	}
+
+	p.apply(opts)
+
+	if p.pageSize == 0 {
+		p.pageSize = q.Limit
+	}
+
+	p.after = q.SearchAfter
+
+	return p
+}
+
+// Next advance to next row, it returns false if no more rows or error
+// occurred, and we should check the error by Err().
+func (p *Paginator) Next() bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.maxRows > 0 && p.yielded >= p.maxRows {
+		return false
+	}
+
+	for p.rowIdx >= len(p.rows) {
+		if p.lastPage {
+			return false
+		}
+
+		if err := p.fetch(); err != nil {
+			p.err = err
+			return false
+		}
+	}
+
+	p.rowIdx++
+	p.yielded++
+	return true
+}
+
+// Row get current row. The row contains a single value within Values,
+// and name/tags/columns of the series it belongs to.
+func (p *Paginator) Row() *Row {
+	if p.rowIdx == 0 || p.rowIdx > len(p.rows) {
+		return nil
+	}
+	return p.rows[p.rowIdx-1]
+}
+
+// Page get current page's query result.
+func (p *Paginator) Page() *DQLResult {
+	return p.page
+}
+
+// Pages get number of pages fetched.
+func (p *Paginator) Pages() int {
+	return p.pages
+}
+
+// Err get the error during pagination.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+func (p *Paginator) fetch() error {
+	page := *p.q
+	page.SearchAfter = append([]any{}, p.after...)
+	if p.pageSize > 0 {
+		page.Limit = p.pageSize
+	}
+
+	// do not fetch more rows than required
+	if left := p.maxRows - p.fetched; p.maxRows > 0 && (page.Limit == 0 || left < page.Limit) {
+		page.Limit = left
+	}
+
+	res, err := p.queryPage(p.ctx, p.cli, &page)
+	if err != nil {
+		return err
+	}
+
+	p.page = res
+	p.pages++
+	p.rows = p.rows[:0]
+	p.rowIdx = 0
+
+	var n int64
+	for _, s := range res.Series {
+		for _, v := range s.Values {
+			p.rows = append(p.rows, &Row{
+				Name:    s.Name,
+				Tags:    s.Tags,
+				Columns: s.Columns,
+				Values:  [][]any{v},
+				Partial: s.Partial,
+			})
+			n++
+		}
+	}
+
+	p.fetched += n
+
+	switch {
+	case len(res.SearchAfter) == 0,
+		n == 0,
+		page.Limit > 0 && n < page.Limit,
+		res.Totalhits > 0 && p.fetched >= res.Totalhits,
+		p.maxRows > 0 && p.fetched >= p.maxRows,
+		reflect.DeepEqual(res.SearchAfter, p.after): // search-after not moving
+		p.lastPage = true
+	}
+
+	p.after = res.SearchAfter
+	return nil
+}
@@ -0,0 +1,182 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	T "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryServer is a fake Datakit, each DQL received is recorded and answered
+// by handle, which is called with the lock held.
+type queryServer struct {
+	*httptest.Server
+
+	mtx      sync.Mutex
+	received []*dql
+}
+
+func newQueryServer(t *T.T, handle func(d *dql) *DQLResult) *queryServer {
+	t.Helper()
+
+	s := &queryServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q query
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			t.Errorf("decode query: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mtx.Lock()
+		d := q.Queries[0]
+		s.received = append(s.received, d)
+		res := handle(d)
+		s.mtx.Unlock()
+
+		json.NewEncoder(w).Encode(&Result{Content: []*DQLResult{res}}) //nolint:errcheck
+	}))
+
+	return s
+}
+
+// queries get DQLs received so far.
+func (s *queryServer) queries() []*dql {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*dql(nil), s.received...)
+}
+
+func (s *queryServer) client() *Client {
+	return NewClient(s.Listener.Addr().String())
+}
+
+// searchAfterServer serve total rows, and search-after is the index of the
+// last row within the page.
+func searchAfterServer(t *T.T, total int, totalHits bool) *queryServer {
+	t.Helper()
+
+	return newQueryServer(t, func(d *dql) *DQLResult {
+		start := 0
+		if len(d.SearchAfter) > 0 {
+			start = int(d.SearchAfter[0].(float64)) + 1
+		}
+
+		row := &Row{Name: "nginx", Columns: []string{"time", "n"}}
+		for i := start; i < total && int64(len(row.Values)) < d.Limit; i++ {
+			row.Values = append(row.Values, []any{1680172008117 + i, i})
+		}
+
+		res := &DQLResult{Series: []*Row{row}}
+		if n := len(row.Values); n > 0 {
+			res.SearchAfter = []any{start + n - 1}
+		}
+		if totalHits {
+			res.Totalhits = int64(total)
+		}
+		return res
+	})
+}
+
+func TestPaginate(t *T.T) {
+	t.Run("all", func(t *T.T) {
+		ts := searchAfterServer(t, 25, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.Paginate(context.Background(), MustBuildDQL("L::nginx"), WithPageSize(10))
+
+		var ns []float64
+		for p.Next() {
+			ns = append(ns, p.Row().Values[0][1].(float64))
+		}
+
+		require.NoError(t, p.Err())
+		require.Len(t, ns, 25)
+		for i, n := range ns {
+			assert.Equal(t, float64(i), n)
+		}
+
+		assert.Equal(t, 3, p.Pages())
+		received := ts.queries()
+		assert.Empty(t, received[0].SearchAfter)
+		assert.Equal(t, []any{float64(9)}, received[1].SearchAfter)
+		assert.Equal(t, int64(10), received[1].Limit)
+	})
+
+	t.Run("exact-pages", func(t *T.T) {
+		ts := searchAfterServer(t, 20, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.Paginate(context.Background(), MustBuildDQL("L::nginx", WithLimit(10)))
+
+		n := 0
+		for p.Next() {
+			n++
+		}
+
+		require.NoError(t, p.Err())
+		assert.Equal(t, 20, n)
+		assert.Len(t, ts.queries(), 3) // the last page is empty
+	})
+
+	t.Run("total-hits", func(t *T.T) {
+		ts := searchAfterServer(t, 20, true)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.Paginate(context.Background(), MustBuildDQL("L::nginx"), WithPageSize(10))
+
+		n := 0
+		for p.Next() {
+			n++
+		}
+
+		require.NoError(t, p.Err())
+		assert.Equal(t, 20, n)
+		assert.Len(t, ts.queries(), 2)
+	})
+
+	t.Run("max-rows", func(t *T.T) {
+		ts := searchAfterServer(t, 100, false)
+		defer ts.Close()
+
+		c := ts.client()
+		p := c.Paginate(context.Background(), MustBuildDQL("L::nginx"), WithPageSize(10), WithMaxRows(15))
+
+		n := 0
+		for p.Next() {
+			n++
+		}
+
+		require.NoError(t, p.Err())
+		assert.Equal(t, 15, n)
+		received := ts.queries()
+		require.Len(t, received, 2)
+		assert.Equal(t, int64(5), received[1].Limit)
+	})
+
+	t.Run("error", func(t *T.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error_code":"dql.parse_error"}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String())
+		p := c.Paginate(context.Background(), MustBuildDQL("L::nginx"))
+
+		assert.False(t, p.Next())
+		assert.ErrorIs(t, p.Err(), ErrParse)
+	})
+}
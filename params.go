@@ -0,0 +1,153 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithParams bind named parameters like $host within the DQL:
+//
+//	BuildDQL("L::nginx { host = $host, status IN $codes } [$last]",
+//		WithParams(map[string]any{
+//			"host":  h,
+//			"codes": []int{500, 502},
+//			"last":  time.Hour,
+//		}))
+//
+// Values are formatted as DQL literals:
+//
+//   - string quoted and escaped, such as 'abc'
+//   - integers, floats and bool as is, NaN and Inf are rejected
+//   - nil as nil
+//   - time.Duration as duration, such as 1h30m
+//   - time.Time as UNIX timestamp in ms
+//   - slices as list, such as ['a', 'b'] for IN
+//   - Expr as is, use Field(name) to bind an identifier, it's quoted
+//     by backquote if needed, such as `host name`
+//
+// Other types are rejected, and missing parameters within the DQL are
+// error too. Parameters within strings, quoted identifiers and comments
+// are not replaced. The error returned by BuildDQL.
+func WithParams(params map[string]any) DQLOption {
+	return func(q *dql) {
+		q.bind(func(name string) (any, bool) {
+			v, ok := params[name]
+			return v, ok
+		})
+	}
+}
+
+// WithArgs bind positional parameters $1, $2... within the DQL, $1 is
+// args[0]. Values are formatted the same as WithParams.
+func WithArgs(args ...any) DQLOption {
+	return func(q *dql) {
+		q.bind(func(name string) (any, bool) {
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 1 || i > len(args) {
+				return nil, false
+			}
+			return args[i-1], true
+		})
+	}
+}
+
+func (q *dql) bind(lookup func(string) (any, bool)) {
+	if q.err != nil {
+		return
+	}
+
+	s, err := bindParams(q.DQL, lookup)
+	if err != nil {
+		q.setErr(err)
+		return
+	}
+
+	q.DQL = s
+}
+
+// bindParams replace $name within DQL with the value formatted as literal.
+func bindParams(s string, lookup func(string) (any, bool)) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch c {
+		case '\'', '"', '`': // copy the quoted as is
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				j = len(s) - 1
+			}
+			sb.WriteString(s[i : j+1])
+			i = j
+
+		case '#': // comment until end of line
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				sb.WriteString(s[i:])
+				i = len(s)
+			} else {
+				sb.WriteString(s[i : i+j])
+				i += j - 1
+			}
+
+		case '$':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || isAlnum(s[j])) {
+				j++
+			}
+
+			name := s[i+1 : j]
+			if name == "" {
+				return "", fmt.Errorf("%w: empty parameter name at %d", ErrInvalidOption, i)
+			}
+
+			v, ok := lookup(name)
+			if !ok {
+				return "", fmt.Errorf("%w: missing parameter $%s", ErrInvalidOption, name)
+			}
+
+			lit, err := paramLiteral(v)
+			if err != nil {
+				return "", fmt.Errorf("%w: parameter $%s: %s", ErrInvalidOption, name, err)
+			}
+
+			sb.WriteString(lit)
+			i = j - 1
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func paramLiteral(v any) (string, error) {
+	node, err := literal(v)
+	if err != nil {
+		return "", err
+	}
+
+	if node == nil {
+		return "", fmt.Errorf("empty expression")
+	}
+
+	return node.String(), nil
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
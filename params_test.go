@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	"math"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParams(t *T.T) {
+	t.Run("named", func(t *T.T) {
+		q, err := BuildDQL("L::nginx:($f) { host = $host, status IN $codes, ok = $ok, x = $x, y = $nil } [$start:$end:$step]",
+			WithParams(map[string]any{
+				"f":     Field("host name"),
+				"host":  "it's } OR 1=1 #",
+				"codes": []int{500, 502},
+				"ok":    true,
+				"x":     1.5,
+				"nil":   nil,
+				"start": time.UnixMilli(1672502400000),
+				"end":   time.UnixMilli(1672506000000),
+				"step":  90 * time.Second,
+			}), WithValidate(true))
+		require.NoError(t, err)
+
+		assert.Equal(t, "L::nginx:(`host name`) { host = 'it\\'s } OR 1=1 #', status IN [500, 502], ok = true, x = 1.5, y = nil }"+
+			" [1672502400000:1672506000000:1m30s]", q.DQL)
+
+		ast, err := parser.ParseQuery(q.DQL)
+		require.NoError(t, err)
+		assert.Len(t, ast.Where, 5)
+	})
+
+	t.Run("positional", func(t *T.T) {
+		q, err := BuildDQL("L::nginx { host = $1, status = $2 } LIMIT $3", WithArgs("a", "b", 10))
+		require.NoError(t, err)
+		assert.Equal(t, "L::nginx { host = 'a', status = 'b' } LIMIT 10", q.DQL)
+	})
+
+	t.Run("not-replaced", func(t *T.T) {
+		s := "L::nginx { host = '$host', `$host` = \"\\\"$host\" } # $host"
+		q, err := BuildDQL(s, WithParams(map[string]any{"host": "x"}))
+		require.NoError(t, err)
+		assert.Equal(t, s, q.DQL)
+	})
+
+	t.Run("errors", func(t *T.T) {
+		cases := []struct {
+			dql    string
+			params map[string]any
+		}{
+			{"L::nginx { host = $host }", nil},
+			{"L::nginx { host = $ }", nil},
+			{"L::nginx { host = $host }", map[string]any{"host": struct{}{}}},
+			{"L::nginx { host = $host }", map[string]any{"host": math.Inf(1)}},
+			{"L::nginx { host = $host }", map[string]any{"host": "\xff"}},
+			{"L::nginx { host = $host }", map[string]any{"host": [][]int{{1}}}},
+			{"L::nginx { host = $host }", map[string]any{"host": Func("a b")}},
+			{"L::nginx [$du]", map[string]any{"du": -time.Hour}},
+			{"L::nginx [$du]", map[string]any{"du": time.Duration(0)}},
+		}
+
+		for _, tc := range cases {
+			_, err := BuildDQL(tc.dql, WithParams(tc.params))
+			require.Error(t, err, "%v", tc.params)
+			assert.True(t, errors.Is(err, ErrInvalidOption))
+		}
+
+		_, err := BuildDQL("L::nginx { host = $2 }", WithArgs("a"))
+		assert.Error(t, err)
+
+		q, err := BuildDQL("L::nginx [$du]", WithParams(map[string]any{"du": time.Hour}))
+		require.NoError(t, err)
+		assert.Equal(t, "L::nginx [1h]", q.DQL)
+
+		assert.Panics(t, func() {
+			MustBuildDQL("L::nginx { host = $host }", WithParams(nil))
+		})
+	})
+}
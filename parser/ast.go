@@ -0,0 +1,633 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Pos is the position of a node within DQL input.
+type Pos struct {
+	Offset int // byte offset, start from 0
+	Line   int // start from 1
+	Column int // in runes, start from 1
+}
+
+// String used to get the position in line:column form.
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// A Node is a node of the AST. String of the node is the canonical DQL
+// of the node.
+type Node interface {
+	Pos() Pos
+	String() string
+}
+
+// An Expr is an expression node.
+type Expr interface {
+	Node
+	expr()
+}
+
+// A Stmt is a statement node, *Query or *CallStmt.
+type Stmt interface {
+	Node
+	stmt()
+}
+
+type node struct {
+	pos Pos
+}
+
+// Pos get the position of the node.
+func (n node) Pos() Pos {
+	return n.pos
+}
+
+// A Query is a DQL query statement:
+//
+//	namespace::sources:(targets) {where} [time-range] BY ... HAVING ...
+//	ORDER BY ... SORDER BY ... LIMIT n OFFSET n SLIMIT n SOFFSET n
+type Query struct {
+	node
+
+	// Namespace as it written, such as "M" or "metric", empty if omitted.
+	Namespace string
+
+	Sources   []*Source
+	Targets   []*Target
+	Where     []Expr // conditions separated by comma(AND)
+	TimeRange *TimeRange
+	GroupBy   []Expr
+	Having    Expr
+	OrderBy   []*OrderBy
+	SOrderBy  []*OrderBy
+
+	// Limits, nil if not set.
+	Limit   *int64
+	Offset  *int64
+	SLimit  *int64
+	SOffset *int64
+}
+
+func (*Query) stmt() {}
+
+// String implements Node. Clauses are in canonical order.
+func (q *Query) String() string {
+	var sb strings.Builder
+
+	if q.Namespace != "" {
+		sb.WriteString(NamespaceShort(q.Namespace) + "::")
+	}
+
+	for i, s := range q.Sources {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(s.String())
+	}
+
+	if len(q.Targets) > 0 {
+		arr := make([]string, 0, len(q.Targets))
+		for _, t := range q.Targets {
+			arr = append(arr, t.String())
+		}
+		sb.WriteString(":(" + strings.Join(arr, ", ") + ")")
+	}
+
+	for _, clause := range q.Clauses() {
+		sb.WriteString(" " + clause)
+	}
+
+	return sb.String()
+}
+
+// Clauses get clauses after sources and targets in canonical order, such
+// as "{host = 'abc'}", "[1h]", "BY host" and "LIMIT 10".
+func (q *Query) Clauses() []string {
+	var clauses []string
+
+	if len(q.Where) > 0 {
+		clauses = append(clauses, "{"+joinExprs(q.Where, ", ")+"}")
+	}
+
+	if q.TimeRange != nil {
+		clauses = append(clauses, q.TimeRange.String())
+	}
+
+	if len(q.GroupBy) > 0 {
+		clauses = append(clauses, "BY "+joinExprs(q.GroupBy, ", "))
+	}
+
+	if q.Having != nil {
+		clauses = append(clauses, "HAVING "+q.Having.String())
+	}
+
+	for _, x := range []struct {
+		kw    string
+		items []*OrderBy
+	}{
+		{"ORDER BY", q.OrderBy},
+		{"SORDER BY", q.SOrderBy},
+	} {
+		if len(x.items) == 0 {
+			continue
+		}
+
+		arr := make([]string, 0, len(x.items))
+		for _, o := range x.items {
+			arr = append(arr, o.String())
+		}
+		clauses = append(clauses, x.kw+" "+strings.Join(arr, ", "))
+	}
+
+	for _, x := range []struct {
+		kw string
+		n  *int64
+	}{
+		{"LIMIT", q.Limit},
+		{"OFFSET", q.Offset},
+		{"SLIMIT", q.SLimit},
+		{"SOFFSET", q.SOffset},
+	} {
+		if x.n != nil {
+			clauses = append(clauses, x.kw+" "+strconv.FormatInt(*x.n, 10))
+		}
+	}
+
+	return clauses
+}
+
+// A CallStmt is a function statement, such as show_measurement().
+type CallStmt struct {
+	node
+
+	Namespace string
+	Call      *Call
+}
+
+func (*CallStmt) stmt() {}
+
+// String implements Node.
+func (s *CallStmt) String() string {
+	if s.Namespace != "" {
+		return NamespaceShort(s.Namespace) + "::" + s.Call.String()
+	}
+	return s.Call.String()
+}
+
+// A Source is a data source of the query, a name, a regex or a subquery.
+type Source struct {
+	node
+
+	Name     string // name, or pattern if Regex
+	Regex    bool   // re('pattern')
+	Subquery *Query
+}
+
+// String implements Node.
+func (s *Source) String() string {
+	switch {
+	case s.Subquery != nil:
+		return "(" + s.Subquery.String() + ")"
+	case s.Regex:
+		return "re(" + QuoteString(s.Name) + ")"
+	default:
+		return QuoteIdent(s.Name)
+	}
+}
+
+// A Target is a projection of the query, with optional alias.
+type Target struct {
+	node
+
+	Expr  Expr
+	Alias string
+}
+
+// String implements Node.
+func (t *Target) String() string {
+	if t.Alias != "" {
+		return t.Expr.String() + " AS " + QuoteIdent(t.Alias)
+	}
+	return t.Expr.String()
+}
+
+// A TimeRange is the [start:end:interval:rollup] of the query.
+type TimeRange struct {
+	node
+
+	Start    *TimeValue
+	End      *TimeValue
+	Interval *TimeValue
+	Rollup   string
+}
+
+// String implements Node.
+func (tr *TimeRange) String() string {
+	parts := []string{"", "", "", tr.Rollup}
+	for i, tv := range []*TimeValue{tr.Start, tr.End, tr.Interval} {
+		if tv != nil {
+			parts[i] = tv.String()
+		}
+	}
+
+	// trim trailing empty parts, [1h:] is the same as [1h]
+	n := len(parts)
+	for n > 1 && parts[n-1] == "" {
+		n--
+	}
+
+	return "[" + strings.Join(parts[:n], ":") + "]"
+}
+
+// A TimeValue is a time within TimeRange, a relative duration(such as 1h
+// means 1 hour ago, or interval) or UNIX timestamp in ms.
+type TimeValue struct {
+	node
+
+	Raw       string
+	Duration  time.Duration // for relative time and interval
+	Timestamp int64         // UNIX timestamp in ms
+	Absolute  bool
+}
+
+// String implements Node.
+func (tv *TimeValue) String() string {
+	return tv.Raw
+}
+
+// Time get the time value relative to now.
+func (tv *TimeValue) Time(now time.Time) time.Time {
+	if tv.Absolute {
+		return time.UnixMilli(tv.Timestamp)
+	}
+	return now.Add(-tv.Duration)
+}
+
+// An OrderBy is a ORDER BY(or SORDER BY) item.
+type OrderBy struct {
+	node
+
+	Expr  Expr
+	Order string // ASC, DESC, or empty if not set
+}
+
+// String implements Node.
+func (o *OrderBy) String() string {
+	if o.Order != "" {
+		return o.Expr.String() + " " + o.Order
+	}
+	return o.Expr.String()
+}
+
+// An Ident is an identifier, such as field name.
+type Ident struct {
+	node
+
+	Name   string
+	Quoted bool // quoted by backquote
+}
+
+func (*Ident) expr() {}
+
+// String implements Node.
+func (i *Ident) String() string {
+	return QuoteIdent(i.Name)
+}
+
+// A StringLit is a string literal.
+type StringLit struct {
+	node
+	Value string
+}
+
+func (*StringLit) expr() {}
+
+// String implements Node.
+func (s *StringLit) String() string {
+	return QuoteString(s.Value)
+}
+
+// A NumberLit is a number literal.
+type NumberLit struct {
+	node
+
+	Raw   string
+	Value float64
+	IsInt bool
+}
+
+func (*NumberLit) expr() {}
+
+// String implements Node.
+func (n *NumberLit) String() string {
+	return n.Raw
+}
+
+// A DurationLit is a duration literal, such as 1h.
+type DurationLit struct {
+	node
+
+	Raw   string
+	Value time.Duration
+}
+
+func (*DurationLit) expr() {}
+
+// String implements Node.
+func (d *DurationLit) String() string {
+	return d.Raw
+}
+
+// A BoolLit is true or false.
+type BoolLit struct {
+	node
+	Value bool
+}
+
+func (*BoolLit) expr() {}
+
+// String implements Node.
+func (b *BoolLit) String() string {
+	if b.Value {
+		return "true"
+	}
+	return "false"
+}
+
+// A NilLit is nil(or null).
+type NilLit struct {
+	node
+}
+
+func (*NilLit) expr() {}
+
+// String implements Node.
+func (*NilLit) String() string {
+	return "nil"
+}
+
+// A ListLit is a list, such as ['a', 'b'].
+type ListLit struct {
+	node
+	Elems []Expr
+}
+
+func (*ListLit) expr() {}
+
+// String implements Node.
+func (l *ListLit) String() string {
+	return "[" + joinExprs(l.Elems, ", ") + "]"
+}
+
+// A Star is * within targets or function args.
+type Star struct {
+	node
+}
+
+func (*Star) expr() {}
+
+// String implements Node.
+func (*Star) String() string {
+	return "*"
+}
+
+// A Call is a function call.
+type Call struct {
+	node
+
+	Name string
+	Args []Expr
+}
+
+func (*Call) expr() {}
+
+// String implements Node.
+func (c *Call) String() string {
+	return c.Name + "(" + joinExprs(c.Args, ", ") + ")"
+}
+
+// A BinaryExpr is a binary expression. Op is the canonical operator:
+// AND, OR, =, !=, <, <=, >, >=, IN, NOT IN, +, -, *, / and %.
+type BinaryExpr struct {
+	node
+
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+func (*BinaryExpr) expr() {}
+
+// String implements Node.
+func (b *BinaryExpr) String() string {
+	return b.LHS.String() + " " + b.Op + " " + b.RHS.String()
+}
+
+// A UnaryExpr is NOT x or -x.
+type UnaryExpr struct {
+	node
+
+	Op string
+	X  Expr
+}
+
+func (*UnaryExpr) expr() {}
+
+// String implements Node.
+func (u *UnaryExpr) String() string {
+	if u.Op == "NOT" {
+		return "NOT " + u.X.String()
+	}
+	return u.Op + u.X.String()
+}
+
+// A ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	node
+	X Expr
+}
+
+func (*ParenExpr) expr() {}
+
+// String implements Node.
+func (p *ParenExpr) String() string {
+	return "(" + p.X.String() + ")"
+}
+
+func joinExprs(exprs []Expr, sep string) string {
+	arr := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		arr = append(arr, e.String())
+	}
+	return strings.Join(arr, sep)
+}
+
+// IsIdent check if s is a valid unquoted identifier.
+func IsIdent(s string) bool {
+	if s == "" || IsKeyword(s) {
+		return false
+	}
+
+	for i, r := range s {
+		if i == 0 && !isIdentStart(r) || !isIdentPart(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// QuoteIdent quote s with backquote if it's not a valid unquoted identifier.
+func QuoteIdent(s string) string {
+	if IsIdent(s) {
+		return s
+	}
+	return "`" + escape(s, '`') + "`"
+}
+
+// QuoteString quote s as DQL string with single quote.
+func QuoteString(s string) string {
+	return "'" + escape(s, '\'') + "'"
+}
+
+func escape(s string, quote byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', quote:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// namespaces are short and long names of DQL namespaces.
+var namespaces = map[string]string{
+	"M":  "metric",
+	"L":  "logging",
+	"O":  "object",
+	"CO": "custom_object",
+	"E":  "event",
+	"T":  "tracing",
+	"R":  "rum",
+	"S":  "security",
+	"N":  "network",
+	"P":  "profiling",
+	"BL": "backup_log",
+}
+
+// NamespaceShort get the short name of namespace ns(case-insensitive),
+// such as M for metric. Empty returned if ns is unknown.
+func NamespaceShort(ns string) string {
+	up := strings.ToUpper(ns)
+	if _, ok := namespaces[up]; ok {
+		return up
+	}
+
+	for short, long := range namespaces {
+		if strings.EqualFold(long, ns) {
+			return short
+		}
+	}
+
+	return ""
+}
+
+// ParseDuration parse DQL duration, such as 1h30m, 7d, 1w and 1y. Besides
+// units of time.ParseDuration, d(24h), w(7d) and y(365d) are supported.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	rest := s
+
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		rest = rest[i:]
+
+		var unit time.Duration
+		for _, u := range durationUnits {
+			if strings.HasPrefix(rest, u) {
+				unit = unitDurations[u]
+				rest = rest[len(u):]
+				break
+			}
+		}
+
+		if unit == 0 {
+			return 0, fmt.Errorf("invalid duration %q: missing unit", s)
+		}
+
+		total += time.Duration(n) * unit
+	}
+
+	return total, nil
+}
+
+var unitDurations = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// FormatDuration format du as DQL duration, such as 1h30m and 7d. Units
+// w and y are not used, and zero formatted as 0s.
+func FormatDuration(du time.Duration) string {
+	if du == 0 {
+		return "0s"
+	}
+
+	var sb strings.Builder
+	if du < 0 {
+		sb.WriteByte('-')
+		du = -du
+	}
+
+	for _, u := range []string{"d", "h", "m", "s", "ms", "us", "ns"} {
+		unit := unitDurations[u]
+		if n := du / unit; n > 0 {
+			sb.WriteString(strconv.FormatInt(int64(n), 10) + u)
+			du -= n * unit
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,461 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A TokenType is the type of lexical token.
+type TokenType int
+
+// Token types.
+const (
+	EOF TokenType = iota
+	ILLEGAL
+
+	IDENT     // cpu, __docid, host.name
+	QUOTEDENT // `some field`
+	STRING    // 'abc', "abc"
+	NUMBER    // 123, 1.5, 1e3
+	DURATION  // 1h, 30d, 100ms
+	KEYWORD   // AND, BY, LIMIT...
+	DOUBLECOLON
+	COLON
+	COMMA
+	SEMICOLON
+	LPAREN
+	RPAREN
+	LBRACKET
+	RBRACKET
+	LBRACE
+	RBRACE
+	EQ  // =
+	EQ2 // ==
+	NEQ // != or <>
+	LT
+	LTE
+	GT
+	GTE
+	ADD
+	SUB
+	MUL
+	DIV
+	MOD
+	LAND // &&
+	LOR  // ||
+	NOT  // !
+)
+
+var tokenNames = map[TokenType]string{
+	EOF:         "EOF",
+	ILLEGAL:     "ILLEGAL",
+	IDENT:       "identifier",
+	QUOTEDENT:   "quoted identifier",
+	STRING:      "string",
+	NUMBER:      "number",
+	DURATION:    "duration",
+	KEYWORD:     "keyword",
+	DOUBLECOLON: "'::'",
+	COLON:       "':'",
+	COMMA:       "','",
+	SEMICOLON:   "';'",
+	LPAREN:      "'('",
+	RPAREN:      "')'",
+	LBRACKET:    "'['",
+	RBRACKET:    "']'",
+	LBRACE:      "'{'",
+	RBRACE:      "'}'",
+	EQ:          "'='",
+	EQ2:         "'=='",
+	NEQ:         "'!='",
+	LT:          "'<'",
+	LTE:         "'<='",
+	GT:          "'>'",
+	GTE:         "'>='",
+	ADD:         "'+'",
+	SUB:         "'-'",
+	MUL:         "'*'",
+	DIV:         "'/'",
+	MOD:         "'%'",
+	LAND:        "'&&'",
+	LOR:         "'||'",
+	NOT:         "'!'",
+}
+
+// String used to get the token type in string representation.
+func (t TokenType) String() string {
+	if s, ok := tokenNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// Keywords of DQL, case-insensitive.
+var keywords = map[string]bool{
+	"AND":     true,
+	"OR":      true,
+	"NOT":     true,
+	"IN":      true,
+	"AS":      true,
+	"BY":      true,
+	"ORDER":   true,
+	"SORDER":  true,
+	"ASC":     true,
+	"DESC":    true,
+	"LIMIT":   true,
+	"OFFSET":  true,
+	"SLIMIT":  true,
+	"SOFFSET": true,
+	"HAVING":  true,
+	"TRUE":    true,
+	"FALSE":   true,
+	"NIL":     true,
+	"NULL":    true,
+}
+
+// IsKeyword check if s is a DQL keyword.
+func IsKeyword(s string) bool {
+	return keywords[strings.ToUpper(s)]
+}
+
+// Keywords get all DQL keywords in upper case.
+func Keywords() []string {
+	arr := make([]string, 0, len(keywords))
+	for k := range keywords {
+		arr = append(arr, k)
+	}
+	return arr
+}
+
+// durationUnits are units of duration literal, longer unit first.
+var durationUnits = []string{"ns", "us", "ms", "s", "m", "h", "d", "w", "y"}
+
+// A Token is a lexical token.
+type Token struct {
+	Type TokenType
+	Pos  Pos
+
+	// Text is the raw text of the token. For STRING and QUOTEDENT,
+	// Value is the unquoted value, for KEYWORD, Value is in upper case,
+	// for others, Value is the same as Text.
+	Text  string
+	Value string
+}
+
+type lexer struct {
+	input string
+	pos   int
+	line  int
+	col   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input, line: 1, col: 1}
+}
+
+func (l *lexer) curPos() Pos {
+	return Pos{Offset: l.pos, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) peekRune() rune {
+	if l.pos >= len(l.input) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return r
+}
+
+func (l *lexer) peekAt(n int) byte {
+	if l.pos+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos+n]
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func (l *lexer) skipSpacesAndComments() {
+	for l.pos < len(l.input) {
+		r := l.peekRune()
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '#': // comment until end of line
+			for l.pos < len(l.input) && l.peekRune() != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// next get next token. On lexical error, an ILLEGAL token returned,
+// and it's Value is the error message.
+func (l *lexer) next() Token {
+	l.skipSpacesAndComments()
+
+	start := l.curPos()
+	tok := func(t TokenType) Token {
+		text := l.input[start.Offset:l.pos]
+		return Token{Type: t, Pos: start, Text: text, Value: text}
+	}
+
+	if l.pos >= len(l.input) {
+		return Token{Type: EOF, Pos: start}
+	}
+
+	r := l.peekRune()
+
+	switch {
+	case isIdentStart(r):
+		return l.ident(start)
+
+	case r == '`':
+		return l.quoted(start, '`', QUOTEDENT)
+
+	case r == '\'' || r == '"':
+		return l.quoted(start, byte(r), STRING)
+
+	case r < utf8.RuneSelf && isDigit(byte(r)),
+		r == '.' && isDigit(l.peekAt(1)):
+		return l.number(start)
+	}
+
+	l.advance()
+	switch r {
+	case ':':
+		if l.peekRune() == ':' {
+			l.advance()
+			return tok(DOUBLECOLON)
+		}
+		return tok(COLON)
+	case ',':
+		return tok(COMMA)
+	case ';':
+		return tok(SEMICOLON)
+	case '(':
+		return tok(LPAREN)
+	case ')':
+		return tok(RPAREN)
+	case '[':
+		return tok(LBRACKET)
+	case ']':
+		return tok(RBRACKET)
+	case '{':
+		return tok(LBRACE)
+	case '}':
+		return tok(RBRACE)
+	case '=':
+		if l.peekRune() == '=' {
+			l.advance()
+			return tok(EQ2)
+		}
+		return tok(EQ)
+	case '!':
+		if l.peekRune() == '=' {
+			l.advance()
+			return tok(NEQ)
+		}
+		return tok(NOT)
+	case '<':
+		switch l.peekRune() {
+		case '=':
+			l.advance()
+			return tok(LTE)
+		case '>':
+			l.advance()
+			return tok(NEQ)
+		}
+		return tok(LT)
+	case '>':
+		if l.peekRune() == '=' {
+			l.advance()
+			return tok(GTE)
+		}
+		return tok(GT)
+	case '+':
+		return tok(ADD)
+	case '-':
+		return tok(SUB)
+	case '*':
+		return tok(MUL)
+	case '/':
+		return tok(DIV)
+	case '%':
+		return tok(MOD)
+	case '&':
+		if l.peekRune() == '&' {
+			l.advance()
+			return tok(LAND)
+		}
+	case '|':
+		if l.peekRune() == '|' {
+			l.advance()
+			return tok(LOR)
+		}
+	}
+
+	t := tok(ILLEGAL)
+	t.Value = fmt.Sprintf("unexpected character %q", r)
+	return t
+}
+
+func (l *lexer) ident(start Pos) Token {
+	for l.pos < len(l.input) && isIdentPart(l.peekRune()) {
+		l.advance()
+	}
+
+	text := l.input[start.Offset:l.pos]
+	if IsKeyword(text) {
+		return Token{Type: KEYWORD, Pos: start, Text: text, Value: strings.ToUpper(text)}
+	}
+
+	return Token{Type: IDENT, Pos: start, Text: text, Value: text}
+}
+
+func (l *lexer) quoted(start Pos, quote byte, typ TokenType) Token {
+	l.advance() // skip leading quote
+
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return Token{
+				Type:  ILLEGAL,
+				Pos:   start,
+				Text:  l.input[start.Offset:l.pos],
+				Value: "unterminated " + typ.String(),
+			}
+		}
+
+		r := l.advance()
+		switch {
+		case r == rune(quote):
+			return Token{Type: typ, Pos: start, Text: l.input[start.Offset:l.pos], Value: sb.String()}
+
+		case r == '\\' && l.pos < len(l.input):
+			esc := l.advance()
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '\\', '\'', '"', '`':
+				sb.WriteRune(esc)
+			default: // keep unknown escapes as is, e.g. regex `\d`
+				sb.WriteByte('\\')
+				sb.WriteRune(esc)
+			}
+
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func (l *lexer) number(start Pos) Token {
+	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+		l.advance()
+	}
+
+	isFloat := false
+	if l.peekAt(0) == '.' && isDigit(l.peekAt(1)) {
+		isFloat = true
+		l.advance()
+		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+			l.advance()
+		}
+	}
+
+	if c := l.peekAt(0); c == 'e' || c == 'E' {
+		n := 1
+		if c2 := l.peekAt(1); c2 == '+' || c2 == '-' {
+			n = 2
+		}
+		if isDigit(l.peekAt(n)) {
+			for i := 0; i < n; i++ {
+				l.advance()
+			}
+			for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+				l.advance()
+			}
+			isFloat = true
+		}
+	}
+
+	// duration: integer followed by unit, such as 1h or 1h30m
+	if !isFloat && l.durationUnit() {
+		for isDigit(l.peekAt(0)) {
+			save := *l
+			for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+				l.advance()
+			}
+			if !l.durationUnit() {
+				*l = save
+				break
+			}
+		}
+
+		text := l.input[start.Offset:l.pos]
+		return Token{Type: DURATION, Pos: start, Text: text, Value: text}
+	}
+
+	text := l.input[start.Offset:l.pos]
+	return Token{Type: NUMBER, Pos: start, Text: text, Value: text}
+}
+
+// durationUnit consume a duration unit. The unit should not followed by
+// identifier characters except digits of the next duration part.
+func (l *lexer) durationUnit() bool {
+	for _, unit := range durationUnits {
+		if !strings.HasPrefix(l.input[l.pos:], unit) {
+			continue
+		}
+
+		end := l.pos + len(unit)
+		if end < len(l.input) {
+			next, _ := utf8.DecodeRuneInString(l.input[end:])
+			if isIdentPart(next) && !unicode.IsDigit(next) {
+				continue
+			}
+		}
+
+		for i := 0; i < len(unit); i++ {
+			l.advance()
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,840 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package parser is a pure-Go parser of DQL. It parse DQL text into typed
+// AST, and the String of the AST is the canonical DQL text.
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An Error is a syntax error with position.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Parse parse a single DQL statement, a *Query or a *CallStmt. A trailing
+// semicolon is allowed.
+func Parse(input string) (Stmt, error) {
+	p := newParser(input)
+
+	stmt, err := p.parseStmt()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().Type == SEMICOLON {
+		p.next()
+	}
+
+	if err := p.expect(EOF); err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+// ParseQuery parse a DQL query statement, function statements such as
+// show_measurement() are rejected.
+func ParseQuery(input string) (*Query, error) {
+	stmt, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	q, ok := stmt.(*Query)
+	if !ok {
+		return nil, &Error{Pos: stmt.Pos(), Msg: "expected query, got function statement"}
+	}
+
+	return q, nil
+}
+
+// ParseExpr parse a single expression, such as a where condition.
+func ParseExpr(input string) (Expr, error) {
+	p := newParser(input)
+
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(EOF); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+type parser struct {
+	lex *lexer
+	buf []Token // lookahead tokens
+}
+
+func newParser(input string) *parser {
+	return &parser{lex: newLexer(input)}
+}
+
+// peekN get the n-th(start from 0) lookahead token.
+func (p *parser) peekN(n int) Token {
+	for len(p.buf) <= n {
+		p.buf = append(p.buf, p.lex.next())
+	}
+	return p.buf[n]
+}
+
+func (p *parser) peek() Token {
+	return p.peekN(0)
+}
+
+func (p *parser) next() Token {
+	t := p.peek()
+	p.buf = p.buf[1:]
+	return t
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.Type == KEYWORD && t.Value == kw
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// unexpected build error on current token.
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	if t.Type == ILLEGAL {
+		return p.errorf(t.Pos, "%s", t.Value)
+	}
+
+	if expected == "" {
+		return p.errorf(t.Pos, "unexpected %s", describe(t))
+	}
+	return p.errorf(t.Pos, "unexpected %s, expected %s", describe(t), expected)
+}
+
+func describe(t Token) string {
+	switch t.Type {
+	case EOF:
+		return "end of input"
+	case IDENT, QUOTEDENT, STRING, NUMBER, DURATION, KEYWORD:
+		return fmt.Sprintf("%s %s", t.Type, strconv.Quote(t.Text))
+	default:
+		return t.Type.String()
+	}
+}
+
+func (p *parser) expect(typ TokenType) error {
+	if p.peek().Type != typ {
+		return p.unexpected(typ.String())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return p.unexpected(kw)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseStmt() (Stmt, error) {
+	start := p.peek().Pos
+
+	ns := ""
+	if t := p.peek(); t.Type == IDENT && p.peekN(1).Type == DOUBLECOLON {
+		if NamespaceShort(t.Value) == "" {
+			return nil, p.errorf(t.Pos, "unknown namespace %q", t.Value)
+		}
+		ns = t.Value
+		p.next()
+		p.next()
+	}
+
+	// function statement, such as show_measurement()
+	if t := p.peek(); t.Type == IDENT && !strings.EqualFold(t.Value, "re") && p.peekN(1).Type == LPAREN {
+		call, err := p.parseCall()
+		if err != nil {
+			return nil, err
+		}
+		return &CallStmt{node: node{start}, Namespace: ns, Call: call}, nil
+	}
+
+	q, err := p.parseQueryBody(start)
+	if err != nil {
+		return nil, err
+	}
+	q.Namespace = ns
+
+	return q, nil
+}
+
+// parseQueryBody parse sources, targets and clauses of the query.
+func (p *parser) parseQueryBody(start Pos) (*Query, error) {
+	q := &Query{node: node{start}}
+
+	for {
+		src, err := p.parseSource()
+		if err != nil {
+			return nil, err
+		}
+		q.Sources = append(q.Sources, src)
+
+		if p.peek().Type != COMMA {
+			break
+		}
+		p.next()
+	}
+
+	if p.peek().Type == COLON {
+		p.next()
+
+		if err := p.expect(LPAREN); err != nil {
+			return nil, err
+		}
+
+		for {
+			t, err := p.parseTarget()
+			if err != nil {
+				return nil, err
+			}
+			q.Targets = append(q.Targets, t)
+
+			if p.peek().Type != COMMA {
+				break
+			}
+			p.next()
+		}
+
+		if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.parseClauses(q); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (p *parser) parseSource() (*Source, error) {
+	t := p.peek()
+
+	switch t.Type {
+	case IDENT:
+		p.next()
+
+		if !strings.EqualFold(t.Value, "re") || p.peek().Type != LPAREN {
+			return &Source{node: node{t.Pos}, Name: t.Value}, nil
+		}
+
+		p.next()
+		pat := p.peek()
+		if pat.Type != STRING && pat.Type != QUOTEDENT {
+			return nil, p.unexpected("regex pattern")
+		}
+		p.next()
+
+		if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+
+		return &Source{node: node{t.Pos}, Name: pat.Value, Regex: true}, nil
+
+	case QUOTEDENT, STRING:
+		p.next()
+		return &Source{node: node{t.Pos}, Name: t.Value}, nil
+
+	case LPAREN:
+		p.next()
+
+		sub, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+
+		q, ok := sub.(*Query)
+		if !ok {
+			return nil, p.errorf(sub.Pos(), "function statement can not be subquery")
+		}
+
+		if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+
+		return &Source{node: node{t.Pos}, Subquery: q}, nil
+
+	default:
+		return nil, p.unexpected("source")
+	}
+}
+
+func (p *parser) parseTarget() (*Target, error) {
+	start := p.peek().Pos
+
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Target{node: node{start}, Expr: e}
+
+	if p.isKeyword("AS") {
+		p.next()
+
+		alias := p.peek()
+		if alias.Type != IDENT && alias.Type != QUOTEDENT && alias.Type != STRING {
+			return nil, p.unexpected("alias")
+		}
+		p.next()
+		t.Alias = alias.Value
+	}
+
+	return t, nil
+}
+
+// parseClauses parse clauses after sources and targets. Clauses can be in
+// any order, but each at most once.
+func (p *parser) parseClauses(q *Query) error {
+	seen := map[string]bool{}
+
+	once := func(t Token, name string) error {
+		if seen[name] {
+			return p.errorf(t.Pos, "duplicate %s clause", name)
+		}
+		seen[name] = true
+		return nil
+	}
+
+	for {
+		t := p.peek()
+
+		var (
+			name string
+			fn   func() error
+		)
+
+		switch {
+		case t.Type == EOF, t.Type == SEMICOLON, t.Type == RPAREN:
+			return nil
+
+		case t.Type == LBRACE:
+			name, fn = "where", func() error { return p.parseWhere(q) }
+
+		case t.Type == LBRACKET:
+			name, fn = "time range", func() error {
+				tr, err := p.parseTimeRange()
+				q.TimeRange = tr
+				return err
+			}
+
+		case t.Type == KEYWORD && t.Value == "BY":
+			name, fn = "BY", func() error {
+				p.next()
+				var err error
+				q.GroupBy, err = p.parseExprList()
+				return err
+			}
+
+		case t.Type == KEYWORD && t.Value == "HAVING":
+			name, fn = "HAVING", func() error {
+				p.next()
+				var err error
+				q.Having, err = p.parseExpr()
+				return err
+			}
+
+		case t.Type == KEYWORD && t.Value == "ORDER":
+			name, fn = "ORDER BY", func() error {
+				var err error
+				q.OrderBy, err = p.parseOrderBy()
+				return err
+			}
+
+		case t.Type == KEYWORD && t.Value == "SORDER":
+			name, fn = "SORDER BY", func() error {
+				var err error
+				q.SOrderBy, err = p.parseOrderBy()
+				return err
+			}
+
+		case t.Type == KEYWORD && t.Value == "LIMIT":
+			name, fn = "LIMIT", func() error { return p.parseInt(&q.Limit) }
+
+		case t.Type == KEYWORD && t.Value == "OFFSET":
+			name, fn = "OFFSET", func() error { return p.parseInt(&q.Offset) }
+
+		case t.Type == KEYWORD && t.Value == "SLIMIT":
+			name, fn = "SLIMIT", func() error { return p.parseInt(&q.SLimit) }
+
+		case t.Type == KEYWORD && t.Value == "SOFFSET":
+			name, fn = "SOFFSET", func() error { return p.parseInt(&q.SOffset) }
+
+		default:
+			return p.unexpected("")
+		}
+
+		if err := once(t, name); err != nil {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseWhere(q *Query) error {
+	p.next() // {
+
+	if p.peek().Type == RBRACE {
+		p.next()
+		return nil
+	}
+
+	conds, err := p.parseExprList()
+	if err != nil {
+		return err
+	}
+	q.Where = conds
+
+	return p.expect(RBRACE)
+}
+
+// parseTimeRange parse [start:end:interval:rollup], each part is optional.
+func (p *parser) parseTimeRange() (*TimeRange, error) {
+	start := p.next().Pos // [
+	tr := &TimeRange{node: node{start}}
+
+	part := 0
+	for {
+		t := p.peek()
+
+		switch t.Type {
+		case RBRACKET:
+			p.next()
+			return tr, nil
+
+		case COLON, DOUBLECOLON:
+			p.next()
+			part++
+			if t.Type == DOUBLECOLON {
+				part++
+			}
+
+			if part > 3 {
+				return nil, p.errorf(t.Pos, "too many parts within time range")
+			}
+			continue
+		}
+
+		switch part {
+		case 0, 1, 2:
+			tv, err := p.parseTimeValue(part == 2)
+			if err != nil {
+				return nil, err
+			}
+
+			switch part {
+			case 0:
+				tr.Start = tv
+			case 1:
+				tr.End = tv
+			case 2:
+				tr.Interval = tv
+			}
+
+		case 3:
+			if t.Type != IDENT {
+				return nil, p.unexpected("rollup function")
+			}
+			p.next()
+			tr.Rollup = t.Value
+		}
+
+		// next should be ':' or ']'
+		if nt := p.peek().Type; nt != COLON && nt != DOUBLECOLON && nt != RBRACKET {
+			return nil, p.unexpected("':' or ']'")
+		}
+	}
+}
+
+func (p *parser) parseTimeValue(interval bool) (*TimeValue, error) {
+	t := p.peek()
+
+	switch t.Type {
+	case DURATION:
+		p.next()
+
+		du, err := ParseDuration(t.Value)
+		if err != nil {
+			return nil, p.errorf(t.Pos, "%s", err)
+		}
+		return &TimeValue{node: node{t.Pos}, Raw: t.Text, Duration: du}, nil
+
+	case NUMBER:
+		if interval {
+			return nil, p.unexpected("duration")
+		}
+		p.next()
+
+		ms, err := strconv.ParseInt(t.Value, 10, 64)
+		if err != nil {
+			return nil, p.errorf(t.Pos, "invalid timestamp %q", t.Text)
+		}
+		return &TimeValue{node: node{t.Pos}, Raw: t.Text, Timestamp: ms, Absolute: true}, nil
+
+	default:
+		if interval {
+			return nil, p.unexpected("duration")
+		}
+		return nil, p.unexpected("duration or timestamp")
+	}
+}
+
+func (p *parser) parseOrderBy() ([]*OrderBy, error) {
+	p.next() // ORDER or SORDER
+	if err := p.expectKeyword("BY"); err != nil {
+		return nil, err
+	}
+
+	var items []*OrderBy
+	for {
+		start := p.peek().Pos
+
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		o := &OrderBy{node: node{start}, Expr: e}
+		if p.isKeyword("ASC") || p.isKeyword("DESC") {
+			o.Order = p.next().Value
+		}
+		items = append(items, o)
+
+		if p.peek().Type != COMMA {
+			return items, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseInt(dst **int64) error {
+	p.next() // keyword
+
+	t := p.peek()
+	if t.Type != NUMBER {
+		return p.unexpected("integer")
+	}
+
+	n, err := strconv.ParseInt(t.Value, 10, 64)
+	if err != nil {
+		return p.errorf(t.Pos, "invalid integer %q", t.Text)
+	}
+	p.next()
+
+	*dst = &n
+	return nil
+}
+
+func (p *parser) parseExprList() ([]Expr, error) {
+	var exprs []Expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+
+		if p.peek().Type != COMMA {
+			return exprs, nil
+		}
+		p.next()
+	}
+}
+
+// Operator precedence, from low to high:
+//
+//	OR ||
+//	AND &&
+//	NOT !
+//	= == != <> < <= > >= IN, NOT IN
+//	+ -
+//	* / %
+//	unary -
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("OR") || p.peek().Type == LOR {
+		t := p.next()
+
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{node: node{t.Pos}, Op: "OR", LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	lhs, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("AND") || p.peek().Type == LAND {
+		t := p.next()
+
+		rhs, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{node: node{t.Pos}, Op: "AND", LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.isKeyword("NOT") || p.peek().Type == NOT {
+		t := p.next()
+
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{node: node{t.Pos}, Op: "NOT", X: x}, nil
+	}
+
+	return p.parseCmp()
+}
+
+var cmpOps = map[TokenType]string{
+	EQ:  "=",
+	EQ2: "=",
+	NEQ: "!=",
+	LT:  "<",
+	LTE: "<=",
+	GT:  ">",
+	GTE: ">=",
+}
+
+func (p *parser) parseCmp() (Expr, error) {
+	lhs, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+
+	op, ok := cmpOps[t.Type]
+	switch {
+	case ok:
+		p.next()
+	case p.isKeyword("IN"):
+		p.next()
+		op = "IN"
+	case p.isKeyword("NOT") && p.peekN(1).Type == KEYWORD && p.peekN(1).Value == "IN":
+		p.next()
+		p.next()
+		op = "NOT IN"
+	default:
+		return lhs, nil
+	}
+
+	rhs, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinaryExpr{node: node{t.Pos}, Op: op, LHS: lhs, RHS: rhs}, nil
+}
+
+func (p *parser) parseAdd() (Expr, error) {
+	lhs, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Type == ADD || p.peek().Type == SUB {
+		t := p.next()
+
+		rhs, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{node: node{t.Pos}, Op: t.Text, LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseMul() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Type == MUL || p.peek().Type == DIV || p.peek().Type == MOD {
+		t := p.next()
+
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{node: node{t.Pos}, Op: t.Text, LHS: lhs, RHS: rhs}
+	}
+
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().Type == SUB {
+		t := p.next()
+
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{node: node{t.Pos}, Op: "-", X: x}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.peek()
+
+	switch t.Type {
+	case IDENT:
+		if p.peekN(1).Type == LPAREN {
+			return p.parseCall()
+		}
+		p.next()
+		return &Ident{node: node{t.Pos}, Name: t.Value}, nil
+
+	case QUOTEDENT:
+		p.next()
+		return &Ident{node: node{t.Pos}, Name: t.Value, Quoted: true}, nil
+
+	case STRING:
+		p.next()
+		return &StringLit{node: node{t.Pos}, Value: t.Value}, nil
+
+	case NUMBER:
+		p.next()
+
+		f, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			return nil, p.errorf(t.Pos, "invalid number %q", t.Text)
+		}
+		return &NumberLit{
+			node:  node{t.Pos},
+			Raw:   t.Text,
+			Value: f,
+			IsInt: !strings.ContainsAny(t.Text, ".eE"),
+		}, nil
+
+	case DURATION:
+		p.next()
+
+		du, err := ParseDuration(t.Value)
+		if err != nil {
+			return nil, p.errorf(t.Pos, "%s", err)
+		}
+		return &DurationLit{node: node{t.Pos}, Raw: t.Text, Value: du}, nil
+
+	case KEYWORD:
+		switch t.Value {
+		case "TRUE", "FALSE":
+			p.next()
+			return &BoolLit{node: node{t.Pos}, Value: t.Value == "TRUE"}, nil
+		case "NIL", "NULL":
+			p.next()
+			return &NilLit{node: node{t.Pos}}, nil
+		}
+
+	case LBRACKET:
+		p.next()
+
+		l := &ListLit{node: node{t.Pos}}
+		if p.peek().Type != RBRACKET {
+			elems, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			l.Elems = elems
+		}
+
+		if err := p.expect(RBRACKET); err != nil {
+			return nil, err
+		}
+		return l, nil
+
+	case LPAREN:
+		p.next()
+
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(RPAREN); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{node: node{t.Pos}, X: x}, nil
+
+	case MUL:
+		p.next()
+		return &Star{node: node{t.Pos}}, nil
+	}
+
+	return nil, p.unexpected("expression")
+}
+
+func (p *parser) parseCall() (*Call, error) {
+	t := p.next() // name
+	p.next()      // (
+
+	c := &Call{node: node{t.Pos}, Name: t.Value}
+
+	if p.peek().Type != RPAREN {
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		c.Args = args
+	}
+
+	if err := p.expect(RPAREN); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,265 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package parser
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *T.T) {
+	t.Run("basic", func(t *T.T) {
+		q, err := ParseQuery("L::testing_module:(name, coverage, message) { coverage > 0 } LIMIT 3")
+		require.NoError(t, err)
+
+		assert.Equal(t, "L", q.Namespace)
+		require.Len(t, q.Sources, 1)
+		assert.Equal(t, "testing_module", q.Sources[0].Name)
+		require.Len(t, q.Targets, 3)
+		assert.Equal(t, "coverage", q.Targets[1].Expr.(*Ident).Name)
+
+		require.Len(t, q.Where, 1)
+		cond := q.Where[0].(*BinaryExpr)
+		assert.Equal(t, ">", cond.Op)
+		assert.Equal(t, Pos{Offset: 46, Line: 1, Column: 47}, cond.LHS.Pos())
+
+		require.NotNil(t, q.Limit)
+		assert.Equal(t, int64(3), *q.Limit)
+
+		assert.Equal(t, "L::testing_module:(name, coverage, message) {coverage > 0} LIMIT 3", q.String())
+	})
+
+	t.Run("regex-source-and-alias", func(t *T.T) {
+		q, err := ParseQuery("L::re(`.*`):(fill(count(__docid), 0) AS count) [1d] BY status")
+		require.NoError(t, err)
+
+		assert.True(t, q.Sources[0].Regex)
+		assert.Equal(t, ".*", q.Sources[0].Name)
+
+		tgt := q.Targets[0]
+		assert.Equal(t, "count", tgt.Alias)
+		call := tgt.Expr.(*Call)
+		assert.Equal(t, "fill", call.Name)
+		assert.Len(t, call.Args, 2)
+
+		require.NotNil(t, q.TimeRange)
+		assert.Equal(t, 24*time.Hour, q.TimeRange.Start.Duration)
+		assert.Nil(t, q.TimeRange.End)
+
+		require.Len(t, q.GroupBy, 1)
+		assert.Equal(t, "status", q.GroupBy[0].String())
+
+		assert.Equal(t, "L::re('.*'):(fill(count(__docid), 0) AS count) [1d] BY status", q.String())
+	})
+
+	t.Run("function-in-where", func(t *T.T) {
+		q, err := ParseQuery("L::testing_module { message=query_string('datakit') } LIMIT 2")
+		require.NoError(t, err)
+
+		cond := q.Where[0].(*BinaryExpr)
+		assert.Equal(t, "=", cond.Op)
+		assert.Equal(t, "query_string", cond.RHS.(*Call).Name)
+		assert.Equal(t, "datakit", cond.RHS.(*Call).Args[0].(*StringLit).Value)
+	})
+
+	t.Run("comment-and-open-end", func(t *T.T) {
+		q, err := ParseQuery("L::some_source [1d:] # comment")
+		require.NoError(t, err)
+		assert.Equal(t, "L::some_source [1d]", q.String())
+	})
+
+	t.Run("case-insensitive-keywords", func(t *T.T) {
+		q, err := ParseQuery("M::cpu limit 1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), *q.Limit)
+		assert.Equal(t, "M::cpu LIMIT 1", q.String())
+	})
+
+	t.Run("full", func(t *T.T) {
+		q, err := ParseQuery(`metric::cpu, mem:(avg(usage) AS "avg usage", host)
+			{host != 'a' and (region IN ['cn', 'us'] || zone NOT IN ['z1']), usage >= 1.5}
+			[1672502400000:1672588800000:1m:avg]
+			BY host
+			ORDER BY time DESC
+			SORDER BY host ASC
+			LIMIT 10 OFFSET 20 SLIMIT 5 SOFFSET 1;`)
+		require.NoError(t, err)
+
+		assert.Len(t, q.Sources, 2)
+		assert.Equal(t, "avg usage", q.Targets[0].Alias)
+		assert.Len(t, q.Where, 2)
+
+		tr := q.TimeRange
+		assert.True(t, tr.Start.Absolute)
+		assert.Equal(t, int64(1672502400000), tr.Start.Timestamp)
+		assert.Equal(t, time.UnixMilli(1672588800000), tr.End.Time(time.Now()))
+		assert.Equal(t, time.Minute, tr.Interval.Duration)
+		assert.Equal(t, "avg", tr.Rollup)
+
+		assert.Equal(t, "DESC", q.OrderBy[0].Order)
+		assert.Equal(t, "ASC", q.SOrderBy[0].Order)
+		assert.Equal(t, int64(20), *q.Offset)
+		assert.Equal(t, int64(1), *q.SOffset)
+
+		assert.Equal(t, "M::cpu, mem:(avg(usage) AS `avg usage`, host)"+
+			" {host != 'a' AND (region IN ['cn', 'us'] OR zone NOT IN ['z1']), usage >= 1.5}"+
+			" [1672502400000:1672588800000:1m:avg] BY host ORDER BY time DESC SORDER BY host ASC"+
+			" LIMIT 10 OFFSET 20 SLIMIT 5 SOFFSET 1", q.String())
+
+		// canonical output can be parsed again
+		q2, err := ParseQuery(q.String())
+		require.NoError(t, err)
+		assert.Equal(t, q.String(), q2.String())
+	})
+
+	t.Run("time-range-empty-parts", func(t *T.T) {
+		q, err := ParseQuery("M::cpu [::5m]")
+		require.NoError(t, err)
+		assert.Nil(t, q.TimeRange.Start)
+		assert.Nil(t, q.TimeRange.End)
+		assert.Equal(t, 5*time.Minute, q.TimeRange.Interval.Duration)
+		assert.Equal(t, "M::cpu [::5m]", q.String())
+
+		q, err = ParseQuery("M::cpu [1h30m:10m]")
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Minute, q.TimeRange.Start.Duration)
+		assert.Equal(t, 10*time.Minute, q.TimeRange.End.Duration)
+	})
+
+	t.Run("subquery", func(t *T.T) {
+		q, err := ParseQuery("M::(M::cpu:(max(usage) AS u) BY host):(avg(u))")
+		require.NoError(t, err)
+
+		sub := q.Sources[0].Subquery
+		require.NotNil(t, sub)
+		assert.Equal(t, "M", sub.Namespace)
+		assert.Equal(t, "M::(M::cpu:(max(usage) AS u) BY host):(avg(u))", q.String())
+	})
+
+	t.Run("without-namespace", func(t *T.T) {
+		q, err := ParseQuery("cpu")
+		require.NoError(t, err)
+		assert.Equal(t, "", q.Namespace)
+		assert.Equal(t, "cpu", q.String())
+	})
+}
+
+func TestParseCallStmt(t *T.T) {
+	stmt, err := Parse("M::show_measurement()")
+	require.NoError(t, err)
+
+	cs, ok := stmt.(*CallStmt)
+	require.True(t, ok)
+	assert.Equal(t, "show_measurement", cs.Call.Name)
+	assert.Equal(t, "M::show_measurement()", cs.String())
+
+	_, err = ParseQuery("M::show_measurement()")
+	assert.Error(t, err)
+}
+
+func TestParseErrors(t *T.T) {
+	cases := []struct {
+		in  string
+		pos string
+		msg string
+	}{
+		{"X::cpu", "1:1", `unknown namespace "X"`},
+		{"M::cpu {host = }", "1:16", "unexpected '}', expected expression"},
+		{"M::cpu\n  LIMIT abc", "2:9", `unexpected identifier "abc", expected integer`},
+		{"M::cpu LIMIT 1 LIMIT 2", "1:16", "duplicate LIMIT clause"},
+		{"M::cpu [1h:2h:3h:avg:x]", "1:21", "too many parts within time range"},
+		{"M::cpu {host = 'abc}", "1:16", "unterminated string"},
+		{"M::cpu:(a", "1:10", "unexpected end of input, expected ')'"},
+		{"M::cpu @", "1:8", `unexpected character '@'`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *T.T) {
+			_, err := ParseQuery(tc.in)
+			require.Error(t, err)
+
+			perr, ok := err.(*Error)
+			require.True(t, ok)
+			assert.Equal(t, tc.pos, perr.Pos.String())
+			assert.Equal(t, tc.msg, perr.Msg)
+			assert.Equal(t, tc.pos+": "+tc.msg, err.Error())
+		})
+	}
+}
+
+func TestParseExpr(t *T.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"a = 1 and b == 2 or c <> 3", "a = 1 AND b = 2 OR c != 3"},
+		{"not a > -1", "NOT a > -1"},
+		{"!(a && b)", "NOT (a AND b)"},
+		{"a + b * 2 % 3 - c / 4", "a + b * 2 % 3 - c / 4"},
+		{"`some field` = \"it's\"", "`some field` = 'it\\'s'"},
+		{"x in [1, 2.5, true, null, 1h]", "x IN [1, 2.5, true, nil, 1h]"},
+		{"count(*)", "count(*)"},
+	}
+
+	for _, tc := range cases {
+		e, err := ParseExpr(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.out, e.String(), tc.in)
+	}
+
+	e, err := ParseExpr("a = 1 and b = 2 or c = 3")
+	require.NoError(t, err)
+
+	or := e.(*BinaryExpr)
+	assert.Equal(t, "OR", or.Op)
+	assert.Equal(t, "AND", or.LHS.(*BinaryExpr).Op)
+}
+
+func TestParseDuration(t *T.T) {
+	du, err := ParseDuration("1h30m")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, du)
+
+	du, err = ParseDuration("1w1d")
+	require.NoError(t, err)
+	assert.Equal(t, 8*24*time.Hour, du)
+
+	_, err = ParseDuration("1x")
+	assert.Error(t, err)
+}
+
+func TestQuoteIdent(t *T.T) {
+	assert.Equal(t, "host", QuoteIdent("host"))
+	assert.Equal(t, "host.name", QuoteIdent("host.name"))
+	assert.Equal(t, "`limit`", QuoteIdent("limit"))
+	assert.Equal(t, "`a-b`", QuoteIdent("a-b"))
+	assert.Equal(t, "`a\\`b`", QuoteIdent("a`b"))
+	assert.False(t, IsIdent("1abc"))
+}
+
+func TestFormatDuration(t *T.T) {
+	cases := map[time.Duration]string{
+		0:                              "0s",
+		time.Hour:                      "1h",
+		90 * time.Minute:               "1h30m",
+		30 * 24 * time.Hour:            "30d",
+		1500 * time.Millisecond:        "1s500ms",
+		-time.Minute:                   "-1m",
+		25*time.Hour + time.Nanosecond: "1d1h1ns",
+	}
+
+	for du, expect := range cases {
+		assert.Equal(t, expect, FormatDuration(du))
+
+		if du > 0 {
+			back, err := ParseDuration(expect)
+			require.NoError(t, err)
+			assert.Equal(t, du, back)
+		}
+	}
+}
@@ -0,0 +1,608 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package promapi serve Prometheus-compatible HTTP API on top of the DQL
+// client, so Datakit can be used as Prometheus datasource, such as Grafana.
+//
+// Following endpoints are served(both GET and POST):
+//
+//	/api/v1/query
+//	/api/v1/query_range
+//	/api/v1/series
+//	/api/v1/labels
+//	/api/v1/label/<name>/values
+package promapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+	"github.com/GuanceCloud/dql-go/promql"
+)
+
+// maxPoints is the max points of each series within range query, same as
+// Prometheus.
+const maxPoints = 11000
+
+// lookbackDelta is the lookback of series selected at each evaluation, same
+// as the default of Prometheus.
+const lookbackDelta = 5 * time.Minute
+
+// A Handler is the http.Handler of Prometheus HTTP API, all queries are
+// sent as PromQL via Client.QueryPromQL.
+type Handler struct {
+	c         *dql.Client
+	dqlOpts   []dql.DQLOption
+	queryOpts []dql.QueryOption
+	now       func() time.Time
+	mux       *http.ServeMux
+}
+
+// Option used to set various handler options.
+type Option func(*Handler)
+
+// WithDQLOptions set DQL options(such as dql.WithMaxPoint) applied on
+// every PromQL query.
+func WithDQLOptions(opts ...dql.DQLOption) Option {
+	return func(h *Handler) {
+		h.dqlOpts = append(h.dqlOpts, opts...)
+	}
+}
+
+// WithQueryOptions set query options(such as dql.WithToken) for every
+// request to the server.
+func WithQueryOptions(opts ...dql.QueryOption) Option {
+	return func(h *Handler) {
+		h.queryOpts = append(h.queryOpts, opts...)
+	}
+}
+
+// NewHandler create Prometheus HTTP API handler on the client c.
+func NewHandler(c *dql.Client, opts ...Option) *Handler {
+	h := &Handler{c: c, now: time.Now}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("/api/v1/query", h.handleQuery)
+	h.mux.HandleFunc("/api/v1/query_range", h.handleQueryRange)
+	h.mux.HandleFunc("/api/v1/series", h.handleSeries)
+	h.mux.HandleFunc("/api/v1/labels", h.handleLabels)
+	h.mux.HandleFunc("/api/v1/label/", h.handleLabelValues)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, errorBadData, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		badData(w, fmt.Errorf("invalid form: %w", err))
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// Prometheus API error types.
+const (
+	errorBadData   = "bad_data"
+	errorExec      = "execution"
+	errorTimeout   = "timeout"
+	errorCanceled  = "canceled"
+	errorNotFound  = "not_found"
+	statusCanceled = 499 // client closed request
+)
+
+type response struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck,gosec
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	writeJSON(w, http.StatusOK, &response{Status: "success", Data: data})
+}
+
+func writeError(w http.ResponseWriter, status int, typ string, err error) {
+	writeJSON(w, status, &response{Status: "error", ErrorType: typ, Error: err.Error()})
+}
+
+func badData(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusBadRequest, errorBadData, err)
+}
+
+// queryFailed write the error of query, invalid query is bad data.
+func queryFailed(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dql.ErrParse), errors.Is(err, dql.ErrInvalidOption):
+		badData(w, err)
+	case errors.Is(err, dql.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, errorTimeout, err)
+	case errors.Is(err, context.Canceled):
+		writeError(w, statusCanceled, errorCanceled, err)
+	default:
+		writeError(w, http.StatusUnprocessableEntity, errorExec, err)
+	}
+}
+
+func (h *Handler) options(r *http.Request) ([]dql.DQLOption, error) {
+	if r.Form.Get("query") == "" {
+		return nil, errors.New("parameter \"query\" is required")
+	}
+
+	opts := append([]dql.DQLOption{}, h.dqlOpts...)
+
+	if s := r.Form.Get("timeout"); s != "" {
+		du, err := parseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter \"timeout\": %w", err)
+		}
+		opts = append(opts, dql.WithTimeout(du))
+	}
+
+	return opts, nil
+}
+
+func (h *Handler) handleQuery(w http.ResponseWriter, r *http.Request) {
+	at, err := parseTimeParam(r, "time", h.now())
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	opts, err := h.options(r)
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	q, err := dql.BuildInstantPromQL(r.Form.Get("query"), at, opts...)
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	res, err := h.c.QueryPromQL(r.Context(), q, h.queryOpts...)
+	if err != nil {
+		queryFailed(w, err)
+		return
+	}
+
+	writeData(w, res)
+}
+
+func (h *Handler) handleQueryRange(w http.ResponseWriter, r *http.Request) {
+	start, err := parseTimeParam(r, "start", time.Time{})
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	end, err := parseTimeParam(r, "end", time.Time{})
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	if start.IsZero() || end.IsZero() {
+		badData(w, errors.New("parameter \"start\" and \"end\" are required"))
+		return
+	}
+
+	if end.Before(start) {
+		badData(w, errors.New("end timestamp must not be before start time"))
+		return
+	}
+
+	step, err := parseDuration(r.Form.Get("step"))
+	if err != nil {
+		badData(w, fmt.Errorf("invalid parameter \"step\": %w", err))
+		return
+	}
+
+	if step <= 0 {
+		badData(w, errors.New("zero or negative query resolution step widths are not accepted"))
+		return
+	}
+
+	// PromQL step of DQL should be whole seconds
+	if rem := step % time.Second; rem != 0 {
+		step += time.Second - rem
+	}
+
+	if end.Sub(start)/step > maxPoints {
+		badData(w, fmt.Errorf("exceeded maximum resolution of %d points per timeseries", maxPoints))
+		return
+	}
+
+	opts, err := h.options(r)
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	if start.Equal(end) {
+		h.querySinglePoint(w, r, start, opts)
+		return
+	}
+
+	q, err := dql.BuildPromQL(r.Form.Get("query"), start, end, step, opts...)
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	res, err := h.c.QueryPromQL(r.Context(), q, h.queryOpts...)
+	if err != nil {
+		queryFailed(w, err)
+		return
+	}
+
+	writeData(w, res)
+}
+
+// querySinglePoint query range query of start equals end as instant query
+// at, the result converted to matrix of exactly one point at, same as
+// Prometheus.
+func (h *Handler) querySinglePoint(w http.ResponseWriter, r *http.Request, at time.Time, opts []dql.DQLOption) {
+	expr := r.Form.Get("query")
+	if err := promql.CheckRange(expr); err != nil {
+		badData(w, err)
+		return
+	}
+
+	q, err := dql.BuildInstantPromQL(expr, at, opts...)
+	if err != nil {
+		badData(w, err)
+		return
+	}
+
+	res, err := h.c.QueryPromQL(r.Context(), q, h.queryOpts...)
+	if err != nil {
+		queryFailed(w, err)
+		return
+	}
+
+	m := dql.PromMatrix{}
+	switch v := res.Result.(type) {
+	case dql.PromVector:
+		for _, s := range v {
+			m = append(m, &dql.PromSeries{Metric: s.Metric, Values: []dql.PromPoint{{T: at, V: s.Value.V}}})
+		}
+	case dql.PromScalar:
+		m = append(m, &dql.PromSeries{Metric: map[string]string{}, Values: []dql.PromPoint{{T: at, V: v.V}}})
+	default:
+		writeError(w, http.StatusUnprocessableEntity, errorExec, fmt.Errorf("unexpected result type %s", res.ResultType))
+		return
+	}
+
+	writeData(w, &dql.PromResult{ResultType: promql.ValueMatrix, Result: m})
+}
+
+// seriesTimeRange get time range of parameter start and end(default now),
+// start is zero if not set.
+func (h *Handler) seriesTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	start, err := parseTimeParam(r, "start", time.Time{})
+	if err != nil {
+		return start, start, err
+	}
+
+	end, err := parseTimeParam(r, "end", h.now())
+	if err != nil {
+		return start, end, err
+	}
+
+	if !start.IsZero() && end.Before(start) {
+		return start, end, errors.New("end timestamp must not be before start time")
+	}
+	return start, end, nil
+}
+
+// seriesStep get start and step of the range query selecting series within
+// [start, end]. The query evaluated backward from end, and step is no more
+// than lookbackDelta if possible, so no samples within the range missed.
+func seriesStep(start, end time.Time) (time.Time, time.Duration) {
+	du := end.Sub(start)
+
+	step := lookbackDelta
+	if du < step {
+		step = du
+	}
+	if least := du / maxPoints; step < least {
+		step = least
+	}
+
+	// PromQL step of DQL should be whole seconds
+	if rem := step % time.Second; rem != 0 {
+		step += time.Second - rem
+	}
+
+	n := (du + step - 1) / step
+	return end.Add(-n * step), step
+}
+
+// series query series of match[] selectors within parameter start and
+// end(default now). Each selector is sent as PromQL range query over the
+// time range, or instant query at end if no start(or start equals end), in
+// which series within lookbackDelta selected.
+func (h *Handler) series(w http.ResponseWriter, r *http.Request) ([]map[string]string, bool) {
+	matches := r.Form["match[]"]
+	if len(matches) == 0 {
+		badData(w, errors.New("no match[] parameter provided"))
+		return nil, false
+	}
+
+	start, end, err := h.seriesTimeRange(r)
+	if err != nil {
+		badData(w, err)
+		return nil, false
+	}
+
+	for _, m := range matches {
+		if err := promql.CheckSelector(m); err != nil {
+			badData(w, fmt.Errorf("invalid parameter \"match[]\": %w", err))
+			return nil, false
+		}
+	}
+
+	var (
+		res  = []map[string]string{}
+		seen = map[string]bool{}
+	)
+
+	for _, m := range matches {
+		q, err := dql.BuildInstantPromQL(m, end, h.dqlOpts...)
+		if !start.IsZero() && start.Before(end) {
+			from, step := seriesStep(start, end)
+			q, err = dql.BuildPromQL(m, from, end, step, h.dqlOpts...)
+		}
+		if err != nil {
+			badData(w, err)
+			return nil, false
+		}
+
+		pr, err := h.c.QueryPromQL(r.Context(), q, h.queryOpts...)
+		if err != nil {
+			queryFailed(w, err)
+			return nil, false
+		}
+
+		var metrics []map[string]string
+		switch v := pr.Result.(type) {
+		case dql.PromVector:
+			for _, s := range v {
+				metrics = append(metrics, s.Metric)
+			}
+		case dql.PromMatrix:
+			for _, s := range v {
+				metrics = append(metrics, s.Metric)
+			}
+		default:
+			writeError(w, http.StatusUnprocessableEntity, errorExec, fmt.Errorf("unexpected result type %s", pr.ResultType))
+			return nil, false
+		}
+
+		for _, metric := range metrics {
+			if k := metricKey(metric); !seen[k] {
+				seen[k] = true
+				res = append(res, metric)
+			}
+		}
+	}
+
+	return res, true
+}
+
+func metricKey(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + "=" + m[k] + "\x00")
+	}
+	return sb.String()
+}
+
+func (h *Handler) handleSeries(w http.ResponseWriter, r *http.Request) {
+	if series, ok := h.series(w, r); ok {
+		writeData(w, series)
+	}
+}
+
+// showTagKeys get all tag keys by SHOW function, used as label names if no
+// match[], instead of selecting all series.
+func (h *Handler) showTagKeys(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	start, end, err := h.seriesTimeRange(r)
+	if err != nil {
+		badData(w, err)
+		return nil, false
+	}
+
+	var opts []dql.DQLOption
+	if !start.IsZero() && start.Before(end) {
+		opts = append(opts, dql.WithTimeWindow(start, end))
+	}
+
+	q, err := dql.BuildDQL("show_tag_key()", opts...)
+	if err != nil {
+		badData(w, err)
+		return nil, false
+	}
+
+	res, err := h.c.QueryContext(r.Context(), append(h.queryOpts, dql.WithQueries(q), dql.WithQueryError(true))...)
+	if err != nil {
+		queryFailed(w, err)
+		return nil, false
+	}
+
+	keys := []string{"__name__"}
+	for _, dr := range res.Content {
+		for _, row := range dr.Series {
+			col := 0
+			if len(row.Columns) > 1 && row.Columns[0] == "time" {
+				col = 1
+			}
+
+			for _, v := range row.Values {
+				if col < len(v) {
+					if k, ok := v[col].(string); ok {
+						keys = append(keys, k)
+					}
+				}
+			}
+		}
+	}
+
+	return keys, true
+}
+
+func (h *Handler) handleLabels(w http.ResponseWriter, r *http.Request) {
+	names := map[string]bool{}
+
+	if len(r.Form["match[]"]) == 0 {
+		keys, ok := h.showTagKeys(w, r)
+		if !ok {
+			return
+		}
+
+		for _, k := range keys {
+			names[k] = true
+		}
+		writeData(w, sortedKeys(names))
+		return
+	}
+
+	series, ok := h.series(w, r)
+	if !ok {
+		return
+	}
+
+	for _, s := range series {
+		for k := range s {
+			names[k] = true
+		}
+	}
+
+	writeData(w, sortedKeys(names))
+}
+
+func (h *Handler) handleLabelValues(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/label/")
+	name = strings.TrimSuffix(name, "/values")
+	if name == "" || strings.Contains(name, "/") || !strings.HasSuffix(r.URL.Path, "/values") {
+		writeError(w, http.StatusNotFound, errorNotFound, fmt.Errorf("path %s not found", r.URL.Path))
+		return
+	}
+
+	if !isLabelName(name) {
+		badData(w, fmt.Errorf("invalid label name: %q", name))
+		return
+	}
+
+	if len(r.Form["match[]"]) == 0 {
+		r.Form["match[]"] = []string{fmt.Sprintf(`{%s!=""}`, name)}
+	}
+
+	series, ok := h.series(w, r)
+	if !ok {
+		return
+	}
+
+	values := map[string]bool{}
+	for _, s := range series {
+		if v, ok := s[name]; ok {
+			values[v] = true
+		}
+	}
+
+	writeData(w, sortedKeys(values))
+}
+
+func isLabelName(s string) bool {
+	for i, c := range s {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// parseTimeParam parse time in UNIX seconds(float allowed) or RFC3339, def
+// returned if the parameter not set.
+func parseTimeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	s := r.Form.Get(name)
+	if s == "" {
+		return def, nil
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, fmt.Errorf("invalid parameter %q: cannot parse %q to a valid timestamp", name, s)
+		}
+		return time.UnixMilli(int64(math.Round(f * 1000))), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid parameter %q: cannot parse %q to a valid timestamp", name, s)
+	}
+	return t, nil
+}
+
+// parseDuration parse duration in seconds(float allowed) or PromQL duration
+// such as 5m.
+func parseDuration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || math.IsInf(f, 0) || f*float64(time.Second) > math.MaxInt64 {
+			return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+		}
+		return time.Duration(math.Round(f * float64(time.Second))), nil
+	}
+
+	du, err := promql.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+	}
+	return du, nil
+}
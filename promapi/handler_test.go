@@ -0,0 +1,256 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package promapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type received struct {
+	Query     string  `json:"query"`
+	QType     string  `json:"qtype"`
+	TimeRange []int64 `json:"time_range"`
+	Interval  int64   `json:"interval"`
+	Timeout   string  `json:"search_timeout"`
+}
+
+// datakit is a fake Datakit serving PromQL results.
+func datakit(t *T.T) (*httptest.Server, func() []received) {
+	t.Helper()
+
+	var (
+		mtx sync.Mutex
+		all []received
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []received `json:"queries"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		q := body.Queries[0]
+		mtx.Lock()
+		all = append(all, q)
+		mtx.Unlock()
+
+		var res *dql.Result
+		switch {
+		case strings.Contains(q.Query, "bad("):
+			w.WriteHeader(http.StatusBadRequest)
+			res = &dql.Result{ErrorCode: "query.parse_error", Message: "bad query"}
+
+		case q.Query == "show_tag_key()":
+			res = &dql.Result{Content: []*dql.DQLResult{{Series: []*dql.Row{
+				{Name: "up", Columns: []string{"tagKey"}, Values: [][]any{{"instance"}, {"job"}}},
+				{Name: "cpu", Columns: []string{"tagKey"}, Values: [][]any{{"host"}}},
+			}}}}
+
+		default:
+			res = &dql.Result{Content: []*dql.DQLResult{{Series: []*dql.Row{
+				{
+					Name:    "up",
+					Tags:    map[string]string{"job": "api", "instance": "a"},
+					Columns: []string{"time", "value"},
+					Values:  [][]any{{1000, 1}, {2000, 0}},
+				},
+				{
+					Name:    "up",
+					Tags:    map[string]string{"job": "web", "instance": "b"},
+					Columns: []string{"time", "value"},
+					Values:  [][]any{{2000, 1}},
+				},
+			}}}}
+		}
+
+		json.NewEncoder(w).Encode(res) //nolint:errcheck
+	}))
+
+	return ts, func() []received {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]received(nil), all...)
+	}
+}
+
+type apiResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+func call(t *T.T, h http.Handler, method, path string, form url.Values) (int, *apiResponse) {
+	t.Helper()
+
+	var req *http.Request
+	if method == http.MethodPost {
+		req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, path+"?"+form.Encode(), nil)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp apiResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w.Code, &resp
+}
+
+func TestHandler(t *T.T) {
+	ts, queries := datakit(t)
+	defer ts.Close()
+
+	h := NewHandler(dql.NewClient(ts.Listener.Addr().String()),
+		WithDQLOptions(dql.WithMaxPoint(100)),
+		WithQueryOptions(dql.WithToken("tkn_xxx")))
+	h.now = func() time.Time { return time.UnixMilli(60000) }
+
+	t.Run("query", func(t *T.T) {
+		code, resp := call(t, h, http.MethodGet, "/api/v1/query", url.Values{
+			"query": {"up"}, "time": {"30.5"}, "timeout": {"10s"},
+		})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.Equal(t, "success", resp.Status)
+
+		var data dql.PromResult
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+		assert.Equal(t, "vector", string(data.ResultType))
+		assert.JSONEq(t, `{"resultType":"vector","result":[
+			{"metric":{"__name__":"up","instance":"a","job":"api"},"value":[2,"0"]},
+			{"metric":{"__name__":"up","instance":"b","job":"web"},"value":[2,"1"]}
+		]}`, string(resp.Data))
+
+		q := queries()[len(queries())-1]
+		assert.Equal(t, "promql", q.QType)
+		assert.Equal(t, []int64{30500, 30500}, q.TimeRange)
+		assert.Equal(t, "10s", q.Timeout)
+
+		// time default to now
+		code, _ = call(t, h, http.MethodPost, "/api/v1/query", url.Values{"query": {"up"}})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []int64{60000, 60000}, queries()[len(queries())-1].TimeRange)
+	})
+
+	t.Run("query-range", func(t *T.T) {
+		code, resp := call(t, h, http.MethodPost, "/api/v1/query_range", url.Values{
+			"query": {"rate(up[5m])"},
+			"start": {"1970-01-01T00:00:00Z"},
+			"end":   {"60"},
+			"step":  {"14.5"},
+		})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.Contains(t, string(resp.Data), `"resultType":"matrix"`)
+		assert.Contains(t, string(resp.Data), `"values":[[1,"1"],[2,"0"]]`)
+
+		q := queries()[len(queries())-1]
+		assert.Equal(t, []int64{0, 60000}, q.TimeRange)
+		assert.Equal(t, int64(15), q.Interval) // rounded up to whole seconds
+
+		// single point
+		code, resp = call(t, h, http.MethodGet, "/api/v1/query_range", url.Values{
+			"query": {"up"}, "start": {"30"}, "end": {"30"}, "step": {"15"},
+		})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.JSONEq(t, `{"resultType":"matrix","result":[
+			{"metric":{"__name__":"up","instance":"a","job":"api"},"values":[[30,"0"]]},
+			{"metric":{"__name__":"up","instance":"b","job":"web"},"values":[[30,"1"]]}
+		]}`, string(resp.Data))
+		assert.Equal(t, []int64{30000, 30000}, queries()[len(queries())-1].TimeRange)
+	})
+
+	t.Run("series-and-labels", func(t *T.T) {
+		code, resp := call(t, h, http.MethodGet, "/api/v1/series", url.Values{
+			"match[]": {"up", `up{job="api"}`},
+		})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+
+		var series []map[string]string
+		require.NoError(t, json.Unmarshal(resp.Data, &series))
+		assert.Len(t, series, 2) // deduplicated
+		assert.Equal(t, []int64{60000, 60000}, queries()[len(queries())-1].TimeRange)
+
+		// range query over [start, end]
+		code, resp = call(t, h, http.MethodGet, "/api/v1/series", url.Values{
+			"match[]": {"up"}, "start": {"0"}, "end": {"3600"},
+		})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.Contains(t, string(resp.Data), `"job":"web"`)
+
+		q := queries()[len(queries())-1]
+		assert.Equal(t, []int64{0, 3600000}, q.TimeRange)
+		assert.Equal(t, int64(300), q.Interval) // no more than lookback
+
+		code, _ = call(t, h, http.MethodGet, "/api/v1/series", url.Values{
+			"match[]": {"up"}, "start": {"50.5"},
+		})
+		require.Equal(t, http.StatusOK, code)
+		q = queries()[len(queries())-1]
+		assert.Equal(t, []int64{50000, 60000}, q.TimeRange)
+		assert.Equal(t, int64(10), q.Interval) // step of the whole range
+
+		// tag keys shown if no match[]
+		code, resp = call(t, h, http.MethodGet, "/api/v1/labels", nil)
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.JSONEq(t, `["__name__","host","instance","job"]`, string(resp.Data))
+		assert.Equal(t, "show_tag_key()", queries()[len(queries())-1].Query)
+
+		code, resp = call(t, h, http.MethodGet, "/api/v1/labels", url.Values{"match[]": {"up"}})
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.JSONEq(t, `["__name__","instance","job"]`, string(resp.Data))
+
+		code, resp = call(t, h, http.MethodGet, "/api/v1/label/job/values", nil)
+		require.Equal(t, http.StatusOK, code, resp.Error)
+		assert.JSONEq(t, `["api","web"]`, string(resp.Data))
+		assert.Equal(t, `{job!=""}`, queries()[len(queries())-1].Query)
+	})
+
+	t.Run("errors", func(t *T.T) {
+		cases := []struct {
+			method, path string
+			form         url.Values
+			status       int
+			errorType    string
+		}{
+			{http.MethodGet, "/api/v1/query", url.Values{"query": {"up"}, "time": {"abc"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query", nil, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query", url.Values{"query": {"bad(up)"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query", url.Values{"query": {"up"}, "timeout": {"x"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query_range", url.Values{"query": {"up"}, "start": {"1"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query_range", url.Values{"query": {"up"}, "start": {"2"}, "end": {"1"}, "step": {"1"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query_range", url.Values{"query": {"up"}, "start": {"1"}, "end": {"2"}, "step": {"0"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query_range", url.Values{"query": {"up"}, "start": {"0"}, "end": {"86400"}, "step": {"1"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/series", nil, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/series", url.Values{"match[]": {"rate(up[5m])"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/series", url.Values{"match[]": {"up"}, "start": {"120"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/labels", url.Values{"end": {"x"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/query_range", url.Values{"query": {"up[5m]"}, "start": {"1"}, "end": {"1"}, "step": {"1"}}, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/label/1job/values", nil, 400, "bad_data"},
+			{http.MethodGet, "/api/v1/label/job", nil, 404, "not_found"},
+			{http.MethodDelete, "/api/v1/query", nil, 405, "bad_data"},
+		}
+
+		for _, tc := range cases {
+			code, resp := call(t, h, tc.method, tc.path, tc.form)
+			assert.Equal(t, tc.status, code, "%s %v", tc.path, tc.form)
+			assert.Equal(t, "error", resp.Status)
+			assert.Equal(t, tc.errorType, resp.ErrorType, "%s %v: %s", tc.path, tc.form, resp.Error)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/promql"
+)
+
+// BuildPromQL build a PromQL range query evaluated on every step within
+// [start, end]. step should be whole seconds, and it's sent as interval in
+// seconds. Extra options(such as WithValidate) can be applied on the query.
+func BuildPromQL(expr string, start, end time.Time, step time.Duration, opts ...DQLOption) (*dql, error) {
+	base := func(q *dql) {
+		q.QType = "promql"
+
+		switch {
+		case !start.Before(end):
+			q.setErr(fmt.Errorf("%w: PromQL start(%s) should before end(%s)", ErrInvalidOption, start, end))
+		case step < time.Second || step%time.Second != 0:
+			q.setErr(fmt.Errorf("%w: PromQL step %s should be whole seconds", ErrInvalidOption, step))
+		}
+
+		q.TimeRange = []int64{start.UnixMilli(), end.UnixMilli()}
+		q.Interval = int64(step / time.Second)
+	}
+
+	return BuildDQL(expr, append([]DQLOption{base}, opts...)...)
+}
+
+// BuildInstantPromQL build a PromQL instant query evaluated at time at.
+func BuildInstantPromQL(expr string, at time.Time, opts ...DQLOption) (*dql, error) {
+	base := func(q *dql) {
+		q.QType = "promql"
+		q.instant = true
+		q.TimeRange = []int64{at.UnixMilli(), at.UnixMilli()}
+	}
+
+	return BuildDQL(expr, append([]DQLOption{base}, opts...)...)
+}
+
+// checkPromQL check the PromQL syntax, range query requires scalar or
+// vector expression.
+func (q *dql) checkPromQL() error {
+	var err error
+	if q.instant {
+		_, err = promql.Check(q.DQL)
+	} else {
+		err = promql.CheckRange(q.DQL)
+	}
+
+	if err != nil {
+		return &ValidationError{Kind: ErrParse, Msg: err.Error(), Err: err}
+	}
+	return nil
+}
+
+// resultType get the Prometheus result type of PromQL query, range query
+// always get matrix.
+func (q *dql) resultType() promql.ValueType {
+	if !q.instant {
+		return promql.ValueMatrix
+	}
+
+	typ, err := promql.Check(q.DQL)
+	if err != nil || typ == promql.ValueString {
+		return promql.ValueVector
+	}
+	return typ
+}
+
+// QueryPromQL query the PromQL q(see BuildPromQL and BuildInstantPromQL),
+// and convert the result into Prometheus-style result. Range query get
+// matrix, and instant query get vector, scalar or matrix depends on the
+// expression.
+func (c *Client) QueryPromQL(ctx context.Context, q *dql, opts ...QueryOption) (*PromResult, error) {
+	if q.QType != "promql" {
+		return nil, fmt.Errorf("%w: query type %q is not promql", ErrInvalidOption, q.QType)
+	}
+
+	pc := &pageConf{opts: opts}
+	res, err := pc.queryPage(ctx, c, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.PromResult(q.resultType())
+}
+
+// A PromPoint is a sample of Prometheus result, JSON encoded as
+// [<unix seconds>, "<value>"].
+type PromPoint struct {
+	T time.Time
+	V float64
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p PromPoint) MarshalJSON() ([]byte, error) {
+	ts := strconv.FormatFloat(float64(p.T.UnixMilli())/1000, 'f', -1, 64)
+	return []byte("[" + ts + "," + strconv.Quote(formatPromValue(p.V)) + "]"), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *PromPoint) UnmarshalJSON(data []byte) error {
+	var (
+		ts  float64
+		val string
+	)
+
+	if err := json.Unmarshal(data, &[]any{&ts, &val}); err != nil {
+		return fmt.Errorf("invalid Prometheus sample %s: %w", data, err)
+	}
+
+	v, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Prometheus sample value %q: %w", val, err)
+	}
+
+	p.T = time.UnixMilli(int64(math.Round(ts * 1000)))
+	p.V = v
+	return nil
+}
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// A PromSeries is a series of Prometheus matrix.
+type PromSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values []PromPoint       `json:"values"`
+}
+
+// A PromSample is a sample of Prometheus vector.
+type PromSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  PromPoint         `json:"value"`
+}
+
+type (
+	// PromMatrix is the Prometheus range vector result.
+	PromMatrix []*PromSeries
+
+	// PromVector is the Prometheus instant vector result.
+	PromVector []*PromSample
+
+	// PromScalar is the Prometheus scalar result.
+	PromScalar = PromPoint
+)
+
+// A PromResult is the data of Prometheus HTTP API query response, Result is
+// one of PromMatrix, PromVector and PromScalar.
+type PromResult struct {
+	ResultType promql.ValueType `json:"resultType"`
+	Result     any              `json:"result"`
+}
+
+// PromResult convert the result into Prometheus-style result of typ, only
+// matrix, vector and scalar are supported.
+func (r *DQLResult) PromResult(typ promql.ValueType) (*PromResult, error) {
+	var (
+		res any
+		err error
+	)
+
+	switch typ {
+	case promql.ValueMatrix:
+		res, err = r.PromMatrix()
+	case promql.ValueVector:
+		res, err = r.PromVector()
+	case promql.ValueScalar:
+		res, err = r.PromScalar()
+	case promql.ValueString:
+		err = fmt.Errorf("unsupported Prometheus result type %q", typ)
+	default:
+		err = fmt.Errorf("unknown Prometheus result type %q", typ)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &PromResult{ResultType: typ, Result: res}, nil
+}
+
+// PromMatrix convert series within the result into Prometheus matrix, each
+// time series(see TimeSeries) is a series of the matrix. The metric of the
+// series are tags of the series, with the series name as __name__. If the
+// series got multiple value columns, the column name is set as __field__.
+// Null values are dropped.
+func (r *DQLResult) PromMatrix() (PromMatrix, error) {
+	all, err := r.TimeSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for _, ts := range all {
+		columns[ts.Name+"\x00"+labelsKey(ts.Labels)]++
+	}
+
+	res := PromMatrix{}
+	for _, ts := range all {
+		multi := columns[ts.Name+"\x00"+labelsKey(ts.Labels)] > 1
+		s := &PromSeries{Metric: promMetric(ts, multi), Values: []PromPoint{}}
+		for _, x := range ts.Samples {
+			if !x.IsNull() {
+				s.Values = append(s.Values, PromPoint{T: x.Time, V: x.Value})
+			}
+		}
+
+		if len(s.Values) > 0 {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// PromVector convert series within the result into Prometheus vector, the
+// last non-null sample of each series used. See PromMatrix for the metric.
+func (r *DQLResult) PromVector() (PromVector, error) {
+	m, err := r.PromMatrix()
+	if err != nil {
+		return nil, err
+	}
+
+	res := PromVector{}
+	for _, s := range m {
+		res = append(res, &PromSample{Metric: s.Metric, Value: s.Values[len(s.Values)-1]})
+	}
+	return res, nil
+}
+
+// PromScalar convert the result into Prometheus scalar, the result should
+// contain only one series, and the last non-null sample used.
+func (r *DQLResult) PromScalar() (PromScalar, error) {
+	v, err := r.PromVector()
+	if err != nil {
+		return PromScalar{}, err
+	}
+
+	if len(v) != 1 {
+		return PromScalar{}, errors.New("scalar result requires exactly one non-empty series")
+	}
+	return v[0].Value, nil
+}
+
+func promMetric(ts *TimeSeries, multi bool) map[string]string {
+	metric := map[string]string{}
+	for k, v := range ts.Labels {
+		metric[k] = v
+	}
+
+	if ts.Name != "" {
+		metric["__name__"] = ts.Name
+	}
+
+	if multi {
+		metric["__field__"] = ts.Column
+	}
+
+	return metric
+}
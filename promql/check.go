@@ -0,0 +1,773 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package promql is a lightweight client-side syntax checker of PromQL. It
+// do not build AST, only check the syntax and the value types, so obvious
+// mistakes can be found before sending the query.
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Pos is the position within the PromQL.
+type Pos struct {
+	Offset int // byte offset, start from 0
+	Line   int // start from 1
+	Column int // in runes, start from 1
+}
+
+// String used to get the position in line:column form.
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// An Error is a syntax or type error of PromQL.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// A ValueType is the type of PromQL expression.
+type ValueType string
+
+// Value types of expression.
+const (
+	ValueScalar ValueType = "scalar"
+	ValueVector ValueType = "vector" // instant vector
+	ValueMatrix ValueType = "matrix" // range vector
+	ValueString ValueType = "string"
+)
+
+// Check check the syntax of PromQL expression, and get the type of it.
+func Check(expr string) (ValueType, error) {
+	e, err := check(expr)
+	if err != nil {
+		return "", err
+	}
+	return e.typ, nil
+}
+
+// CheckSelector check the PromQL is a vector selector, such as
+// up{job="api"}, used as series matcher.
+func CheckSelector(expr string) error {
+	e, err := check(expr)
+	if err != nil {
+		return err
+	}
+
+	if !e.selector || e.typ != ValueVector {
+		return &Error{Pos: e.pos, Msg: "expected vector selector"}
+	}
+	return nil
+}
+
+func check(input string) (*expr, error) {
+	c := &checker{lex: lexer{input: input, line: 1, col: 1}}
+	c.tok = c.lex.next()
+
+	if c.tok.typ == tEOF {
+		return nil, c.errorf(c.tok.pos, "empty expression")
+	}
+
+	e, err := c.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tok.typ != tEOF {
+		return nil, c.unexpected()
+	}
+
+	return e, nil
+}
+
+// CheckRange check the PromQL used as range query, only scalar and instant
+// vector expression allowed.
+func CheckRange(expr string) error {
+	typ, err := Check(expr)
+	if err != nil {
+		return err
+	}
+
+	if typ != ValueScalar && typ != ValueVector {
+		return &Error{
+			Pos: Pos{Line: 1, Column: 1},
+			Msg: fmt.Sprintf("invalid expression type %s for range query, must be scalar or vector", typ),
+		}
+	}
+	return nil
+}
+
+type expr struct {
+	typ ValueType
+	pos Pos
+
+	// selector or subquery, offset and @ modifier allowed
+	selector bool
+	modified bool // offset or @ applied
+	offset   bool
+	at       bool
+}
+
+type checker struct {
+	lex   lexer
+	tok   token
+	ahead *token
+}
+
+func (c *checker) next() token {
+	t := c.tok
+	if c.ahead != nil {
+		c.tok, c.ahead = *c.ahead, nil
+	} else {
+		c.tok = c.lex.next()
+	}
+	return t
+}
+
+// peek get the token after current token.
+func (c *checker) peek() token {
+	if c.ahead == nil {
+		t := c.lex.next()
+		c.ahead = &t
+	}
+	return *c.ahead
+}
+
+func (c *checker) errorf(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (c *checker) unexpected() error {
+	if c.tok.typ == tIllegal {
+		return c.errorf(c.tok.pos, "%s", c.tok.text)
+	}
+	return c.errorf(c.tok.pos, "unexpected %s", c.tok.describe())
+}
+
+func (c *checker) expect(typ tokenType, what string) (token, error) {
+	if c.tok.typ != typ {
+		if c.tok.typ == tIllegal {
+			return c.tok, c.unexpected()
+		}
+		return c.tok, c.errorf(c.tok.pos, "expected %s, got %s", what, c.tok.describe())
+	}
+	return c.next(), nil
+}
+
+func (c *checker) isIdent(words ...string) bool {
+	if c.tok.typ != tIdent {
+		return false
+	}
+
+	for _, w := range words {
+		if strings.EqualFold(c.tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// binary operators and their precedence.
+var precedences = map[string]int{
+	"or":     1,
+	"and":    2,
+	"unless": 2,
+	"==":     3,
+	"!=":     3,
+	"<":      3,
+	"<=":     3,
+	">":      3,
+	">=":     3,
+	"+":      4,
+	"-":      4,
+	"*":      5,
+	"/":      5,
+	"%":      5,
+	"atan2":  5,
+	"^":      6,
+}
+
+const powPrecedence = 6
+
+func isComparison(op string) bool {
+	return precedences[op] == 3
+}
+
+func isSetOp(op string) bool {
+	return op == "and" || op == "or" || op == "unless"
+}
+
+// binaryOp get the binary operator of current token.
+func (c *checker) binaryOp() (string, int) {
+	switch c.tok.typ {
+	case tOp:
+		if p, ok := precedences[c.tok.text]; ok {
+			return c.tok.text, p
+		}
+	case tIdent:
+		op := strings.ToLower(c.tok.text)
+		if p, ok := precedences[op]; ok {
+			return op, p
+		}
+	default:
+	}
+	return "", 0
+}
+
+func (c *checker) parseExpr(minPrec int) (*expr, error) {
+	lhs, err := c.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, prec := c.binaryOp()
+		if op == "" || prec < minPrec {
+			return lhs, nil
+		}
+		opTok := c.next()
+
+		var (
+			isBool   bool
+			matching bool
+		)
+
+		if c.isIdent("bool") {
+			if !isComparison(op) {
+				return nil, c.errorf(c.tok.pos, "bool modifier can only be used on comparison operators")
+			}
+			c.next()
+			isBool = true
+		}
+
+		if c.isIdent("on", "ignoring") {
+			c.next()
+			if err := c.parseLabels(); err != nil {
+				return nil, err
+			}
+			matching = true
+		}
+
+		if c.isIdent("group_left", "group_right") {
+			if isSetOp(op) {
+				return nil, c.errorf(c.tok.pos, "no grouping allowed for %q operation", op)
+			}
+			if !matching {
+				return nil, c.errorf(c.tok.pos, "%s requires on or ignoring", strings.ToLower(c.tok.text))
+			}
+			c.next()
+			if c.tok.typ == tLParen {
+				if err := c.parseLabels(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		next := prec + 1
+		if op == "^" { // right associative
+			next = prec
+		}
+
+		rhs, err := c.parseExpr(next)
+		if err != nil {
+			return nil, err
+		}
+
+		if lhs, err = c.binary(opTok.pos, op, lhs, rhs, isBool, matching); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *checker) binary(pos Pos, op string, lhs, rhs *expr, isBool, matching bool) (*expr, error) {
+	for _, e := range []*expr{lhs, rhs} {
+		if e.typ != ValueScalar && e.typ != ValueVector {
+			return nil, c.errorf(pos, "binary expression must contain only scalar and vector types, got %s", e.typ)
+		}
+	}
+
+	scalars := lhs.typ == ValueScalar && rhs.typ == ValueScalar
+	switch {
+	case isSetOp(op) && (lhs.typ != ValueVector || rhs.typ != ValueVector):
+		return nil, c.errorf(pos, "set operator %q not allowed in binary scalar expression", op)
+	case matching && (lhs.typ != ValueVector || rhs.typ != ValueVector):
+		return nil, c.errorf(pos, "vector matching only allowed between vectors")
+	case isComparison(op) && scalars && !isBool:
+		return nil, c.errorf(pos, "comparisons between scalars must use bool modifier")
+	}
+
+	if scalars {
+		return &expr{typ: ValueScalar, pos: lhs.pos}, nil
+	}
+	return &expr{typ: ValueVector, pos: lhs.pos}, nil
+}
+
+func (c *checker) parseUnary() (*expr, error) {
+	if c.tok.typ == tOp && (c.tok.text == "-" || c.tok.text == "+") {
+		opTok := c.next()
+
+		// unary operator binds looser than ^, -2^2 is -4
+		e, err := c.parseExpr(powPrecedence)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.typ != ValueScalar && e.typ != ValueVector {
+			return nil, c.errorf(opTok.pos, "unary expression only allowed on scalar and vector, got %s", e.typ)
+		}
+		return &expr{typ: e.typ, pos: opTok.pos}, nil
+	}
+
+	e, err := c.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return c.parsePostfix(e)
+}
+
+// parsePostfix parse range, subquery, offset and @ modifier.
+func (c *checker) parsePostfix(e *expr) (*expr, error) {
+	for {
+		switch {
+		case c.tok.typ == tLBracket:
+			lb := c.next()
+
+			if _, err := c.parseDuration(); err != nil {
+				return nil, err
+			}
+
+			if c.tok.typ == tColon {
+				c.next()
+				if c.tok.typ == tDuration {
+					if _, err := c.parseDuration(); err != nil {
+						return nil, err
+					}
+				}
+
+				if e.typ != ValueVector {
+					return nil, c.errorf(lb.pos, "subquery is only allowed on vector, got %s", e.typ)
+				}
+				if _, err := c.expect(tRBracket, `"]"`); err != nil {
+					return nil, err
+				}
+
+				e = &expr{typ: ValueMatrix, pos: e.pos, selector: true}
+				continue
+			}
+
+			if _, err := c.expect(tRBracket, `"]"`); err != nil {
+				return nil, err
+			}
+
+			switch {
+			case !e.selector || e.typ != ValueVector:
+				return nil, c.errorf(lb.pos, "ranges only allowed for vector selectors")
+			case e.modified:
+				return nil, c.errorf(lb.pos, "no offset or @ modifier allowed before range")
+			}
+			e.typ = ValueMatrix
+
+		case c.isIdent("offset"):
+			t := c.next()
+			if !e.selector {
+				return nil, c.errorf(t.pos, "offset modifier must be preceded by a selector or subquery")
+			}
+			if e.offset {
+				return nil, c.errorf(t.pos, "offset may not be set multiple times")
+			}
+
+			if c.tok.typ == tOp && (c.tok.text == "-" || c.tok.text == "+") {
+				c.next()
+			}
+
+			if _, err := c.parseDuration(); err != nil {
+				return nil, err
+			}
+			e.offset, e.modified = true, true
+
+		case c.tok.typ == tAt:
+			t := c.next()
+			if !e.selector {
+				return nil, c.errorf(t.pos, "@ modifier must be preceded by a selector or subquery")
+			}
+			if e.at {
+				return nil, c.errorf(t.pos, "@ may not be set multiple times")
+			}
+
+			if err := c.parseAt(); err != nil {
+				return nil, err
+			}
+			e.at, e.modified = true, true
+
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (c *checker) parseAt() error {
+	if c.isIdent("start", "end") {
+		c.next()
+		if _, err := c.expect(tLParen, `"("`); err != nil {
+			return err
+		}
+		_, err := c.expect(tRParen, `")"`)
+		return err
+	}
+
+	if c.tok.typ == tOp && (c.tok.text == "-" || c.tok.text == "+") {
+		c.next()
+	}
+
+	t, err := c.expect(tNumber, "timestamp")
+	if err != nil {
+		return err
+	}
+	return c.checkNumber(t)
+}
+
+func (c *checker) parseDuration() (time.Duration, error) {
+	t, err := c.expect(tDuration, "duration")
+	if err != nil {
+		return 0, err
+	}
+
+	du, err := ParseDuration(t.text)
+	if err != nil {
+		return 0, c.errorf(t.pos, "%s", err)
+	}
+	return du, nil
+}
+
+var unitDurations = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// ParseDuration parse PromQL duration such as 1h30m, units must be in
+// descending order and not repeated.
+func ParseDuration(s string) (time.Duration, error) {
+	var (
+		total time.Duration
+		last  = time.Duration(-1)
+		rest  = s
+	)
+
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[i:]
+
+		j := 0
+		for j < len(rest) && !isDigit(rest[j]) {
+			j++
+		}
+		unit, ok := unitDurations[rest[:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[j:]
+
+		if last >= 0 && unit >= last {
+			return 0, fmt.Errorf("invalid duration %q: units should be in descending order", s)
+		}
+		last = unit
+		total += time.Duration(n) * unit
+	}
+
+	if total <= 0 {
+		return 0, fmt.Errorf("duration %q must be greater than 0", s)
+	}
+	return total, nil
+}
+
+func (c *checker) checkNumber(t token) error {
+	var err error
+	if strings.HasPrefix(t.text, "0x") || strings.HasPrefix(t.text, "0X") {
+		_, err = strconv.ParseUint(t.text[2:], 16, 64)
+	} else {
+		_, err = strconv.ParseFloat(t.text, 64)
+	}
+
+	if err != nil {
+		return c.errorf(t.pos, "invalid number %q", t.text)
+	}
+	return nil
+}
+
+func (c *checker) parsePrimary() (*expr, error) {
+	t := c.tok
+
+	switch t.typ {
+	case tNumber:
+		c.next()
+		if err := c.checkNumber(t); err != nil {
+			return nil, err
+		}
+		return &expr{typ: ValueScalar, pos: t.pos}, nil
+
+	case tString:
+		c.next()
+		return &expr{typ: ValueString, pos: t.pos}, nil
+
+	case tLParen:
+		c.next()
+		e, err := c.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.expect(tRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return &expr{typ: e.typ, pos: t.pos}, nil
+
+	case tLBrace:
+		return c.parseSelector(false)
+
+	case tIdent:
+		name := strings.ToLower(t.text)
+		switch {
+		case name == "inf" || name == "nan":
+			c.next()
+			return &expr{typ: ValueScalar, pos: t.pos}, nil
+
+		case aggregations[name] != nil && (c.peek().typ == tLParen || isGrouping(c.peek())):
+			return c.parseAggregation()
+
+		case c.peek().typ == tLParen:
+			return c.parseCall()
+		}
+
+		return c.parseSelector(true)
+
+	default:
+		return nil, c.unexpected()
+	}
+}
+
+func isGrouping(t token) bool {
+	return t.typ == tIdent && (strings.EqualFold(t.text, "by") || strings.EqualFold(t.text, "without"))
+}
+
+// parseSelector parse vector selector such as metric{label="value"}.
+func (c *checker) parseSelector(named bool) (*expr, error) {
+	pos := c.tok.pos
+	if named {
+		c.next()
+	}
+
+	nonEmpty := named
+	if c.tok.typ == tLBrace {
+		c.next()
+
+		for c.tok.typ != tRBrace {
+			matchesEmpty, err := c.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			if !matchesEmpty {
+				nonEmpty = true
+			}
+
+			if c.tok.typ != tComma {
+				break
+			}
+			c.next()
+		}
+
+		if _, err := c.expect(tRBrace, `"}"`); err != nil {
+			return nil, err
+		}
+	}
+
+	if !nonEmpty {
+		return nil, c.errorf(pos, "vector selector must contain at least one non-empty matcher")
+	}
+
+	return &expr{typ: ValueVector, pos: pos, selector: true}, nil
+}
+
+// parseMatcher parse label matcher, and check if it matches empty string.
+func (c *checker) parseMatcher() (bool, error) {
+	if _, err := c.expect(tIdent, "label name"); err != nil {
+		return false, err
+	}
+
+	if c.tok.typ == tIllegal {
+		return false, c.unexpected()
+	}
+	if c.tok.typ != tOp {
+		return false, c.errorf(c.tok.pos, "expected label matching operator, got %s", c.tok.describe())
+	}
+	op := c.next()
+
+	v, err := c.expect(tString, "label value")
+	if err != nil {
+		return false, err
+	}
+	val := unquote(v.text)
+
+	switch op.text {
+	case "=":
+		return val == "", nil
+	case "!=":
+		return val != "", nil
+	case "=~", "!~":
+		if _, err := regexp.Compile(val); err != nil {
+			return false, c.errorf(v.pos, "invalid regular expression %q: %s", val, err)
+		}
+		re := regexp.MustCompile("^(?:" + val + ")$") // matchers are fully anchored
+		return re.MatchString("") == (op.text == "=~"), nil
+	default:
+		return false, c.errorf(op.pos, "unexpected label matching operator %q", op.text)
+	}
+}
+
+func (c *checker) parseLabels() error {
+	if _, err := c.expect(tLParen, `"("`); err != nil {
+		return err
+	}
+
+	for c.tok.typ != tRParen {
+		if _, err := c.expect(tIdent, "label name"); err != nil {
+			return err
+		}
+
+		if c.tok.typ != tComma {
+			break
+		}
+		c.next()
+	}
+
+	_, err := c.expect(tRParen, `")"`)
+	return err
+}
+
+// parseArgs parse arguments within parentheses.
+func (c *checker) parseArgs() ([]*expr, error) {
+	if _, err := c.expect(tLParen, `"("`); err != nil {
+		return nil, err
+	}
+
+	var args []*expr
+	for c.tok.typ != tRParen {
+		e, err := c.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+
+		if c.tok.typ != tComma {
+			break
+		}
+		c.next()
+	}
+
+	if _, err := c.expect(tRParen, `")"`); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+func (c *checker) parseAggregation() (*expr, error) {
+	t := c.next()
+	name := strings.ToLower(t.text)
+
+	grouped := false
+	if isGrouping(c.tok) {
+		c.next()
+		if err := c.parseLabels(); err != nil {
+			return nil, err
+		}
+		grouped = true
+	}
+
+	args, err := c.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	if isGrouping(c.tok) {
+		if grouped {
+			return nil, c.errorf(c.tok.pos, "aggregation %q grouped multiple times", name)
+		}
+		c.next()
+		if err := c.parseLabels(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkArgs(t, "aggregation", aggregations[name], args); err != nil {
+		return nil, err
+	}
+
+	return &expr{typ: ValueVector, pos: t.pos}, nil
+}
+
+func (c *checker) parseCall() (*expr, error) {
+	t := c.next()
+	name := t.text
+
+	fn, ok := functions[name]
+	if !ok {
+		return nil, c.errorf(t.pos, "unknown function %q", name)
+	}
+
+	args, err := c.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkArgs(t, "function", fn, args); err != nil {
+		return nil, err
+	}
+
+	return &expr{typ: fn.ret, pos: t.pos}, nil
+}
+
+func (c *checker) checkArgs(t token, kind string, fn *function, args []*expr) error {
+	atLeast, atMost := len(fn.args)-fn.optional, len(fn.args)
+	if fn.variadic {
+		atMost = -1
+	}
+
+	if len(args) < atLeast || (atMost >= 0 && len(args) > atMost) {
+		return c.errorf(t.pos, "wrong number of arguments for %s %q, got %d", kind, t.text, len(args))
+	}
+
+	for i, arg := range args {
+		want := fn.args[len(fn.args)-1]
+		if i < len(fn.args) {
+			want = fn.args[i]
+		}
+
+		if arg.typ != want {
+			return c.errorf(arg.pos, "expected type %s in call to %s %q, got %s", want, kind, t.text, arg.typ)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package promql
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *T.T) {
+	cases := []struct {
+		in  string
+		typ ValueType
+	}{
+		{`1`, ValueScalar},
+		{`-1.5e3 + 0x1f`, ValueScalar},
+		{`"str"`, ValueString},
+		{`Inf`, ValueScalar},
+		{`up`, ValueVector},
+		{`up{job="api", instance!~"10\\..*",}`, ValueVector},
+		{`{__name__=~"http_.*"}`, ValueVector},
+		{`http_requests_total[5m]`, ValueMatrix},
+		{`http_requests_total[1h30m] offset -5m @ 1609746000`, ValueMatrix},
+		{`up offset 1d @ end()`, ValueVector},
+		{`rate(http_requests_total{code=~"5.."}[5m])[30m:1m]`, ValueMatrix},
+		{`sum by (job) (rate(http_requests_total[5m]))`, ValueVector},
+		{`sum(rate(http_requests_total[5m])) without (instance)`, ValueVector},
+		{`topk(3, sum by (app) (up))`, ValueVector},
+		{`count_values("version", build_info)`, ValueVector},
+		{`histogram_quantile(0.9, sum by (le) (rate(latency_bucket[5m])))`, ValueVector},
+		{`label_replace(up, "dst", "$1", "src", "(.*)")`, ValueVector},
+		{`label_join(up, "dst", ",", "a", "b", "c")`, ValueVector},
+		{`round(up)`, ValueVector},
+		{`scalar(up) * 2`, ValueScalar},
+		{`time() - 60`, ValueScalar},
+		{`1 > bool 2`, ValueScalar},
+		{`a / on (instance) group_left (job) b`, ValueVector},
+		{`a > 0 and b or c unless d`, ValueVector},
+		{`-(a + b) ^ 2`, ValueVector},
+		{"up # comment\n + 1", ValueVector},
+		{`SUM(up)`, ValueVector},
+	}
+
+	for _, tc := range cases {
+		typ, err := Check(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.typ, typ, tc.in)
+	}
+}
+
+func TestCheckErrors(t *T.T) {
+	cases := []struct {
+		in  string
+		pos string
+		msg string
+	}{
+		{``, "1:1", "empty expression"},
+		{`sum(up`, "1:7", `expected ")", got end of input`},
+		{`up{job="api"`, "1:13", `expected "}", got end of input`},
+		{`up{job=api}`, "1:8", `expected label value, got "api"`},
+		{`up{job~"a"}`, "1:7", `unexpected character '~'`},
+		{`{job=""}`, "1:1", "vector selector must contain at least one non-empty matcher"},
+		{`up{job=~"("}`, "1:9", "invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{`up[5]`, "1:4", `expected duration, got "5"`},
+		{`up[0s]`, "1:4", `duration "0s" must be greater than 0`},
+		{`up[5m1h]`, "1:4", `invalid duration "5m1h": units should be in descending order`},
+		{`rate(up[5m])[5m]`, "1:13", "ranges only allowed for vector selectors"},
+		{`up offset 5m [5m]`, "1:14", "no offset or @ modifier allowed before range"},
+		{`sum(up) offset 5m`, "1:9", "offset modifier must be preceded by a selector or subquery"},
+		{`up offset 1m offset 2m`, "1:14", "offset may not be set multiple times"},
+		{`rate(up)`, "1:6", `expected type matrix in call to function "rate", got vector`},
+		{`foo(up)`, "1:1", `unknown function "foo"`},
+		{`clamp(up, 1)`, "1:1", `wrong number of arguments for function "clamp", got 2`},
+		{`topk(up)`, "1:1", `wrong number of arguments for aggregation "topk", got 1`},
+		{`sum by (a) (up) by (b)`, "1:17", `aggregation "sum" grouped multiple times`},
+		{`1 > 2`, "1:3", "comparisons between scalars must use bool modifier"},
+		{`a + bool b`, "1:5", "bool modifier can only be used on comparison operators"},
+		{`1 and up`, "1:3", `set operator "and" not allowed in binary scalar expression`},
+		{`a and on (x) group_left b`, "1:14", `no grouping allowed for "and" operation`},
+		{`a + group_left b`, "1:5", "group_left requires on or ignoring"},
+		{`up[5m] + 1`, "1:8", "binary expression must contain only scalar and vector types, got matrix"},
+		{`-"a"`, "1:1", "unary expression only allowed on scalar and vector, got string"},
+		{`up up`, "1:4", `unexpected "up"`},
+		{`"abc`, "1:1", "unterminated string"},
+	}
+
+	for _, tc := range cases {
+		_, err := Check(tc.in)
+		require.Error(t, err, tc.in)
+
+		perr, ok := err.(*Error)
+		require.True(t, ok, tc.in)
+		assert.Equal(t, tc.pos, perr.Pos.String(), tc.in)
+		assert.Equal(t, tc.msg, perr.Msg, tc.in)
+	}
+}
+
+func TestCheckRange(t *T.T) {
+	assert.NoError(t, CheckRange(`rate(up[5m])`))
+	assert.NoError(t, CheckRange(`1`))
+	assert.Error(t, CheckRange(`up[5m]`))
+	assert.Error(t, CheckRange(`"str"`))
+	assert.Error(t, CheckRange(`up{`))
+}
+
+func TestCheckSelector(t *T.T) {
+	assert.NoError(t, CheckSelector(`up{job="api"}`))
+	assert.NoError(t, CheckSelector(`{__name__=~"http_.*"} offset 5m`))
+	assert.Error(t, CheckSelector(`rate(up[5m])`))
+	assert.Error(t, CheckSelector(`up[5m]`))
+	assert.Error(t, CheckSelector(`(up)`))
+	assert.Error(t, CheckSelector(`up{`))
+}
+
+func TestParseDuration(t *T.T) {
+	cases := map[string]time.Duration{
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1d":    24 * time.Hour,
+		"1w":    7 * 24 * time.Hour,
+		"1y2d":  367 * 24 * time.Hour,
+		"150ms": 150 * time.Millisecond,
+	}
+
+	for in, want := range cases {
+		du, err := ParseDuration(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, du, in)
+	}
+
+	for _, in := range []string{"1m1m", "0s", "1ms1s"} {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+}
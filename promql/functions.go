@@ -0,0 +1,118 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package promql
+
+type function struct {
+	args     []ValueType
+	optional int  // number of trailing optional arguments
+	variadic bool // the last argument can be repeated
+	ret      ValueType
+}
+
+var (
+	vectorFn   = &function{args: []ValueType{ValueVector}, ret: ValueVector}
+	optionalFn = &function{args: []ValueType{ValueVector}, optional: 1, ret: ValueVector}
+	matrixFn   = &function{args: []ValueType{ValueMatrix}, ret: ValueVector}
+)
+
+// functions of PromQL.
+var functions = map[string]*function{
+	"abs":                vectorFn,
+	"absent":             vectorFn,
+	"absent_over_time":   matrixFn,
+	"acos":               vectorFn,
+	"acosh":              vectorFn,
+	"asin":               vectorFn,
+	"asinh":              vectorFn,
+	"atan":               vectorFn,
+	"atanh":              vectorFn,
+	"avg_over_time":      matrixFn,
+	"ceil":               vectorFn,
+	"changes":            matrixFn,
+	"clamp":              {args: []ValueType{ValueVector, ValueScalar, ValueScalar}, ret: ValueVector},
+	"clamp_max":          {args: []ValueType{ValueVector, ValueScalar}, ret: ValueVector},
+	"clamp_min":          {args: []ValueType{ValueVector, ValueScalar}, ret: ValueVector},
+	"cos":                vectorFn,
+	"cosh":               vectorFn,
+	"count_over_time":    matrixFn,
+	"day_of_month":       optionalFn,
+	"day_of_week":        optionalFn,
+	"day_of_year":        optionalFn,
+	"days_in_month":      optionalFn,
+	"deg":                vectorFn,
+	"delta":              matrixFn,
+	"deriv":              matrixFn,
+	"exp":                vectorFn,
+	"floor":              vectorFn,
+	"histogram_quantile": {args: []ValueType{ValueScalar, ValueVector}, ret: ValueVector},
+	"holt_winters":       {args: []ValueType{ValueMatrix, ValueScalar, ValueScalar}, ret: ValueVector},
+	"hour":               optionalFn,
+	"idelta":             matrixFn,
+	"increase":           matrixFn,
+	"irate":              matrixFn,
+	"label_join": {
+		args:     []ValueType{ValueVector, ValueString, ValueString, ValueString},
+		variadic: true,
+		ret:      ValueVector,
+	},
+	"label_replace": {
+		args: []ValueType{ValueVector, ValueString, ValueString, ValueString, ValueString},
+		ret:  ValueVector,
+	},
+	"last_over_time":     matrixFn,
+	"ln":                 vectorFn,
+	"log10":              vectorFn,
+	"log2":               vectorFn,
+	"max_over_time":      matrixFn,
+	"min_over_time":      matrixFn,
+	"minute":             optionalFn,
+	"month":              optionalFn,
+	"pi":                 {ret: ValueScalar},
+	"predict_linear":     {args: []ValueType{ValueMatrix, ValueScalar}, ret: ValueVector},
+	"present_over_time":  matrixFn,
+	"quantile_over_time": {args: []ValueType{ValueScalar, ValueMatrix}, ret: ValueVector},
+	"rad":                vectorFn,
+	"rate":               matrixFn,
+	"resets":             matrixFn,
+	"round":              {args: []ValueType{ValueVector, ValueScalar}, optional: 1, ret: ValueVector},
+	"scalar":             {args: []ValueType{ValueVector}, ret: ValueScalar},
+	"sgn":                vectorFn,
+	"sin":                vectorFn,
+	"sinh":               vectorFn,
+	"sort":               vectorFn,
+	"sort_desc":          vectorFn,
+	"sqrt":               vectorFn,
+	"stddev_over_time":   matrixFn,
+	"stdvar_over_time":   matrixFn,
+	"sum_over_time":      matrixFn,
+	"tan":                vectorFn,
+	"tanh":               vectorFn,
+	"time":               {ret: ValueScalar},
+	"timestamp":          vectorFn,
+	"vector":             {args: []ValueType{ValueScalar}, ret: ValueVector},
+	"year":               optionalFn,
+}
+
+var (
+	simpleAgg = &function{args: []ValueType{ValueVector}, ret: ValueVector}
+	paramAgg  = &function{args: []ValueType{ValueScalar, ValueVector}, ret: ValueVector}
+)
+
+// aggregation operators of PromQL, names are case insensitive.
+var aggregations = map[string]*function{
+	"avg":          simpleAgg,
+	"bottomk":      paramAgg,
+	"count":        simpleAgg,
+	"count_values": {args: []ValueType{ValueString, ValueVector}, ret: ValueVector},
+	"group":        simpleAgg,
+	"max":          simpleAgg,
+	"min":          simpleAgg,
+	"quantile":     paramAgg,
+	"stddev":       simpleAgg,
+	"stdvar":       simpleAgg,
+	"sum":          simpleAgg,
+	"topk":         paramAgg,
+}
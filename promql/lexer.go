@@ -0,0 +1,309 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package promql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tEOF tokenType = iota
+	tIllegal
+	tIdent
+	tNumber
+	tDuration
+	tString
+	tLParen
+	tRParen
+	tLBrace
+	tRBrace
+	tLBracket
+	tRBracket
+	tComma
+	tColon
+	tAt
+	tOp // operators: + - * / % ^ == != < <= > >= = =~ !~
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	pos  Pos
+}
+
+func (t token) describe() string {
+	switch t.typ {
+	case tEOF:
+		return "end of input"
+	case tIllegal:
+		return t.text
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+type lexer struct {
+	input string
+	pos   int
+	line  int
+	col   int
+}
+
+func (l *lexer) cur() Pos {
+	return Pos{Offset: l.pos, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) peek(n int) byte {
+	if l.pos+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos+n]
+}
+
+func (l *lexer) advance() {
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+}
+
+func (l *lexer) skip() {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			l.advance()
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+var durationUnits = []string{"ms", "s", "m", "h", "d", "w", "y"}
+
+func (l *lexer) next() token {
+	l.skip()
+
+	start := l.cur()
+	tok := func(typ tokenType) token {
+		return token{typ: typ, text: l.input[start.Offset:l.pos], pos: start}
+	}
+
+	if l.pos >= len(l.input) {
+		return token{typ: tEOF, pos: start}
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case c != ':' && isIdentStart(c): // leading ':' is the subquery colon
+		for l.pos < len(l.input) && (isIdentStart(l.input[l.pos]) || isDigit(l.input[l.pos])) {
+			l.advance()
+		}
+		return tok(tIdent)
+
+	case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+		return l.number(start)
+
+	case c == '"' || c == '\'' || c == '`':
+		return l.str(start, c)
+	}
+
+	l.advance()
+	switch c {
+	case '(':
+		return tok(tLParen)
+	case ')':
+		return tok(tRParen)
+	case '{':
+		return tok(tLBrace)
+	case '}':
+		return tok(tRBrace)
+	case '[':
+		return tok(tLBracket)
+	case ']':
+		return tok(tRBracket)
+	case ',':
+		return tok(tComma)
+	case ':':
+		return tok(tColon)
+	case '@':
+		return tok(tAt)
+	case '+', '-', '*', '/', '%', '^':
+		return tok(tOp)
+	case '=':
+		if l.peek(0) == '=' || l.peek(0) == '~' {
+			l.advance()
+		}
+		return tok(tOp)
+	case '!':
+		if l.peek(0) == '=' || l.peek(0) == '~' {
+			l.advance()
+			return tok(tOp)
+		}
+	case '<', '>':
+		if l.peek(0) == '=' {
+			l.advance()
+		}
+		return tok(tOp)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[start.Offset:])
+	return token{typ: tIllegal, text: fmt.Sprintf("unexpected character %q", r), pos: start}
+}
+
+func (l *lexer) number(start Pos) token {
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.advance()
+		l.advance()
+		for isHex(l.peek(0)) {
+			l.advance()
+		}
+		return token{typ: tNumber, text: l.input[start.Offset:l.pos], pos: start}
+	}
+
+	for isDigit(l.peek(0)) {
+		l.advance()
+	}
+
+	isFloat := false
+	if l.peek(0) == '.' {
+		isFloat = true
+		l.advance()
+		for isDigit(l.peek(0)) {
+			l.advance()
+		}
+	}
+
+	if c := l.peek(0); c == 'e' || c == 'E' {
+		n := 1
+		if s := l.peek(1); s == '+' || s == '-' {
+			n = 2
+		}
+		if isDigit(l.peek(n)) {
+			isFloat = true
+			for i := 0; i < n; i++ {
+				l.advance()
+			}
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+	}
+
+	if !isFloat && l.unit() {
+		// compound duration, such as 1h30m
+		for isDigit(l.peek(0)) {
+			save := *l
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+			if !l.unit() {
+				*l = save
+				break
+			}
+		}
+		return token{typ: tDuration, text: l.input[start.Offset:l.pos], pos: start}
+	}
+
+	return token{typ: tNumber, text: l.input[start.Offset:l.pos], pos: start}
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// unit consume a duration unit not followed by identifier characters.
+func (l *lexer) unit() bool {
+	for _, u := range durationUnits {
+		if !strings.HasPrefix(l.input[l.pos:], u) {
+			continue
+		}
+
+		if next := l.peek(len(u)); isIdentStart(next) && next != ':' {
+			continue
+		}
+
+		for i := 0; i < len(u); i++ {
+			l.advance()
+		}
+		return true
+	}
+	return false
+}
+
+func (l *lexer) str(start Pos, quote byte) token {
+	l.advance()
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == quote:
+			l.advance()
+			return token{typ: tString, text: l.input[start.Offset:l.pos], pos: start}
+		case c == '\\' && quote != '`':
+			l.advance()
+			if l.pos < len(l.input) {
+				l.advance()
+			}
+		case c == '\n' && quote != '`':
+			return token{typ: tIllegal, text: "unterminated string", pos: start}
+		default:
+			l.advance()
+		}
+	}
+
+	return token{typ: tIllegal, text: "unterminated string", pos: start}
+}
+
+// unquote get the value of string token.
+func unquote(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+
+	body := s[1 : len(s)-1]
+	if s[0] == '`' {
+		return body
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\\' && i+1 < len(body) {
+			i++
+			switch body[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(body[i])
+			}
+			continue
+		}
+		sb.WriteByte(body[i])
+	}
+	return sb.String()
+}
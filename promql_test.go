@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func promTestResult() *DQLResult {
+	return &DQLResult{Series: []*Row{
+		{
+			Name:    "http_requests_total",
+			Tags:    map[string]string{"job": "api"},
+			Columns: []string{"time", "value"},
+			Values: [][]any{
+				{float64(1000), 1.0},
+				{float64(2500), 2.5},
+				{float64(4000), nil},
+			},
+		},
+		{
+			Tags:    map[string]string{"job": "web"},
+			Columns: []string{"time", "value"},
+			Values:  [][]any{{float64(1000), math.Inf(1)}},
+		},
+	}}
+}
+
+func TestBuildPromQL(t *T.T) {
+	start, end := time.UnixMilli(1000), time.UnixMilli(61000)
+
+	t.Run("range", func(t *T.T) {
+		q, err := BuildPromQL("rate(up[5m])", start, end, 15*time.Second, WithValidate(true))
+		require.NoError(t, err)
+
+		assert.Equal(t, "promql", q.QType)
+		assert.Equal(t, []int64{1000, 61000}, q.TimeRange)
+		assert.Equal(t, int64(15), q.Interval)
+		assert.False(t, q.instant)
+	})
+
+	t.Run("instant", func(t *T.T) {
+		q, err := BuildInstantPromQL("up[5m]", end, WithValidate(true))
+		require.NoError(t, err)
+
+		assert.Equal(t, []int64{61000, 61000}, q.TimeRange)
+		assert.Equal(t, int64(0), q.Interval)
+		assert.Equal(t, promql.ValueMatrix, q.resultType())
+
+		q, err = BuildInstantPromQL("scalar(up)", end)
+		require.NoError(t, err)
+		assert.Equal(t, promql.ValueScalar, q.resultType())
+	})
+
+	t.Run("invalid-options", func(t *T.T) {
+		_, err := BuildPromQL("up", end, start, time.Second)
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildPromQL("up", start, end, 1500*time.Millisecond)
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithQueryType("sql"))
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+	})
+
+	t.Run("syntax", func(t *T.T) {
+		_, err := BuildPromQL("some promql{", start, end, time.Second)
+		assert.NoError(t, err) // not validated
+
+		_, err = BuildPromQL("some promql{", start, end, time.Second, WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrParse))
+
+		var perr *promql.Error
+		require.True(t, errors.As(err, &perr))
+		assert.Equal(t, "1:6", perr.Pos.String())
+
+		// range query requires scalar or vector
+		_, err = BuildPromQL("up[5m]", start, end, time.Second, WithValidate(true))
+		assert.True(t, errors.Is(err, ErrParse))
+	})
+}
+
+func TestPromResult(t *T.T) {
+	t.Run("matrix", func(t *T.T) {
+		m, err := promTestResult().PromMatrix()
+		require.NoError(t, err)
+		require.Len(t, m, 2)
+
+		assert.Equal(t, map[string]string{"job": "web"}, m[0].Metric)
+		assert.Equal(t, map[string]string{"__name__": "http_requests_total", "job": "api"}, m[1].Metric)
+		assert.Len(t, m[1].Values, 2) // null dropped
+
+		j, err := json.Marshal(&PromResult{ResultType: promql.ValueMatrix, Result: m})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"resultType":"matrix","result":[
+			{"metric":{"job":"web"},"values":[[1,"+Inf"]]},
+			{"metric":{"__name__":"http_requests_total","job":"api"},"values":[[1,"1"],[2.5,"2.5"]]}
+		]}`, string(j))
+	})
+
+	t.Run("vector-and-scalar", func(t *T.T) {
+		r, err := promTestResult().PromResult(promql.ValueVector)
+		require.NoError(t, err)
+
+		v := r.Result.(PromVector)
+		require.Len(t, v, 2)
+		assert.Equal(t, PromPoint{T: time.UnixMilli(2500), V: 2.5}, v[1].Value)
+
+		_, err = promTestResult().PromScalar()
+		assert.Error(t, err)
+
+		s, err := (&DQLResult{Series: []*Row{{
+			Columns: []string{"time", "value"},
+			Values:  [][]any{{float64(3000), 42.0}},
+		}}}).PromScalar()
+		require.NoError(t, err)
+		assert.Equal(t, 42.0, s.V)
+
+		_, err = promTestResult().PromResult(promql.ValueString)
+		assert.Error(t, err)
+	})
+
+	t.Run("multiple-columns", func(t *T.T) {
+		m, err := (&DQLResult{Series: []*Row{{
+			Name:    "cpu",
+			Columns: []string{"time", "user", "system"},
+			Values:  [][]any{{float64(1000), 1.0, 2.0}},
+		}}}).PromMatrix()
+		require.NoError(t, err)
+		require.Len(t, m, 2)
+		assert.Equal(t, "system", m[0].Metric["__field__"])
+		assert.Equal(t, "user", m[1].Metric["__field__"])
+	})
+
+	t.Run("point-json", func(t *T.T) {
+		var p PromPoint
+		require.NoError(t, json.Unmarshal([]byte(`[1435781451.781,"-Inf"]`), &p))
+		assert.Equal(t, time.UnixMilli(1435781451781), p.T)
+		assert.True(t, math.IsInf(p.V, -1))
+
+		assert.Error(t, json.Unmarshal([]byte(`[1, "abc"]`), &p))
+		assert.Error(t, json.Unmarshal([]byte(`{}`), &p))
+	})
+}
+
+func TestQueryPromQL(t *T.T) {
+	var received *dql
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q query
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+		received = q.Queries[0]
+
+		res := promTestResult()
+		// +Inf can not be JSON encoded
+		res.Series = res.Series[:1]
+		json.NewEncoder(w).Encode(&Result{Content: []*DQLResult{res}}) //nolint:errcheck
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.Listener.Addr().String())
+
+	q, err := BuildPromQL("up", time.UnixMilli(0), time.UnixMilli(60000), time.Minute)
+	require.NoError(t, err)
+
+	res, err := c.QueryPromQL(context.Background(), q)
+	require.NoError(t, err)
+	assert.Equal(t, promql.ValueMatrix, res.ResultType)
+	assert.Len(t, res.Result.(PromMatrix), 1)
+
+	assert.Equal(t, "promql", received.QType)
+	assert.Equal(t, int64(60), received.Interval)
+
+	q, err = BuildInstantPromQL("up", time.UnixMilli(60000))
+	require.NoError(t, err)
+
+	res, err = c.QueryPromQL(context.Background(), q)
+	require.NoError(t, err)
+	assert.Equal(t, promql.ValueVector, res.ResultType)
+
+	_, err = c.QueryPromQL(context.Background(), MustBuildDQL("L::nginx"))
+	assert.True(t, errors.Is(err, ErrInvalidOption))
+}
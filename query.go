@@ -7,15 +7,20 @@ package dql
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 )
 
 type query struct {
-	https bool
+	https      bool
+	queryError bool
 
 	EchoExplain bool   `json:"echo_explain"`
 	Token       string `json:"token,omitempty"`
@@ -41,26 +46,76 @@ func (q *query) json(indent bool) string {
 // A Client is the DQL query client connecting to a exist Datakit
 // or directly to Dataway(and the token required).
 type Client struct {
-	host   string
-	cli    *http.Client
-	dqlURL string
+	host    string
+	baseURL string
+	cli     *http.Client
+	headers http.Header
 
+	tlsConf *tls.Config
+	proxy   func(*http.Request) (*url.URL, error)
+
+	mu        sync.Mutex
 	lastQuery *query
+
+	retry *RetryPolicy
 }
 
 // NewClient create a Datakit/Dataway client with IP:Port.
 // For example, local default Datakit host is localhost:9529, for
 // directly to dataway, the default host is openway.guance.com.
-func NewClient(host string) *Client {
+//
+// We can set HTTP client, TLS, proxy and extra headers within ClientOptions.
+func NewClient(host string, opts ...ClientOption) *Client {
 	c := &Client{
-		host: host,
+		host:    host,
+		headers: http.Header{},
 	}
 
-	c.cli = &http.Client{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+
+	if c.cli == nil {
+		c.cli = &http.Client{}
+	}
+
+	if c.tlsConf != nil || c.proxy != nil {
+		c.setupTransport()
+	}
 
 	return c
 }
 
+// setupTransport apply TLS and proxy settings to the HTTP client's transport.
+// The HTTP client and it's transport are copied, so the one passed
+// by WithHTTPClient not changed.
+func (c *Client) setupTransport() {
+	cli := *c.cli
+
+	var tr *http.Transport
+	switch x := cli.Transport.(type) {
+	case nil:
+		tr = http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	case *http.Transport:
+		tr = x.Clone()
+	default: // custom RoundTripper, we can't set TLS and proxy on it
+		return
+	}
+
+	if c.tlsConf != nil {
+		tr.TLSClientConfig = c.tlsConf
+	}
+
+	if c.proxy != nil {
+		tr.Proxy = c.proxy
+	}
+
+	cli.Transport = tr
+	c.cli = &cli
+}
+
 // A Result is the query result of DQL request. Within a Result
 // there maybe multiple DQL query result.
 // If there any error on query, we can see them with ErrorCode and Message.
@@ -127,6 +182,14 @@ type Row struct {
 // Query send one or more DQL query to Datakit. We can build
 // DQL within QueryOptions.
 func (c *Client) Query(opts ...QueryOption) (*Result, error) {
+	return c.QueryContext(context.Background(), opts...)
+}
+
+// QueryContext is the same as Query, but the query request bound to ctx.
+// If ctx canceled or it's deadline exceeded, the in-flight HTTP request
+// aborted, and the returned error wraps context.Canceled or
+// context.DeadlineExceeded, we can check them with errors.Is().
+func (c *Client) QueryContext(ctx context.Context, opts ...QueryOption) (*Result, error) {
 	q := &query{}
 
 	for _, opt := range opts {
@@ -135,51 +198,111 @@ func (c *Client) Query(opts ...QueryOption) (*Result, error) {
 		}
 	}
 
-	return c.do(q)
+	return c.do(ctx, q)
 }
 
-func (c *Client) do(q *query) (*Result, error) {
-	j, err := json.Marshal(q)
-	if err != nil {
-		c.lastQuery = nil
-		return nil, err
+// ctxErr check if err caused by ctx, and if so, wrap the context
+// error so caller can distinguish it from backend errors.
+func ctxErr(ctx context.Context, err error) error {
+	if cerr := ctx.Err(); cerr != nil {
+		return fmt.Errorf("dql query aborted: %w", cerr)
 	}
+	return err
+}
 
+// setLastQuery record the latest query sent by the client.
+func (c *Client) setLastQuery(q *query) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.lastQuery = q
+}
 
-	if c.dqlURL == "" {
-		if q.https {
-			c.dqlURL = fmt.Sprintf("https://%s/v1/query/raw", c.host)
-		} else {
-			c.dqlURL = fmt.Sprintf("http://%s/v1/query/raw", c.host)
-		}
+// getLastQuery get the latest query sent by the client. Under concurrent
+// queries, it's any one of them.
+func (c *Client) getLastQuery() *query {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastQuery
+}
 
-		if q.Token != "" {
-			c.dqlURL = fmt.Sprintf("%s?token=%s", c.dqlURL, q.Token)
-		}
+// queryURL build the query URL of q. Token and HTTPS are
+// query options, so the URL are different among queries.
+func (c *Client) queryURL(q *query) string {
+	var u string
+
+	switch {
+	case c.baseURL != "":
+		u = c.baseURL + "/v1/query/raw"
+	case q.https:
+		u = fmt.Sprintf("https://%s/v1/query/raw", c.host)
+	default:
+		u = fmt.Sprintf("http://%s/v1/query/raw", c.host)
 	}
 
-	req, err := http.NewRequest("POST", c.dqlURL, bytes.NewBuffer(j))
+	if q.Token != "" {
+		u = fmt.Sprintf("%s?token=%s", u, url.QueryEscape(q.Token))
+	}
+
+	return u
+}
+
+func (c *Client) do(ctx context.Context, q *query) (*Result, error) {
+	j, err := json.Marshal(q)
 	if err != nil {
+		c.setLastQuery(nil)
 		return nil, err
 	}
 
+	c.setLastQuery(q)
+
+	if c.retry == nil {
+		r, _, err := c.roundTrip(ctx, q, j)
+		return r, err
+	}
+
+	return c.retry.do(ctx, q, func() (*Result, *http.Response, error) {
+		return c.roundTrip(ctx, q, j)
+	})
+}
+
+// roundTrip send the marshalled query body once. The returned response
+// is nil if the request not sent or no response received, and it's body
+// has been read and closed.
+func (c *Client) roundTrip(ctx context.Context, q *query, body []byte) (*Result, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.queryURL(q), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for k, v := range c.headers {
+		req.Header[k] = v
+	}
+
 	resp, err := c.cli.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, ctxErr(ctx, err)
 	}
 
 	defer resp.Body.Close() //nolint:errcheck
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, ctxErr(ctx, err)
 	}
 
+	ok := resp.StatusCode/100 == 2
+
 	var r Result
-	if err := json.Unmarshal(body, &r); err != nil {
-		return nil, err
+	if err := json.Unmarshal(respBody, &r); err != nil {
+		if !ok {
+			return nil, resp, newQueryError(q, resp.StatusCode, nil, respBody)
+		}
+		return nil, resp, err
+	}
+
+	if q.queryError && (!ok || r.ErrorCode != "") {
+		return &r, resp, newQueryError(q, resp.StatusCode, &r, respBody)
 	}
 
-	return &r, nil
+	return &r, resp, nil
 }
@@ -6,9 +6,16 @@
 package dql
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	T "testing"
 	"time"
 
@@ -45,7 +52,7 @@ func TestQueryDataway(t *T.T) {
 		assert.NoError(t, err)
 
 		t.Logf("request:\n%s\n---\nseries: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -78,7 +85,7 @@ func TestQueryDataway(t *T.T) {
 		assert.Empty(t, r.Content)
 
 		t.Logf("request:\n%s\n---\n client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			time.Since(start),
 		)
 	})
@@ -105,7 +112,7 @@ func TestQueryDataway(t *T.T) {
 		assert.Empty(t, r.Content)
 
 		t.Logf("request:\n%s\n---\n client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			time.Since(start),
 		)
 	})
@@ -125,7 +132,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -147,7 +154,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -175,7 +182,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -347,7 +354,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -370,7 +377,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -388,7 +395,7 @@ func TestQueryDataway(t *T.T) {
 		require.NotEmpty(t, r.Content[0].Series)
 
 		t.Logf("request:\n%s\n---\nresult: %d, cost: %s, client-cost: %s",
-			c.lastQuery.json(true),
+			c.getLastQuery().json(true),
 			len(r.Content[0].Series[0].Values),
 			r.Content[0].Cost,
 			time.Since(start),
@@ -397,6 +404,14 @@ func TestQueryDataway(t *T.T) {
 }
 
 func TestQuery(t *T.T) {
+	// run these test with a local Datakit listening on localhost:9529, see
+	// package dqltest for tests without Datakit.
+	conn, err := net.DialTimeout("tcp", "localhost:9529", time.Second)
+	if err != nil {
+		t.Skipf("%s skipped due to local Datakit not available: %s", t.Name(), err)
+	}
+	conn.Close()
+
 	t.Run("single-dql", func(t *T.T) {
 		c := NewClient("localhost:9529")
 
@@ -485,3 +500,102 @@ func TestQuery(t *T.T) {
 		t.Logf("result:\n%s", string(j))
 	})
 }
+
+func TestQueryContext(t *T.T) {
+	t.Run("cancel", func(t *T.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer ts.Close()
+		defer close(done)
+
+		c := NewClient(ts.Listener.Addr().String())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		_, err := c.QueryContext(ctx, WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.False(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("deadline-on-body-read", func(t *T.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"content":[`)) //nolint:errcheck
+			w.(http.Flusher).Flush()
+			<-done
+		}))
+		defer ts.Close()
+		defer close(done)
+
+		c := NewClient(ts.Listener.Addr().String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := c.QueryContext(ctx, WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("ok", func(t *T.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"content":[{"series":[{"name":"cpu"}]}]}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String())
+
+		r, err := c.QueryContext(context.Background(), WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.NoError(t, err)
+		assert.Equal(t, "cpu", r.Content[0].Series[0].Name)
+	})
+}
+
+func TestConcurrentQuery(t *T.T) {
+	// run these test with:
+	//   go test -race -run TestConcurrentQuery
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q query
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// echo token and DQL back within series name and tags
+		res := &Result{Content: []*DQLResult{
+			{Series: []*Row{{Name: r.URL.Query().Get("token"), Tags: map[string]string{"query": q.Queries[0].DQL}}}},
+		}}
+
+		json.NewEncoder(w).Encode(res) //nolint:errcheck
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.Listener.Addr().String())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			token := fmt.Sprintf("tkn_%d", i)
+			dql := fmt.Sprintf("M::cpu_%d LIMIT 1", i)
+
+			r, err := c.Query(WithToken(token), WithQueries(MustBuildDQL(dql)))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, token, r.Content[0].Series[0].Name)
+			assert.Equal(t, dql, r.Content[0].Series[0].Tags["query"])
+			assert.NotNil(t, c.getLastQuery())
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,238 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A RetryPolicy control how the failed query retried.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of requests sent, include the first one.
+	// Values less than 2 disable retry.
+	MaxAttempts int
+
+	// Backoff of the n-th retry is MinBackoff * 2^(n-1), and no more than MaxBackoff.
+	// If the server responded Retry-After, we wait at least that long.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// MaxRetryAfter is the max Retry-After we accept, retry given up if the
+	// server asked to wait longer(or beyond the deadline of the context).
+	// Zero means no limit.
+	MaxRetryAfter time.Duration
+
+	// Jitter(in [0, 1]) randomly shrink each backoff, for example, with jitter 0.2,
+	// backoff 1s will be a random duration between 800ms and 1s.
+	Jitter float64
+
+	// StatusCodes are HTTP status codes that retryable.
+	StatusCodes []int
+
+	// ErrorCodes are Result.ErrorCode values that retryable.
+	ErrorCodes []string
+
+	// Retryable, if set, overrides the default predicate over status codes,
+	// error codes and network errors. status is 0 if no response received.
+	Retryable func(status int, r *Result, err error) bool
+}
+
+// DefaultRetryPolicy retry at most 3 attempts on network errors and
+// HTTP 429/502/503/504.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.2,
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// A RetryError is the error of the last attempt after all attempts failed.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+// Error implements error interface.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("dql query failed after %d attempts: %s", e.Attempts, e.Err)
+}
+
+// Unwrap return the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (p *RetryPolicy) retryable(ctx context.Context, status int, r *Result, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if p.Retryable != nil {
+		return p.Retryable(status, r, err)
+	}
+
+	if err != nil && status == 0 {
+		return transportError(err)
+	}
+
+	for _, code := range p.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+
+	if r == nil {
+		var qe *QueryError
+		if errors.As(err, &qe) {
+			r = &Result{ErrorCode: qe.ErrorCode}
+		}
+	}
+
+	if r != nil && r.ErrorCode != "" {
+		for _, code := range p.ErrorCodes {
+			if code == r.ErrorCode {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// transportError check if err is a network error during the round trip,
+// errors such as building the request are not.
+func transportError(err error) bool {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Op != "parse"
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff get the wait duration before the n-th(start from 1) retry.
+func (p *RetryPolicy) backoff(n int, retryAfter time.Duration) time.Duration {
+	du := p.MinBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || du < p.MaxBackoff); i++ {
+		du *= 2
+	}
+
+	if p.Jitter > 0 && du > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		du -= time.Duration(rand.Float64() * j * float64(du)) //nolint:gosec
+	}
+
+	if p.MaxBackoff > 0 && du > p.MaxBackoff {
+		du = p.MaxBackoff
+	}
+
+	// Retry-After not limited by MaxBackoff
+	if retryAfter > du {
+		du = retryAfter
+	}
+
+	return du
+}
+
+// parseRetryAfter parse Retry-After header, which is delay-seconds or HTTP-date.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if du := time.Until(t); du > 0 {
+			return du
+		}
+	}
+
+	return 0
+}
+
+// allowWait check if we can wait du before next retry.
+func (p *RetryPolicy) allowWait(ctx context.Context, du time.Duration) bool {
+	if p.MaxRetryAfter > 0 && du > p.MaxRetryAfter {
+		return false
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(du).After(deadline) {
+		return false
+	}
+
+	return true
+}
+
+// do call fn until success, not retryable or attempts exhausted. If the
+// last attempt still retryable, a RetryError returned, even if the failure
+// only within the Result(see WithQueryError).
+func (p *RetryPolicy) do(ctx context.Context, q *query, fn func() (*Result, *http.Response, error)) (*Result, error) {
+	for n := 1; ; n++ {
+		r, resp, err := fn()
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+
+		if !p.retryable(ctx, status, r, err) {
+			if err != nil && n > 1 {
+				return r, &RetryError{Attempts: n, Err: err}
+			}
+			return r, err
+		}
+
+		if err == nil {
+			err = newQueryError(q, status, r, nil)
+		}
+
+		if n >= p.MaxAttempts {
+			return r, &RetryError{Attempts: n, Err: err}
+		}
+
+		retryAfter := parseRetryAfter(resp)
+		if retryAfter > 0 && !p.allowWait(ctx, retryAfter) {
+			return r, &RetryError{Attempts: n, Err: fmt.Errorf("retry after %s not allowed: %w", retryAfter, err)}
+		}
+
+		t := time.NewTimer(p.backoff(n, retryAfter))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, &RetryError{Attempts: n, Err: ctxErr(ctx, err)}
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *T.T) {
+	policy := func() *RetryPolicy {
+		p := DefaultRetryPolicy()
+		p.MinBackoff = time.Millisecond
+		p.MaxBackoff = 10 * time.Millisecond
+		return p
+	}
+
+	t.Run("retry-on-status", func(t *T.T) {
+		var (
+			n      int32
+			bodies []string
+		)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+
+			if atomic.AddInt32(&n, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"content":[{"cost":"1ms"}]}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(policy()))
+		r, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.NoError(t, err)
+		assert.Equal(t, "1ms", r.Content[0].Cost)
+		assert.Equal(t, int32(3), n)
+
+		require.Len(t, bodies, 3)
+		assert.Equal(t, bodies[0], bodies[1])
+		assert.Equal(t, bodies[0], bodies[2])
+	})
+
+	t.Run("attempts-exhausted", func(t *T.T) {
+		var n int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(policy()))
+		_, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+
+		var re *RetryError
+		require.True(t, errors.As(err, &re))
+		assert.Equal(t, 3, re.Attempts)
+		assert.Equal(t, int32(3), n)
+
+		var qe *QueryError
+		require.True(t, errors.As(err, &qe))
+		assert.Equal(t, http.StatusBadGateway, qe.StatusCode)
+	})
+
+	t.Run("not-retryable", func(t *T.T) {
+		var n int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error_code":"dql.parse_error"}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(policy()))
+		_, err := c.Query(WithQueryError(true), WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+		assert.Equal(t, int32(1), n)
+
+		var re *RetryError
+		assert.False(t, errors.As(err, &re))
+	})
+
+	t.Run("retry-on-error-code", func(t *T.T) {
+		var n int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&n, 1) < 2 {
+				w.Write([]byte(`{"error_code":"kodo.busy"}`)) //nolint:errcheck
+				return
+			}
+			w.Write([]byte(`{"content":[]}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		p := policy()
+		p.ErrorCodes = []string{"kodo.busy"}
+
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(p))
+		r, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.NoError(t, err)
+		assert.Empty(t, r.ErrorCode)
+		assert.Equal(t, int32(2), n)
+	})
+
+	t.Run("retry-after", func(t *T.T) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", "2")
+		assert.Equal(t, 2*time.Second, parseRetryAfter(resp))
+
+		resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		assert.Greater(t, parseRetryAfter(resp), 59*time.Minute)
+
+		p := &RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: time.Minute}
+		assert.Equal(t, 2*time.Second, p.backoff(1, 2*time.Second))
+		assert.Equal(t, time.Hour, p.backoff(1, time.Hour)) // at least Retry-After
+
+		// give up if Retry-After too long
+		var n int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer ts.Close()
+
+		p = policy()
+		p.MaxRetryAfter = time.Minute
+
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(p))
+		_, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+
+		var re *RetryError
+		require.True(t, errors.As(err, &re))
+		assert.Equal(t, 1, re.Attempts)
+		assert.Equal(t, int32(1), n)
+
+		// or beyond the deadline
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		c = NewClient(ts.Listener.Addr().String(), WithRetry(policy()))
+		_, err = c.QueryContext(ctx, WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.True(t, errors.As(err, &re))
+		assert.Equal(t, 1, re.Attempts)
+		assert.Equal(t, int32(2), n)
+	})
+
+	t.Run("exhausted-within-result", func(t *T.T) {
+		var n int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&n, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error_code":"kodo.busy","message":"busy"}`)) //nolint:errcheck
+		}))
+		defer ts.Close()
+
+		// query error not enabled
+		c := NewClient(ts.Listener.Addr().String(), WithRetry(policy()))
+		r, err := c.Query(WithQueries(MustBuildDQL("M::cpu LIMIT 1")))
+		require.Error(t, err)
+		assert.Equal(t, "kodo.busy", r.ErrorCode)
+
+		var re *RetryError
+		require.True(t, errors.As(err, &re))
+		assert.Equal(t, 3, re.Attempts)
+
+		var qe *QueryError
+		require.True(t, errors.As(err, &qe))
+		assert.Equal(t, http.StatusServiceUnavailable, qe.StatusCode)
+		assert.Equal(t, "kodo.busy", qe.ErrorCode)
+	})
+
+	t.Run("transport-error", func(t *T.T) {
+		p := policy()
+		ctx := context.Background()
+
+		assert.True(t, p.retryable(ctx, 0, nil, &url.Error{Op: "Post", URL: "http://x", Err: errors.New("connection refused")}))
+		assert.True(t, p.retryable(ctx, 0, nil, io.ErrUnexpectedEOF))
+		assert.False(t, p.retryable(ctx, 0, nil, &url.Error{Op: "parse", URL: "::", Err: errors.New("bad url")}))
+		assert.False(t, p.retryable(ctx, 0, nil, errors.New("json: unsupported value")))
+	})
+
+	t.Run("backoff", func(t *T.T) {
+		p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+		assert.Equal(t, 100*time.Millisecond, p.backoff(1, 0))
+		assert.Equal(t, 200*time.Millisecond, p.backoff(2, 0))
+		assert.Equal(t, 400*time.Millisecond, p.backoff(3, 0))
+		assert.Equal(t, time.Second, p.backoff(10, 0))
+
+		p.Jitter = 0.5
+		for i := 0; i < 100; i++ {
+			du := p.backoff(1, 0)
+			assert.True(t, du >= 50*time.Millisecond && du <= 100*time.Millisecond)
+		}
+	})
+}
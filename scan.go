@@ -0,0 +1,426 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	tagsType = reflect.TypeOf(map[string]string{})
+)
+
+// Scan scan the first value of the row into struct pointed by dst.
+// See ScanAt for details.
+func (r *Row) Scan(dst any) error {
+	return r.ScanAt(0, dst)
+}
+
+// ScanAt scan the i-th value of the row into struct pointed by dst.
+// Columns are mapped to struct fields by `dql` tag, for example:
+//
+//	type Log struct {
+//		Time    time.Time         `dql:"time"`
+//		Status  int               `dql:"status"`
+//		Message string            `dql:"message"`
+//		Host    string            `dql:"host"`   // from column or tag
+//		Tags    map[string]string `dql:",tags"`  // all tags of the row
+//		Ignored string            `dql:"-"`
+//	}
+//
+// Fields without `dql` tag are mapped to column with same
+// name(case-insensitive). If there is no such column, the field
+// set with the tag of the row. JSON numbers converted to int/uint/float,
+// and numbers within time.Time field are UNIX timestamp in ms.
+func (r *Row) ScanAt(i int, dst any) error {
+	if i < 0 || i >= len(r.Values) {
+		return fmt.Errorf("dql: scan value %d out of range(%d values)", i, len(r.Values))
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dql: scan destination should be non-nil struct pointer, got %T", dst)
+	}
+
+	return r.scanStruct(r.Values[i], v.Elem(), structFields(v.Elem().Type()))
+}
+
+// ScanAll scan all values of the row into slice pointed by dst, the element
+// of the slice should be struct or struct pointer. See ScanAt for details.
+func (r *Row) ScanAll(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dql: scan destination should be non-nil slice pointer, got %T", dst)
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+
+	isPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("dql: scan destination should be slice of struct, got %T", dst)
+	}
+
+	fields := structFields(structType)
+
+	for i, vals := range r.Values {
+		elem := reflect.New(structType)
+		if err := r.scanStruct(vals, elem.Elem(), fields); err != nil {
+			return fmt.Errorf("value %d: %w", i, err)
+		}
+
+		if isPtr {
+			slice = reflect.Append(slice, elem)
+		} else {
+			slice = reflect.Append(slice, elem.Elem())
+		}
+	}
+
+	v.Elem().Set(slice)
+	return nil
+}
+
+// ScanAll scan all values of all series within the result into slice pointed
+// by dst. See Row.ScanAll for details.
+func (r *DQLResult) ScanAll(dst any) error {
+	for _, s := range r.Series {
+		if err := s.ScanAll(dst); err != nil {
+			return fmt.Errorf("series %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+type scanField struct {
+	name  string
+	index []int
+	tags  bool // receive all tags
+}
+
+// structFields get scannable fields of struct type t.
+func structFields(t reflect.Type) []*scanField {
+	var fields []*scanField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag, hasTag := f.Tag.Lookup("dql")
+		if tag == "-" {
+			continue
+		}
+
+		name, opt, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			for _, sf := range structFields(f.Type) {
+				sf.index = append([]int{i}, sf.index...)
+				fields = append(fields, sf)
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, &scanField{
+			name:  name,
+			index: []int{i},
+			tags:  opt == "tags",
+		})
+	}
+
+	return fields
+}
+
+func (r *Row) scanStruct(vals []any, v reflect.Value, fields []*scanField) error {
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+
+		if f.tags {
+			if fv.Type() != tagsType {
+				return fmt.Errorf("dql: tags field %s should be map[string]string", f.name)
+			}
+
+			tags := make(map[string]string, len(r.Tags))
+			for k, val := range r.Tags {
+				tags[k] = val
+			}
+			fv.Set(reflect.ValueOf(tags))
+			continue
+		}
+
+		col := r.columnIndex(f.name)
+		if col >= 0 && col < len(vals) {
+			if err := setValue(fv, vals[col]); err != nil {
+				return fmt.Errorf("dql: scan column %q: %w", r.Columns[col], err)
+			}
+			continue
+		}
+
+		if tag, ok := r.lookupTag(f.name); ok {
+			if err := setValue(fv, tag); err != nil {
+				return fmt.Errorf("dql: scan tag %q: %w", f.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// columnIndex find column by name, exact match preferred.
+func (r *Row) columnIndex(name string) int {
+	for i, col := range r.Columns {
+		if col == name {
+			return i
+		}
+	}
+
+	for i, col := range r.Columns {
+		if strings.EqualFold(col, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (r *Row) lookupTag(name string) (string, bool) {
+	if v, ok := r.Tags[name]; ok {
+		return v, true
+	}
+
+	for k, v := range r.Tags {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+var (
+	errNotIntegral = errors.New("not an integral number")
+	errOutOfRange  = errors.New("out of range")
+)
+
+// setValue convert JSON value val and set to v.
+func setValue(v reflect.Value, val any) error {
+	if val == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), val); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := toTime(val)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(val))
+
+	case reflect.String:
+		switch x := val.(type) {
+		case string:
+			v.SetString(x)
+		case float64:
+			v.SetString(strconv.FormatFloat(x, 'f', -1, 64))
+		case bool:
+			v.SetString(strconv.FormatBool(x))
+		case json.Number:
+			v.SetString(x.String())
+		default:
+			j, err := json.Marshal(x)
+			if err != nil {
+				return err
+			}
+			v.SetString(string(j))
+		}
+
+	case reflect.Bool:
+		switch x := val.(type) {
+		case bool:
+			v.SetBool(x)
+		case string:
+			b, err := strconv.ParseBool(x)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+		default:
+			return fmt.Errorf("can not convert %T to bool", val)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(val)
+		if errors.Is(err, errOutOfRange) || (err == nil && v.OverflowInt(n)) {
+			return fmt.Errorf("%v overflows %s", val, v.Type())
+		}
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toUint(val)
+		if errors.Is(err, errOutOfRange) || (err == nil && v.OverflowUint(n)) {
+			return fmt.Errorf("%v overflows %s", val, v.Type())
+		}
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(val)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	default:
+		// for slice/map/struct, convert via JSON
+		j, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(j, v.Addr().Interface()); err != nil {
+			return fmt.Errorf("can not convert %T to %s: %w", val, v.Type(), err)
+		}
+	}
+
+	return nil
+}
+
+// toInt convert val to int64, json.Number of integer parsed directly without
+// losing precision. Float should be integral and within range of int64.
+func toInt(val any) (int64, error) {
+	switch x := val.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case json.Number:
+		if n, err := strconv.ParseInt(x.String(), 10, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	f, err := toFloat(val)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case math.IsNaN(f), f != math.Trunc(f):
+		return 0, fmt.Errorf("%v: %w", val, errNotIntegral)
+	case f < math.MinInt64 || f >= math.MaxInt64:
+		return 0, fmt.Errorf("%v: %w", val, errOutOfRange)
+	}
+	return int64(f), nil
+}
+
+// toUint is the same as toInt, but for uint64.
+func toUint(val any) (uint64, error) {
+	switch x := val.(type) {
+	case int64:
+		if x < 0 {
+			return 0, fmt.Errorf("%v: %w", val, errOutOfRange)
+		}
+		return uint64(x), nil
+	case int:
+		if x < 0 {
+			return 0, fmt.Errorf("%v: %w", val, errOutOfRange)
+		}
+		return uint64(x), nil
+	case json.Number:
+		if n, err := strconv.ParseUint(x.String(), 10, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	f, err := toFloat(val)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case math.IsNaN(f), f != math.Trunc(f):
+		return 0, fmt.Errorf("%v: %w", val, errNotIntegral)
+	case f < 0 || f >= math.MaxUint64:
+		return 0, fmt.Errorf("%v: %w", val, errOutOfRange)
+	}
+	return uint64(f), nil
+}
+
+func toFloat(val any) (float64, error) {
+	switch x := val.(type) {
+	case float64:
+		return x, nil
+	case json.Number:
+		return x.Float64()
+	case int64:
+		return float64(x), nil
+	case int:
+		return float64(x), nil
+	case string:
+		return strconv.ParseFloat(x, 64)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("can not convert %T to number", val)
+	}
+}
+
+// toTime convert UNIX timestamp in ms or RFC3339 string to time.
+func toTime(val any) (time.Time, error) {
+	if s, ok := val.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+	}
+
+	f, err := toFloat(val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("can not convert %v to time", val)
+	}
+
+	return time.UnixMilli(int64(f)), nil
+}
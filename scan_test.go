@@ -0,0 +1,171 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scanBase struct {
+	Time time.Time `dql:"time"`
+}
+
+type scanLog struct {
+	scanBase
+
+	Status   int               `dql:"status"`
+	Cost     float64           `dql:"cost"`
+	Message  string            `dql:"message"`
+	Host     string            `dql:"host"`
+	Service  *string           `dql:"service"`
+	OK       bool              `dql:"ok"`
+	Port     uint16            `dql:"port"`
+	Tags     map[string]string `dql:",tags"`
+	Extra    any               `dql:"extra"`
+	Ignored  string            `dql:"-"`
+	Untagged string
+}
+
+func TestScan(t *T.T) {
+	var r Row
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "name": "nginx",
+  "tags": {"host": "host-1", "region": "cn"},
+  "columns": ["time", "status", "cost", "message", "service", "ok", "port", "extra", "untagged"],
+  "values": [
+    [1680172008117, 200, 1.5, "hello", "web", true, 80, {"a": 1}, "x"],
+    [1680172008118, 404, 2, "world", null, false, 443, null, "y"]
+  ]
+}`), &r))
+
+	t.Run("scan", func(t *T.T) {
+		var l scanLog
+		l.Ignored = "keep"
+
+		require.NoError(t, r.Scan(&l))
+
+		assert.Equal(t, int64(1680172008117), l.Time.UnixMilli())
+		assert.Equal(t, 200, l.Status)
+		assert.Equal(t, 1.5, l.Cost)
+		assert.Equal(t, "hello", l.Message)
+		assert.Equal(t, "host-1", l.Host) // from tags
+		require.NotNil(t, l.Service)
+		assert.Equal(t, "web", *l.Service)
+		assert.True(t, l.OK)
+		assert.Equal(t, uint16(80), l.Port)
+		assert.Equal(t, map[string]string{"host": "host-1", "region": "cn"}, l.Tags)
+		assert.Equal(t, map[string]any{"a": float64(1)}, l.Extra)
+		assert.Equal(t, "keep", l.Ignored)
+		assert.Equal(t, "x", l.Untagged)
+	})
+
+	t.Run("scan-all", func(t *T.T) {
+		var logs []scanLog
+		require.NoError(t, r.ScanAll(&logs))
+		require.Len(t, logs, 2)
+		assert.Equal(t, 404, logs[1].Status)
+		assert.Nil(t, logs[1].Service)
+
+		var ptrs []*scanLog
+		require.NoError(t, r.ScanAll(&ptrs))
+		require.Len(t, ptrs, 2)
+		assert.Equal(t, "world", ptrs[1].Message)
+	})
+
+	t.Run("conversion-error", func(t *T.T) {
+		var x struct {
+			Status int `dql:"cost"`
+		}
+		err := r.Scan(&x)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `"cost"`)
+
+		var y struct {
+			Port int8 `dql:"port"`
+		}
+		assert.NoError(t, r.Scan(&y))
+		err = r.ScanAt(1, &y)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overflows")
+
+		var z struct {
+			Region int `dql:"region"`
+		}
+		err = r.Scan(&z)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `tag "region"`)
+	})
+
+	t.Run("invalid-dst", func(t *T.T) {
+		var l scanLog
+		assert.Error(t, r.Scan(l))
+		assert.Error(t, r.ScanAt(2, &l))
+
+		var ints []int
+		assert.Error(t, r.ScanAll(&ints))
+	})
+
+	t.Run("integers", func(t *T.T) {
+		var (
+			i64 int64
+			u64 uint64
+			i8  int8
+		)
+
+		ok := []struct {
+			dst  any
+			val  any
+			want any
+		}{
+			{&i64, json.Number("9007199254740993"), int64(9007199254740993)}, // not exact as float64
+			{&i64, json.Number("-9223372036854775808"), int64(math.MinInt64)},
+			{&i64, json.Number("1e3"), int64(1000)},
+			{&i64, float64(-1 << 63), int64(math.MinInt64)},
+			{&u64, json.Number("18446744073709551615"), uint64(math.MaxUint64)},
+			{&u64, 12.0, uint64(12)},
+			{&i8, json.Number("-128"), int8(-128)},
+		}
+
+		for _, tc := range ok {
+			v := reflect.ValueOf(tc.dst).Elem()
+			require.NoError(t, setValue(v, tc.val), "%v", tc.val)
+			assert.Equal(t, tc.want, v.Interface(), "%v", tc.val)
+		}
+
+		bad := []struct {
+			dst any
+			val any
+			err string
+		}{
+			{&i64, float64(1 << 63), "overflows"},
+			{&i64, math.Inf(-1), "overflows"},
+			{&i64, json.Number("9223372036854775808"), "overflows"},
+			{&u64, -1.0, "overflows"},
+			{&u64, json.Number("-1"), "overflows"},
+			{&u64, float64(1 << 64), "overflows"},
+			{&i8, json.Number("128"), "overflows"},
+			{&i64, math.NaN(), errNotIntegral.Error()},
+			{&u64, math.NaN(), errNotIntegral.Error()},
+			{&i64, json.Number("1.5"), errNotIntegral.Error()},
+		}
+
+		for _, tc := range bad {
+			err := setValue(reflect.ValueOf(tc.dst).Elem(), tc.val)
+			require.Error(t, err, "%v", tc.val)
+			assert.Contains(t, err.Error(), tc.err, "%v", tc.val)
+		}
+
+		assert.True(t, errors.Is(setValue(reflect.ValueOf(&i64).Elem(), 0.5), errNotIntegral))
+	})
+}
@@ -0,0 +1,117 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// timeWindow is the time range set by WithTimeWindow or WithLast, the
+// relative window evaluated when the query is sent.
+type timeWindow struct {
+	start, end time.Time
+	last       time.Duration // relative to now, if not 0
+}
+
+// WithTimeWindow set time range of the query to [start, end), it replace
+// time range set by other options. start should before end.
+func WithTimeWindow(start, end time.Time) DQLOption {
+	return func(q *dql) {
+		if !start.Before(end) {
+			q.setErr(fmt.Errorf("%w: time window start(%s) should before end(%s)",
+				ErrInvalidOption, start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano)))
+			return
+		}
+
+		q.TimeRange = nil
+		q.window = &timeWindow{start: start, end: end}
+	}
+}
+
+// WithLast set time range of the query to the last du, such as last 15
+// minutes. The time range is relative to the time when the query sent,
+// not the time the option applied, so the built query can be reused.
+// It replace time range set by other options.
+func WithLast(du time.Duration) DQLOption {
+	return func(q *dql) {
+		if du <= 0 {
+			q.setErr(fmt.Errorf("%w: invalid last duration %s", ErrInvalidOption, du))
+			return
+		}
+
+		q.TimeRange = nil
+		q.window = &timeWindow{last: du}
+	}
+}
+
+// WithAlignedWindow align time range of the query to multiple of step: start
+// rounded down and end rounded up. For example, with step 1m, the last 15
+// minutes from 10:20:30 is aligned to [10:05:00, 10:21:00). It's useful to
+// get stable time buckets for time-aggregate query.
+func WithAlignedWindow(step time.Duration) DQLOption {
+	return func(q *dql) {
+		if step < time.Millisecond {
+			q.setErr(fmt.Errorf("%w: align step %s should not less than 1ms", ErrInvalidOption, step))
+			return
+		}
+
+		q.align = step
+	}
+}
+
+func (q *dql) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// timeRange get time range of the query in UNIX timestamp ms, with relative
+// window evaluated at now. nil returned if no time range set.
+func (q *dql) timeRange(now time.Time) []int64 {
+	var start, end int64
+
+	switch {
+	case q.window != nil && q.window.last > 0:
+		start, end = now.Add(-q.window.last).UnixMilli(), now.UnixMilli()
+	case q.window != nil:
+		start, end = q.window.start.UnixMilli(), q.window.end.UnixMilli()
+	case len(q.TimeRange) == 2:
+		start, end = q.TimeRange[0], q.TimeRange[1]
+	default:
+		return q.TimeRange
+	}
+
+	if step := q.align.Milliseconds(); step > 0 {
+		start -= mod(start, step)
+		if r := mod(end, step); r != 0 {
+			end += step - r
+		}
+	}
+
+	return []int64{start, end}
+}
+
+// mod is the non-negative modulo, timestamp before 1970 are negative.
+func mod(n, m int64) int64 {
+	r := n % m
+	if r < 0 {
+		r += m
+	}
+	return r
+}
+
+// MarshalJSON implements json.Marshaler. Time range set by WithLast is
+// evaluated here, so it's relative to the time of sending.
+func (q *dql) MarshalJSON() ([]byte, error) {
+	type alias dql // avoid recursion
+
+	x := *(*alias)(q)
+	x.TimeRange = q.timeRange(time.Now())
+
+	return json.Marshal(&x)
+}
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"encoding/json"
+	"errors"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRangeOptions(t *T.T) {
+	t.Run("replace", func(t *T.T) {
+		q := MustBuildDQL("L::nginx", WithTimeRange(1, 2), WithTimeRange(3, 4))
+		assert.Equal(t, []int64{3, 4}, q.TimeRange)
+
+		start := time.UnixMilli(1680172008117)
+		q = MustBuildDQL("L::nginx", WithTimeRange(1, 2), WithTimeWindow(start, start.Add(time.Minute)))
+		assert.Nil(t, q.TimeRange)
+		assert.Equal(t, []int64{1680172008117, 1680172068117}, q.timeRange(time.Now()))
+
+		q = MustBuildDQL("L::nginx", WithLast(time.Hour), WithTimeRange(3, 4))
+		assert.Equal(t, []int64{3, 4}, q.timeRange(time.Now()))
+	})
+
+	t.Run("int64", func(t *T.T) {
+		start := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC) // overflow int32 in ms
+		q := MustBuildDQL("L::nginx", WithTimeWindow(start, start.Add(time.Second)))
+
+		j, err := json.Marshal(q)
+		require.NoError(t, err)
+		assert.Contains(t, string(j), `"time_range":[4102444800000,4102444801000]`)
+	})
+
+	t.Run("last-deferred", func(t *T.T) {
+		q := MustBuildDQL("L::nginx", WithLast(15*time.Minute))
+		assert.Nil(t, q.TimeRange)
+
+		now := time.UnixMilli(1680172008117)
+		assert.Equal(t, []int64{1680171108117, 1680172008117}, q.timeRange(now))
+		assert.Equal(t, []int64{1680171168117, 1680172068117}, q.timeRange(now.Add(time.Minute)))
+
+		before := time.Now().UnixMilli()
+		j, err := json.Marshal(q)
+		require.NoError(t, err)
+
+		var x struct {
+			TimeRange []int64 `json:"time_range"`
+		}
+		require.NoError(t, json.Unmarshal(j, &x))
+		require.Len(t, x.TimeRange, 2)
+		assert.GreaterOrEqual(t, x.TimeRange[1], before)
+		assert.Equal(t, int64(15*60*1000), x.TimeRange[1]-x.TimeRange[0])
+
+		// marshal does not modify the query
+		assert.Nil(t, q.TimeRange)
+	})
+
+	t.Run("aligned", func(t *T.T) {
+		now := time.Date(2023, 3, 30, 10, 20, 30, 0, time.UTC)
+		q := MustBuildDQL("L::nginx", WithLast(15*time.Minute), WithAlignedWindow(time.Minute))
+
+		assert.Equal(t, []int64{
+			time.Date(2023, 3, 30, 10, 5, 0, 0, time.UTC).UnixMilli(),
+			time.Date(2023, 3, 30, 10, 21, 0, 0, time.UTC).UnixMilli(),
+		}, q.timeRange(now))
+
+		// already aligned
+		q = MustBuildDQL("L::nginx", WithTimeRange(60000, 120000), WithAlignedWindow(time.Minute))
+		assert.Equal(t, []int64{60000, 120000}, q.timeRange(now))
+	})
+
+	t.Run("errors", func(t *T.T) {
+		now := time.Now()
+
+		_, err := BuildDQL("L::nginx", WithTimeWindow(now, now))
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithTimeWindow(now, now.Add(-time.Second)))
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithLast(0))
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithAlignedWindow(time.Microsecond))
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithLast(2*time.Hour), WithMaxDuration(time.Hour), WithValidate(true))
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+	})
+}
@@ -0,0 +1,347 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Sample is a value of time series at the time, Value is NaN if it's null.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// IsNull check if the sample value is null.
+func (s Sample) IsNull() bool {
+	return math.IsNaN(s.Value)
+}
+
+// A TimeSeries is a value column of series within the result, such as
+// series of query with [start:end:interval] or WithStepInterval.
+type TimeSeries struct {
+	Name   string            // series name
+	Column string            // value column name
+	Labels map[string]string // tags of the series
+
+	// Samples in time order.
+	Samples []Sample
+}
+
+// TimeSeries convert series within the result into time series, see
+// NewTimeSeries.
+func (r *DQLResult) TimeSeries() ([]*TimeSeries, error) {
+	return NewTimeSeries(r)
+}
+
+// NewTimeSeries convert series within results into time series. Each
+// value column(except "time") of the series is a time series, and series
+// with the same name, column and tags across results are merged, such as
+// results of paging query. On duplicate timestamp, the later one is used.
+// Time series are sorted by name, column and tags.
+func NewTimeSeries(results ...*DQLResult) ([]*TimeSeries, error) {
+	var (
+		all   []*TimeSeries
+		index = map[string]*TimeSeries{}
+	)
+
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+
+		for _, s := range r.Series {
+			ti := -1
+			for i, col := range s.Columns {
+				if col == "time" {
+					ti = i
+				}
+			}
+
+			if ti < 0 {
+				return nil, fmt.Errorf("series %q without time column", s.Name)
+			}
+
+			for i, col := range s.Columns {
+				if i == ti {
+					continue
+				}
+
+				ts := &TimeSeries{Name: s.Name, Column: col, Labels: s.Tags}
+				if x, ok := index[ts.key()]; ok {
+					ts = x
+				} else {
+					index[ts.key()] = ts
+					all = append(all, ts)
+				}
+
+				for _, vals := range s.Values {
+					if ti >= len(vals) || i >= len(vals) {
+						continue
+					}
+
+					tm, err := toTime(vals[ti])
+					if err != nil {
+						return nil, fmt.Errorf("series %q: %w", s.Name, err)
+					}
+
+					ts.Samples = append(ts.Samples, Sample{Time: tm, Value: sampleValue(vals[i])})
+				}
+			}
+		}
+	}
+
+	for _, ts := range all {
+		ts.normalize()
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].key() < all[j].key()
+	})
+
+	return all, nil
+}
+
+// sampleValue convert value to float64, NaN for null or non-numeric value.
+func sampleValue(v any) float64 {
+	if _, ok := v.(string); ok {
+		return math.NaN()
+	}
+
+	f, err := toFloat(v)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func (ts *TimeSeries) key() string {
+	return ts.Name + "\x00" + ts.Column + labelsKey(ts.Labels)
+}
+
+// labelsKey get the key of labels, sorted by label name.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString("\x00" + k + "=" + labels[k])
+	}
+	return sb.String()
+}
+
+// normalize sort samples by time, and remove duplicates(the later kept).
+func (ts *TimeSeries) normalize() {
+	sort.SliceStable(ts.Samples, func(i, j int) bool {
+		return ts.Samples[i].Time.Before(ts.Samples[j].Time)
+	})
+
+	var res []Sample
+	for _, s := range ts.Samples {
+		if n := len(res); n > 0 && res[n-1].Time.Equal(s.Time) {
+			res[n-1] = s
+			continue
+		}
+		res = append(res, s)
+	}
+	ts.Samples = res
+}
+
+func (ts *TimeSeries) with(samples []Sample) *TimeSeries {
+	return &TimeSeries{Name: ts.Name, Column: ts.Column, Labels: ts.Labels, Samples: samples}
+}
+
+// bucket get the start of step bucket of t, aligned to UNIX epoch.
+func bucket(t time.Time, step time.Duration) time.Time {
+	ms, stepMS := t.UnixMilli(), step.Milliseconds()
+	return time.UnixMilli(ms - mod(ms, stepMS))
+}
+
+// Resample aggregate samples into buckets of step by fn, bucket time is
+// the start of the bucket aligned to UNIX epoch. Null values are ignored,
+// and empty bucket not generated(see Fill). Only AggCount, AggSum, AggMean,
+// AggMin, AggMax, AggFirst and AggLast are supported.
+func (ts *TimeSeries) Resample(step time.Duration, fn AggFunc) *TimeSeries {
+	if step < time.Millisecond {
+		return ts.with(append([]Sample(nil), ts.Samples...))
+	}
+
+	var (
+		res  []Sample
+		vals []any
+		cur  time.Time
+	)
+
+	flush := func() {
+		if len(vals) == 0 {
+			return
+		}
+
+		var f float64
+		switch x := aggregate(&Column{values: vals}, fn).(type) {
+		case float64:
+			f = x
+		case int64: // AggCount
+			f = float64(x)
+		default:
+			f = math.NaN()
+		}
+
+		res = append(res, Sample{Time: cur, Value: f})
+		vals = vals[:0]
+	}
+
+	for _, s := range ts.Samples {
+		b := bucket(s.Time, step)
+		if !b.Equal(cur) {
+			flush()
+			cur = b
+		}
+
+		if s.IsNull() {
+			vals = append(vals, nil)
+		} else {
+			vals = append(vals, s.Value)
+		}
+	}
+	flush()
+
+	return ts.with(res)
+}
+
+// Align align samples to step, the last sample within each step used.
+func (ts *TimeSeries) Align(step time.Duration) *TimeSeries {
+	return ts.Resample(step, AggLast)
+}
+
+// A FillPolicy is how to fill missing or null samples.
+type FillPolicy int
+
+// Fill policies.
+const (
+	FillNull     FillPolicy = iota // fill NaN
+	FillZero                       // fill 0
+	FillPrevious                   // fill previous non-null value
+	FillLinear                     // linear interpolation between neighbour non-null values
+)
+
+// Fill fill missing samples on every step between the first and last
+// sample, and null samples are filled too. The series should be aligned
+// to step(see Align), samples not on the step are dropped.
+func (ts *TimeSeries) Fill(step time.Duration, policy FillPolicy) *TimeSeries {
+	if step < time.Millisecond || len(ts.Samples) == 0 {
+		return ts.with(append([]Sample(nil), ts.Samples...))
+	}
+
+	known := make(map[int64]float64, len(ts.Samples))
+	for _, s := range ts.Samples {
+		known[s.Time.UnixMilli()] = s.Value
+	}
+
+	start := ts.Samples[0].Time.UnixMilli()
+	end := ts.Samples[len(ts.Samples)-1].Time.UnixMilli()
+	stepMS := step.Milliseconds()
+
+	var res []Sample
+	for t := start; t <= end; t += stepMS {
+		v, ok := known[t]
+		if !ok {
+			v = math.NaN()
+		}
+		res = append(res, Sample{Time: time.UnixMilli(t), Value: v})
+	}
+
+	switch policy {
+	case FillZero:
+		for i := range res {
+			if res[i].IsNull() {
+				res[i].Value = 0
+			}
+		}
+
+	case FillPrevious:
+		prev := math.NaN()
+		for i := range res {
+			if res[i].IsNull() {
+				res[i].Value = prev
+			} else {
+				prev = res[i].Value
+			}
+		}
+
+	case FillLinear:
+		prev := -1
+		for i := range res {
+			if res[i].IsNull() {
+				continue
+			}
+
+			if prev >= 0 && i-prev > 1 {
+				x0, y0 := res[prev].Time.UnixMilli(), res[prev].Value
+				x1, y1 := res[i].Time.UnixMilli(), res[i].Value
+				for j := prev + 1; j < i; j++ {
+					x := res[j].Time.UnixMilli()
+					res[j].Value = y0 + (y1-y0)*float64(x-x0)/float64(x1-x0)
+				}
+			}
+			prev = i
+		}
+
+	case FillNull:
+	}
+
+	return ts.with(res)
+}
+
+// Derivative get per-second change between adjacent non-null samples, the
+// result sample is at the time of the later one.
+func (ts *TimeSeries) Derivative() *TimeSeries {
+	return ts.deriv(false)
+}
+
+// Rate get per-second rate of counter, same as Derivative but decrease of
+// the value is treated as counter reset, and the increase is the later value.
+func (ts *TimeSeries) Rate() *TimeSeries {
+	return ts.deriv(true)
+}
+
+func (ts *TimeSeries) deriv(counter bool) *TimeSeries {
+	var (
+		res  []Sample
+		prev *Sample
+	)
+
+	for i := range ts.Samples {
+		s := &ts.Samples[i]
+		if s.IsNull() {
+			continue
+		}
+
+		if prev != nil {
+			dt := s.Time.Sub(prev.Time).Seconds()
+			dv := s.Value - prev.Value
+			if counter && dv < 0 {
+				dv = s.Value
+			}
+
+			if dt > 0 {
+				res = append(res, Sample{Time: s.Time, Value: dv / dt})
+			}
+		}
+
+		prev = s
+	}
+
+	return ts.with(res)
+}
@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"math"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// samplesOf build samples on (ms, value) pairs, nil value is null.
+func samplesOf(pairs ...any) []Sample {
+	var res []Sample
+	for i := 0; i+1 < len(pairs); i += 2 {
+		v := math.NaN()
+		if f, ok := pairs[i+1].(float64); ok {
+			v = f
+		}
+		res = append(res, Sample{Time: time.UnixMilli(int64(pairs[i].(int))), Value: v})
+	}
+	return res
+}
+
+// values get sample values, NaN converted to -1 for comparing.
+func values(ts *TimeSeries) []float64 {
+	var res []float64
+	for _, s := range ts.Samples {
+		if s.IsNull() {
+			res = append(res, -1)
+		} else {
+			res = append(res, s.Value)
+		}
+	}
+	return res
+}
+
+func TestTimeSeries(t *T.T) {
+	t.Run("from-results", func(t *T.T) {
+		r1 := &DQLResult{Series: []*Row{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "b"},
+				Columns: []string{"time", "usage", "load"},
+				Values: [][]any{
+					{float64(2000), 2.0, 1.0},
+					{float64(1000), 1.0, nil},
+				},
+			},
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "a"},
+				Columns: []string{"time", "usage"},
+				Values:  [][]any{{float64(1000), 5.0}},
+			},
+		}}
+
+		r2 := &DQLResult{Series: []*Row{
+			{
+				Name:    "cpu",
+				Tags:    map[string]string{"host": "b"},
+				Columns: []string{"usage", "time"},
+				Values: [][]any{
+					{3.0, float64(3000)},
+					{2.5, float64(2000)}, // overwrite
+				},
+			},
+		}}
+
+		all, err := NewTimeSeries(r1, nil, r2)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+
+		// sorted by name, column and tags
+		assert.Equal(t, "load", all[0].Column)
+		assert.Equal(t, []float64{-1, 1}, values(all[0]))
+		assert.Equal(t, "a", all[1].Labels["host"])
+
+		usage := all[2]
+		assert.Equal(t, "usage", usage.Column)
+		assert.Equal(t, []float64{1, 2.5, 3}, values(usage))
+		assert.Equal(t, time.UnixMilli(1000), usage.Samples[0].Time)
+
+		one, err := r1.TimeSeries()
+		require.NoError(t, err)
+		assert.Len(t, one, 3)
+
+		_, err = NewTimeSeries(&DQLResult{Series: []*Row{{Name: "x", Columns: []string{"v"}}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("resample", func(t *T.T) {
+		ts := &TimeSeries{Samples: samplesOf(
+			0, 1.0,
+			500, 3.0,
+			1200, nil,
+			2100, 4.0,
+			2900, 6.0,
+		)}
+
+		res := ts.Resample(time.Second, AggMean)
+		assert.Equal(t, []time.Time{time.UnixMilli(0), time.UnixMilli(1000), time.UnixMilli(2000)},
+			[]time.Time{res.Samples[0].Time, res.Samples[1].Time, res.Samples[2].Time})
+		assert.Equal(t, []float64{2, -1, 5}, values(res))
+
+		assert.Equal(t, []float64{3, -1, 6}, values(ts.Align(time.Second)))
+		assert.Equal(t, []float64{2, 0, 2}, values(ts.Resample(time.Second, AggCount)))
+
+		// invalid step, copy returned
+		assert.Len(t, ts.Resample(0, AggMean).Samples, 5)
+	})
+
+	t.Run("fill", func(t *T.T) {
+		ts := &TimeSeries{Samples: samplesOf(
+			0, 1.0,
+			2000, nil,
+			3000, 4.0,
+			5000, 8.0,
+		)}
+
+		assert.Equal(t, []float64{1, -1, -1, 4, -1, 8}, values(ts.Fill(time.Second, FillNull)))
+		assert.Equal(t, []float64{1, 0, 0, 4, 0, 8}, values(ts.Fill(time.Second, FillZero)))
+		assert.Equal(t, []float64{1, 1, 1, 4, 4, 8}, values(ts.Fill(time.Second, FillPrevious)))
+		assert.Equal(t, []float64{1, 2, 3, 4, 6, 8}, values(ts.Fill(time.Second, FillLinear)))
+
+		// leading null can not be filled by previous or linear
+		ts = &TimeSeries{Samples: samplesOf(0, nil, 1000, 2.0)}
+		assert.Equal(t, []float64{-1, 2}, values(ts.Fill(time.Second, FillPrevious)))
+		assert.Equal(t, []float64{-1, 2}, values(ts.Fill(time.Second, FillLinear)))
+	})
+
+	t.Run("rate", func(t *T.T) {
+		ts := &TimeSeries{Samples: samplesOf(
+			0, 10.0,
+			1000, 20.0,
+			2000, nil,
+			3000, 40.0,
+			4000, 5.0, // counter reset
+		)}
+
+		assert.Equal(t, []float64{10, 10, 5}, values(ts.Rate()))
+		assert.Equal(t, []float64{10, 10, -35}, values(ts.Derivative()))
+		assert.Equal(t, time.UnixMilli(3000), ts.Rate().Samples[1].Time)
+	})
+}
@@ -0,0 +1,169 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/parser"
+)
+
+// ErrInvalidOption returned on conflicting or invalid DQL options.
+var ErrInvalidOption = errors.New("dql invalid option")
+
+// A ValidationError is the error of client-side validation(see WithValidate).
+type ValidationError struct {
+	// Kind is one of ErrParse, ErrMaxDuration or ErrInvalidOption.
+	Kind error
+	Msg  string
+
+	// Err is the underlying error, such as *parser.Error on syntax error.
+	Err error
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return "dql validation failed: " + e.Msg
+}
+
+// Is used to match the error against sentinel errors like ErrParse.
+func (e *ValidationError) Is(target error) bool {
+	return target == e.Kind //nolint:errorlint
+}
+
+// Unwrap get the underlying error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// WithValidate enable client-side validation within BuildDQL, invalid
+// query will get an error on BuildDQL(or panic on MustBuildDQL) without
+// sending it to the server. Following are checked:
+//
+//   - DQL syntax, or PromQL syntax for PromQL query(see promql.Check)
+//   - start < end of WithTimeRange(start == end allowed for BuildInstantPromQL)
+//   - time range within DQL(such as [1d]) and time range options(such
+//     as WithTimeRange and WithLast) not exceed WithMaxDuration
+//   - conflicting options, such as WithOffset with WithSearchAfter
+//   - options not available on the namespace(see Warnings)
+func WithValidate(on bool) DQLOption {
+	return func(q *dql) {
+		q.validate = on
+	}
+}
+
+// Validate validate the query, see WithValidate for details.
+func (q *dql) Validate() error {
+	var (
+		ast *parser.Query
+		err error
+	)
+
+	if q.QType == "promql" {
+		if err := q.checkPromQL(); err != nil {
+			return err
+		}
+	} else if ast, err = q.parse(); err != nil {
+		return err
+	}
+
+	tr := q.timeRange(time.Now())
+	if len(tr) > 0 {
+		if len(tr) != 2 {
+			return &ValidationError{
+				Kind: ErrInvalidOption,
+				Msg:  fmt.Sprintf("time range should be [start, end], got %v", tr),
+			}
+		}
+
+		if tr[0] > tr[1] || tr[0] == tr[1] && !q.instant {
+			return &ValidationError{
+				Kind: ErrInvalidOption,
+				Msg:  fmt.Sprintf("time range start(%d) should less than end(%d)", tr[0], tr[1]),
+			}
+		}
+	}
+
+	if q.MaxDuration != "" {
+		if err := q.checkMaxDuration(ast, tr); err != nil {
+			return err
+		}
+	}
+
+	if q.Offset > 0 && len(q.SearchAfter) > 0 {
+		return &ValidationError{
+			Kind: ErrInvalidOption,
+			Msg:  "offset and search-after can not be used together",
+		}
+	}
+
+	if warnings := q.Warnings(); len(warnings) > 0 {
+		return &ValidationError{
+			Kind: ErrInvalidOption,
+			Msg:  strings.Join(warnings, "; "),
+		}
+	}
+
+	return nil
+}
+
+// parse parse the DQL, and the query statement returned. For function
+// statements such as show_measurement(), nil returned.
+func (q *dql) parse() (*parser.Query, error) {
+	stmt, err := parser.Parse(q.DQL)
+	if err != nil {
+		return nil, &ValidationError{Kind: ErrParse, Msg: err.Error(), Err: err}
+	}
+
+	ast, _ := stmt.(*parser.Query)
+	return ast, nil
+}
+
+func (q *dql) checkMaxDuration(ast *parser.Query, tr []int64) error {
+	maxDuration, err := time.ParseDuration(q.MaxDuration)
+	if err != nil {
+		return &ValidationError{
+			Kind: ErrInvalidOption,
+			Msg:  fmt.Sprintf("invalid max duration %q", q.MaxDuration),
+			Err:  err,
+		}
+	}
+
+	var span time.Duration
+
+	switch {
+	case len(tr) == 2: // time range option overwrite time range within DQL
+		span = time.Duration(tr[1]-tr[0]) * time.Millisecond
+	case ast != nil && ast.TimeRange != nil:
+		span = timeRangeSpan(ast.TimeRange, time.Now())
+	}
+
+	if span > maxDuration {
+		return &ValidationError{
+			Kind: ErrMaxDuration,
+			Msg:  fmt.Sprintf("time range %s should less than %s", span, maxDuration),
+		}
+	}
+
+	return nil
+}
+
+// timeRangeSpan get the span of [start:end], 0 if start not set.
+func timeRangeSpan(tr *parser.TimeRange, now time.Time) time.Duration {
+	if tr.Start == nil {
+		return 0
+	}
+
+	end := now
+	if tr.End != nil {
+		end = tr.End.Time(now)
+	}
+
+	return end.Sub(tr.Start.Time(now))
+}
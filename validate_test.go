@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package dql
+
+import (
+	"errors"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/dql-go/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *T.T) {
+	t.Run("disabled-by-default", func(t *T.T) {
+		q, err := BuildDQL("L::nginx [30d:]", WithMaxDuration(time.Hour))
+		require.NoError(t, err)
+		assert.NotNil(t, q)
+
+		_, err = BuildDQL("L::nginx {")
+		assert.NoError(t, err)
+	})
+
+	t.Run("valid", func(t *T.T) {
+		for _, s := range []string{
+			"L::nginx [30m]",
+			"L::re(`.*`):(fill(count(__docid), 0) AS count) [1d] BY status",
+			"M::show_measurement()",
+		} {
+			_, err := BuildDQL(s, WithValidate(true), WithMaxDuration(48*time.Hour))
+			assert.NoError(t, err, s)
+		}
+
+		_, err := BuildDQL("sum(rate(http_requests_total[5m]))", WithQueryType("promql"), WithValidate(true))
+		assert.NoError(t, err)
+	})
+
+	t.Run("syntax", func(t *T.T) {
+		_, err := BuildDQL("L::nginx { host = }", WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrParse))
+
+		var perr *parser.Error
+		require.True(t, errors.As(err, &perr))
+		assert.Equal(t, "1:19", perr.Pos.String())
+
+		assert.Panics(t, func() {
+			MustBuildDQL("L::nginx {", WithValidate(true))
+		})
+	})
+
+	t.Run("time-range", func(t *T.T) {
+		_, err := BuildDQL("L::nginx", WithTimeRange(2000, 1000), WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+
+		_, err = BuildDQL("L::nginx", WithTimeRange(1000, 2000), WithValidate(true))
+		assert.NoError(t, err)
+	})
+
+	t.Run("max-duration", func(t *T.T) {
+		_, err := BuildDQL("L::nginx [30d:]", WithMaxDuration(time.Hour), WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+
+		_, err = BuildDQL("L::nginx [3h:1h]", WithMaxDuration(time.Hour), WithValidate(true))
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+
+		_, err = BuildDQL("L::nginx [2h:1h]", WithMaxDuration(time.Hour), WithValidate(true))
+		assert.NoError(t, err)
+
+		_, err = BuildDQL("L::nginx [1672502400000:1672588800000]", WithMaxDuration(time.Hour), WithValidate(true))
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+
+		// WithTimeRange overwrite the time range within DQL
+		_, err = BuildDQL("L::nginx [30d:]",
+			WithTimeRange(0, 1000), WithMaxDuration(time.Hour), WithValidate(true))
+		assert.NoError(t, err)
+
+		_, err = BuildDQL("L::nginx",
+			WithTimeRange(0, 2*3600*1000), WithMaxDuration(time.Hour), WithValidate(true))
+		assert.True(t, errors.Is(err, ErrMaxDuration))
+	})
+
+	t.Run("conflict", func(t *T.T) {
+		_, err := BuildDQL("L::nginx", WithOffset(10), WithSearchAfter(1, "a"), WithValidate(true))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidOption))
+		assert.False(t, errors.Is(err, ErrParse))
+
+		_, err = BuildDQL("L::nginx", WithOffset(10), WithValidate(true))
+		assert.NoError(t, err)
+	})
+}